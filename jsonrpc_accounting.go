@@ -0,0 +1,178 @@
+package jsonrpc
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrBudgetExceeded = errors.New("rpc: call budget exceeded")
+
+// AccountingKey identifies the calls of one method on one endpoint.
+type AccountingKey struct {
+	Endpoint string
+	Method   string
+}
+
+// AccountingEntry summarizes the calls sharing an AccountingKey. Every
+// attempt counts, so retried calls are counted once per request sent.
+type AccountingEntry struct {
+	Calls         int64
+	Errors        int64
+	BytesSent     int64
+	BytesReceived int64
+	Duration      time.Duration
+	Cost          float64
+}
+
+// AccountingReport is the summary of one interval.
+type AccountingReport struct {
+	Start   time.Time
+	End     time.Time
+	Entries map[AccountingKey]*AccountingEntry
+}
+
+// Accounting summarizes the calls of a Client per method and endpoint over
+// an interval and optionally enforces a budget on them. Set it as
+// Client.Accounting.
+type Accounting struct {
+	sync.Mutex
+
+	// Interval after which the summary is reported to OnReport and all
+	// counters, including the budget, start over. Zero never resets.
+	Interval time.Duration
+
+	// OnReport receives the summary of each elapsed interval.
+	OnReport func(report *AccountingReport)
+
+	// Cost returns the price of one call, used for MaxCost.
+	Cost func(endpoint, method string) float64
+
+	// MaxCalls, if positive, caps the number of calls per interval.
+	MaxCalls int64
+
+	// MaxCost, if positive, caps the total Cost of calls per interval.
+	MaxCost float64
+
+	// Clock times the intervals. Defaults to SystemClock.
+	Clock Clock
+
+	report *AccountingReport
+	calls  int64
+	cost   float64
+}
+
+// rollover starts a new interval if the current one elapsed, returning the
+// report of the finished interval.
+func (a *Accounting) rollover(now time.Time) (finished *AccountingReport) {
+	if a.report != nil && (a.Interval <= 0 || now.Before(a.report.Start.Add(a.Interval))) {
+		return
+	}
+	if a.report != nil {
+		finished = a.report
+		finished.End = now
+	}
+	a.report = &AccountingReport{Start: now, Entries: make(map[AccountingKey]*AccountingEntry)}
+	a.calls, a.cost = 0, 0
+	return
+}
+
+func (a *Accounting) entry(endpoint, method string) *AccountingEntry {
+	key := AccountingKey{endpoint, method}
+	entry, ok := a.report.Entries[key]
+	if !ok {
+		entry = &AccountingEntry{}
+		a.report.Entries[key] = entry
+	}
+	return entry
+}
+
+// reserve counts a call about to be sent, failing if it exceeds the budget.
+func (a *Accounting) reserve(endpoint, method string, sent int) (err error) {
+	var cost float64
+	if a.Cost != nil {
+		cost = a.Cost(endpoint, method)
+	}
+
+	a.Lock()
+	finished := a.rollover(clockOr(a.Clock).Now())
+	if a.MaxCalls > 0 && a.calls >= a.MaxCalls || a.MaxCost > 0 && a.cost+cost > a.MaxCost {
+		err = ErrBudgetExceeded
+	} else {
+		a.calls++
+		a.cost += cost
+		entry := a.entry(endpoint, method)
+		entry.Calls++
+		entry.Cost += cost
+		entry.BytesSent += int64(sent)
+	}
+	a.Unlock()
+
+	if finished != nil && a.OnReport != nil {
+		a.OnReport(finished)
+	}
+	return
+}
+
+// record completes the accounting of a sent call. Successful responses are
+// accounted once their body is closed.
+func (a *Accounting) record(endpoint, method string, start time.Time, resp *http.Response, err error) {
+	if err == nil && resp.StatusCode < 400 {
+		resp.Body = &accountedBody{ReadCloser: resp.Body, accounting: a, endpoint: endpoint, method: method, start: start}
+		return
+	}
+	a.Lock()
+	entry := a.entry(endpoint, method)
+	entry.Errors++
+	entry.Duration += time.Since(start)
+	a.Unlock()
+}
+
+// Snapshot returns a copy of the summary of the current interval. If that
+// ends an elapsed interval, its summary is reported to OnReport first.
+func (a *Accounting) Snapshot() *AccountingReport {
+	a.Lock()
+	now := clockOr(a.Clock).Now()
+	finished := a.rollover(now)
+	report := &AccountingReport{Start: a.report.Start, End: now, Entries: make(map[AccountingKey]*AccountingEntry)}
+	for key, entry := range a.report.Entries {
+		copied := *entry
+		report.Entries[key] = &copied
+	}
+	a.Unlock()
+
+	if finished != nil && a.OnReport != nil {
+		a.OnReport(finished)
+	}
+	return report
+}
+
+// accountedBody counts the bytes of a response body.
+type accountedBody struct {
+	io.ReadCloser
+	accounting *Accounting
+	endpoint   string
+	method     string
+	start      time.Time
+	n          int64
+	once       sync.Once
+}
+
+func (b *accountedBody) Read(p []byte) (n int, err error) {
+	n, err = b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return
+}
+
+func (b *accountedBody) Close() error {
+	b.once.Do(func() {
+		b.accounting.Lock()
+		entry := b.accounting.entry(b.endpoint, b.method)
+		entry.BytesReceived += b.n
+		entry.Duration += time.Since(b.start)
+		b.accounting.Unlock()
+	})
+	return b.ReadCloser.Close()
+}
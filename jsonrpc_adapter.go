@@ -0,0 +1,84 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// Adapter dispatches the calls of one method without reflection, e.g.
+// code generated for handlers serving many requests.
+type Adapter interface {
+	// NewArgs and NewReply return pointers to new argument and reply values.
+	NewArgs() interface{}
+	NewReply() interface{}
+
+	// Call calls the handler with values returned by NewArgs and NewReply.
+	Call(r *http.Request, args, reply interface{}) error
+}
+
+// RegisterAdapter adds a method dispatched to adapter. Middleware, options
+// and documentation work as for Register. NewArgs and NewReply must return
+// non-nil pointers.
+func (s *Server) RegisterAdapter(method string, adapter Adapter, opts ...MethodOption) error {
+	argsType, err := adapterType(method, "NewArgs", adapter.NewArgs())
+	if err != nil {
+		return err
+	}
+	replyType, err := adapterType(method, "NewReply", adapter.NewReply())
+	if err != nil {
+		return err
+	}
+	return s.add(method, &methodSpec{
+		argsType:  argsType,
+		replyType: replyType,
+		adapter:   adapter,
+	}, opts)
+}
+
+// adapterType returns the type v, returned by the adapter function name,
+// points to.
+func adapterType(method, name string, v interface{}) (reflect.Type, error) {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr || reflect.ValueOf(v).IsNil() {
+		return nil, fmt.Errorf("rpc: %s of method %s must return a non-nil pointer, got %T", name, method, v)
+	}
+	return t.Elem(), nil
+}
+
+// FuncAdapter is the Adapter of a handler function with a signature known
+// at compile time.
+type FuncAdapter[A, R any] func(ctx context.Context, args *A, reply *R) error
+
+func (f FuncAdapter[A, R]) NewArgs() interface{} {
+	return new(A)
+}
+
+func (f FuncAdapter[A, R]) NewReply() interface{} {
+	return new(R)
+}
+
+func (f FuncAdapter[A, R]) Call(r *http.Request, args, reply interface{}) error {
+	return f(handlerContext(r), args.(*A), reply.(*R))
+}
+
+// newArgs returns a pointer to a new argument value with the declared
+// defaults set.
+func (m *methodSpec) newArgs() (args interface{}) {
+	if m.adapter != nil {
+		args = m.adapter.NewArgs()
+	} else {
+		args = reflect.New(m.argsType).Interface()
+	}
+	m.applyDefaults(args)
+	return
+}
+
+// newReply returns a pointer to a new reply value.
+func (m *methodSpec) newReply() interface{} {
+	if m.adapter != nil {
+		return m.adapter.NewReply()
+	}
+	return reflect.New(m.replyType).Interface()
+}
@@ -0,0 +1,45 @@
+package jsonrpc
+
+import (
+	"net/http"
+	"testing"
+)
+
+type AdapterArgs struct {
+	Name string `json:"name"`
+}
+
+// testAdapter returns args and reply from NewArgs and NewReply.
+type testAdapter struct {
+	args, reply func() interface{}
+}
+
+func (a testAdapter) NewArgs() interface{}  { return a.args() }
+func (a testAdapter) NewReply() interface{} { return a.reply() }
+
+func (a testAdapter) Call(r *http.Request, args, reply interface{}) error {
+	return nil
+}
+
+func TestRegisterAdapter(t *testing.T) {
+	valid := func() interface{} { return new(AdapterArgs) }
+	tests := []struct {
+		name        string
+		args, reply func() interface{}
+		wantErr     bool
+	}{
+		{"pointers", valid, valid, false},
+		{"nil args", func() interface{} { return nil }, valid, true},
+		{"nil reply", valid, func() interface{} { return nil }, true},
+		{"typed nil", func() interface{} { return (*AdapterArgs)(nil) }, valid, true},
+		{"non-pointer", func() interface{} { return AdapterArgs{} }, valid, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := new(Server).RegisterAdapter("item.get", testAdapter{tt.args, tt.reply})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultAdvertiseInterval is how often Advertise refreshes an
+// advertisement.
+const DefaultAdvertiseInterval = 30 * time.Second
+
+// Advertisement announces a server's endpoint and methods to a Registry.
+type Advertisement struct {
+	// Service names the group of interchangeable servers clients look up.
+	Service string `json:"service"`
+
+	// Instance identifies this server within the service.
+	Instance string `json:"instance"`
+
+	// Endpoint is the URL clients call.
+	Endpoint string `json:"endpoint"`
+
+	Methods  []string          `json:"methods"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// TTL is how long the advertisement stays valid without a refresh, in
+	// seconds. Advertise defaults it to three refresh intervals.
+	TTL int `json:"ttl"`
+}
+
+// Registry is a discovery backend servers advertise themselves in.
+type Registry interface {
+	// Register adds or refreshes an advertisement.
+	Register(ctx context.Context, ad *Advertisement) error
+
+	// Deregister withdraws an advertisement.
+	Deregister(ctx context.Context, ad *Advertisement) error
+}
+
+// Advertise registers ad in registry with the server's visible method
+// names and refreshes it every interval, or DefaultAdvertiseInterval, so
+// that registered and unregistered methods are picked up. It withdraws the
+// advertisement and returns once ctx is done, or returns the error of the
+// first registration.
+func (s *Server) Advertise(ctx context.Context, registry Registry, ad Advertisement, interval time.Duration) (err error) {
+	if interval <= 0 {
+		interval = DefaultAdvertiseInterval
+	}
+	if ad.TTL <= 0 {
+		ad.TTL = int(3*interval/time.Second) + 1
+	}
+	ad.Methods = s.advertisedMethods(ctx)
+	if err = registry.Register(ctx, &ad); err != nil {
+		return
+	}
+
+	for wait(s.clock(), interval, ctx.Done()) {
+		// Failed refreshes are retried at the next tick.
+		ad.Methods = s.advertisedMethods(ctx)
+		registry.Register(ctx, &ad)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), interval)
+	defer cancel()
+	return registry.Deregister(ctx, &ad)
+}
+
+// advertisedMethods returns the names of the methods visible in ctx.
+func (s *Server) advertisedMethods(ctx context.Context) []string {
+	var names []string
+	for _, m := range s.Methods() {
+		if spec, err := s.get(m.Name); err == nil && spec.isVisible(ctx, m.Name) {
+			names = append(names, m.Name)
+		}
+	}
+	return names
+}
+
+// HTTPRegistry is a Registry reached over HTTP. Registrations are POSTed
+// to URL as JSON Advertisements and withdrawn with a DELETE of the same
+// body. The registry is expected to drop advertisements not refreshed
+// within their TTL.
+type HTTPRegistry struct {
+	URL string
+
+	// Client sends the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// Header is added to every request, e.g. for authorization.
+	Header http.Header
+}
+
+func (r *HTTPRegistry) Register(ctx context.Context, ad *Advertisement) error {
+	return r.send(ctx, "POST", ad)
+}
+
+func (r *HTTPRegistry) Deregister(ctx context.Context, ad *Advertisement) error {
+	return r.send(ctx, "DELETE", ad)
+}
+
+func (r *HTTPRegistry) send(ctx context.Context, method string, ad *Advertisement) (err error) {
+	var body []byte
+	if body, err = json.Marshal(ad); err != nil {
+		return
+	}
+	var req *http.Request
+	if req, err = http.NewRequest(method, r.URL, bytes.NewReader(body)); err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range r.Header {
+		req.Header[key] = values
+	}
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var resp *http.Response
+	if resp, err = client.Do(req); err != nil {
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		err = fmt.Errorf("rpc: registry %s answered %s", r.URL, resp.Status)
+	}
+	return
+}
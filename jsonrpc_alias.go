@@ -0,0 +1,64 @@
+package jsonrpc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Alias makes calls of alias go to the method target, e.g. to keep
+// accepting the old name of a renamed method during a migration. Calls are
+// served exactly like calls of target, with its middleware, visibility and
+// documentation; target may be replaced or registered later. alias must not
+// be a registered method.
+func (s *Server) Alias(alias, target string) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.methods[alias]; ok {
+		return fmt.Errorf("rpc: method already defined: %s", alias)
+	}
+	fold := s.CaseInsensitiveMethods
+	reg := &registry{methods: s.methods, aliases: s.aliases, folded: s.folded}
+	for name, hops := target, 0; ; hops++ {
+		if name == alias || fold && strings.EqualFold(name, alias) {
+			return fmt.Errorf("rpc: alias %s of %s would form a cycle", alias, target)
+		}
+		if hops == maxAliasHops {
+			return fmt.Errorf("rpc: alias %s of %s exceeds %d hops", alias, target, maxAliasHops)
+		}
+		next, ok := reg.next(name, fold)
+		if !ok {
+			break
+		}
+		name = next
+	}
+	if _, ok := s.aliases[alias]; !ok {
+		if err := s.foldName(alias); err != nil {
+			return err
+		}
+	}
+	if s.aliases == nil {
+		s.aliases = make(map[string]string)
+	}
+	s.aliases[alias] = target
+	s.publish()
+	return nil
+}
+
+// Aliases returns the sorted aliases of target.
+func (s *Server) Aliases(target string) (aliases []string) {
+	reg := s.routing()
+	for alias := range reg.aliases {
+		if reg.resolve(alias, s.CaseInsensitiveMethods) == target {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return
+}
+
+// resolve returns the method an alias or, with CaseInsensitiveMethods,
+// a differently cased name stands for, or method itself.
+func (s *Server) resolve(method string) string {
+	return s.routing().resolve(method, s.CaseInsensitiveMethods)
+}
@@ -0,0 +1,115 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestAlias(t *testing.T) {
+	tests := []struct {
+		name            string
+		caseInsensitive bool
+		methods         []string
+		aliases         [][2]string // alias, target
+		unregister      string
+		call            string
+		want            string
+		wantErr         bool // whether the last alias fails
+	}{
+		{"alias", false, []string{"item.get"}, [][2]string{{"item.fetch", "item.get"}}, "", "item.fetch", "item.get", false},
+		{"chain", false, []string{"item.get"}, [][2]string{{"b", "item.get"}, {"a", "b"}}, "", "a", "item.get", false},
+		{"cycle", false, nil, [][2]string{{"a", "b"}, {"b", "a"}}, "", "", "", true},
+		{"self", false, nil, [][2]string{{"a", "a"}}, "", "", "", true},
+		{"folded cycle", true, nil, [][2]string{{"A", "b"}, {"B", "a"}}, "", "", "", true},
+		{"folded alias", true, []string{"item.get"}, [][2]string{{"Item.Fetch", "item.get"}}, "", "ITEM.FETCH", "item.get", false},
+		{"unregister repoints", true, []string{"Foo", "foo"}, nil, "Foo", "FOO", "foo", false},
+		{"unregister keeps alias", true, []string{"item.get"}, [][2]string{{"Item.Fetch", "item.get"}}, "Item.Fetch", "item.fetch", "item.get", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := new(Server)
+			for _, method := range tt.methods {
+				if err := s.Register(method, func(ctx context.Context) error { return nil }); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s.CaseInsensitiveMethods = tt.caseInsensitive
+			for i, alias := range tt.aliases {
+				err := s.Alias(alias[0], alias[1])
+				if last := i == len(tt.aliases)-1; last && tt.wantErr {
+					if err == nil {
+						t.Fatalf("Alias(%q, %q) succeeded, want error", alias[0], alias[1])
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.unregister != "" {
+				s.Unregister(tt.unregister)
+			}
+			if got := s.resolve(tt.call); got != tt.want {
+				t.Errorf("resolve(%q) = %q, want %q", tt.call, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveHops(t *testing.T) {
+	// A loop through case folding must not hang resolution.
+	reg := &registry{
+		aliases: map[string]string{"A": "b", "B": "a"},
+		folded:  map[string]string{"a": "A", "b": "B"},
+	}
+	reg.resolve("a", true)
+}
+
+func TestFoldedPatterns(t *testing.T) {
+	tests := []struct {
+		name            string
+		caseInsensitive bool
+		call            string
+		wantCode        ErrorCode
+		wantMiddleware  bool
+	}{
+		{"exact case", false, "dl.abc", 0, true},
+		{"other case", false, "DL.abc", E_NO_METHOD, false},
+		{"folded exact case", true, "dl.abc", 0, true},
+		{"folded other case", true, "DL.abc", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{CaseInsensitiveMethods: tt.caseInsensitive}
+			if err := s.RegisterPattern("dl.*", func(ctx context.Context) (string, error) {
+				return MethodFromContext(ctx), nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			var middleware bool
+			s.UseFor("dl.*", func(next CallHandler) CallHandler {
+				return func(r *http.Request, method string, args, reply interface{}) error {
+					middleware = true
+					return next(r, method, args, reply)
+				}
+			})
+			resp, err := s.Dispatcher().HandleRequest(context.Background(), &Request{Version: Version, Method: tt.call, ID: json.RawMessage("1")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var code ErrorCode
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			if code != tt.wantCode || middleware != tt.wantMiddleware {
+				t.Errorf("code = %d, middleware %v, want %d, %v", code, middleware, tt.wantCode, tt.wantMiddleware)
+			}
+			err = s.RegisterPattern("DL.*", func(ctx context.Context) error { return nil })
+			if (err != nil) != tt.caseInsensitive {
+				t.Errorf("registering DL.* = %v, want error %v", err, tt.caseInsensitive)
+			}
+		})
+	}
+}
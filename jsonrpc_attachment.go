@@ -0,0 +1,244 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxAttachmentSize limits the total size of the attachments of a
+// request unless Server.MaxAttachmentSize is set.
+const DefaultMaxAttachmentSize = 32 << 20
+
+var ErrAttachmentTooLarge = errors.New("rpc: attachments too large")
+
+// Attachments are binary payloads sent next to a JSON-RPC message in a
+// multipart/related body instead of being base64 encoded inside it, keyed
+// by content id.
+type Attachments map[string][]byte
+
+// AttachmentRef references an attachment from params or results.
+type AttachmentRef struct {
+	ContentID string `json:"$attachment"`
+}
+
+type attachmentsKey struct{}
+
+// callAttachments holds the attachments of the request being served and
+// collects those of its response.
+type callAttachments struct {
+	sync.Mutex
+	request  Attachments
+	response Attachments
+}
+
+// RequestAttachments returns the attachments sent with the call served
+// under ctx.
+func RequestAttachments(ctx context.Context) Attachments {
+	if a, ok := ctx.Value(attachmentsKey{}).(*callAttachments); ok {
+		return a.request
+	}
+	return nil
+}
+
+// Attach adds data to the response of the call served under ctx and
+// returns the reference to put in the result. It fails if the client did not
+// send a multipart request and so cannot receive attachments.
+func Attach(ctx context.Context, data []byte) (ref AttachmentRef, err error) {
+	a, ok := ctx.Value(attachmentsKey{}).(*callAttachments)
+	if !ok {
+		err = errors.New("rpc: client does not accept attachments")
+		return
+	}
+	if ref.ContentID, err = newID(); err != nil {
+		return
+	}
+	a.Lock()
+	if a.response == nil {
+		a.response = make(Attachments)
+	}
+	a.response[ref.ContentID] = data
+	a.Unlock()
+	return
+}
+
+func isMultipartRelated(header http.Header) (boundary string, ok bool) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/related" {
+		return
+	}
+	boundary, ok = params["boundary"]
+	return
+}
+
+// readMultipart splits a multipart/related body into the JSON message, its
+// content type and the attachments that follow it.
+func readMultipart(body io.Reader, boundary string, limit int64) (message []byte, contentType string, attachments Attachments, err error) {
+	reader := multipart.NewReader(body, boundary)
+	attachments = make(Attachments)
+	var total int64
+	for first := true; ; first = false {
+		var part *multipart.Part
+		if part, err = reader.NextPart(); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			return
+		}
+		var data []byte
+		data, err = ioutil.ReadAll(io.LimitReader(part, limit-total+1))
+		part.Close()
+		if err != nil {
+			return
+		}
+		if total += int64(len(data)); total > limit {
+			err = ErrAttachmentTooLarge
+			return
+		}
+		if first {
+			message, contentType = data, part.Header.Get("Content-Type")
+			continue
+		}
+		id := strings.Trim(part.Header.Get("Content-ID"), "<>")
+		attachments[id] = data
+	}
+	if message == nil {
+		err = errors.New("rpc: empty multipart message")
+	}
+	return
+}
+
+// writeMultipart writes message followed by attachments as a
+// multipart/related body, returning its content type.
+func writeMultipart(w io.Writer, message []byte, contentType string, attachments Attachments) (multipartType string, err error) {
+	writer := multipart.NewWriter(w)
+	multipartType = fmt.Sprintf("multipart/related; type=%q; boundary=%s", "application/json", writer.Boundary())
+
+	var part io.Writer
+	if part, err = writer.CreatePart(textproto.MIMEHeader{"Content-Type": {contentType}}); err != nil {
+		return
+	}
+	if _, err = part.Write(message); err != nil {
+		return
+	}
+
+	ids := make([]string, 0, len(attachments))
+	for id := range attachments {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if part, err = writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"application/octet-stream"},
+			"Content-Id":   {"<" + id + ">"},
+		}); err != nil {
+			return
+		}
+		if _, err = part.Write(attachments[id]); err != nil {
+			return
+		}
+	}
+	err = writer.Close()
+	return
+}
+
+// serveMultipart serves a multipart/related request, answering with a
+// multipart/related response if the handler attached any data.
+func (s *Server) serveMultipart(w http.ResponseWriter, r *http.Request, boundary string) {
+	limit := s.MaxAttachmentSize
+	if limit <= 0 {
+		limit = DefaultMaxAttachmentSize
+	}
+	message, contentType, attachments, err := readMultipart(r.Body, boundary, limit)
+	r.Body.Close()
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == ErrAttachmentTooLarge {
+			status = http.StatusRequestEntityTooLarge
+		}
+		WriteError(w, status, err.Error())
+		return
+	}
+
+	a := &callAttachments{request: attachments}
+	r = r.WithContext(context.WithValue(r.Context(), attachmentsKey{}, a))
+	r.Header = r.Header.Clone()
+	r.Header.Set("Content-Type", contentType)
+	r.Body = ioutil.NopCloser(bytes.NewReader(message))
+	r.ContentLength = int64(len(message))
+
+	buf := &bufferResponseWriter{header: w.Header()}
+	s.serve(buf, r)
+
+	if len(a.response) == 0 {
+		if buf.status != 0 {
+			w.WriteHeader(buf.status)
+		}
+		w.Write(buf.body.Bytes())
+		return
+	}
+
+	var body bytes.Buffer
+	multipartType, err := writeMultipart(&body, buf.body.Bytes(), w.Header().Get("Content-Type"), a.response)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", multipartType)
+	w.Write(body.Bytes())
+}
+
+// CallWithAttachments is like Call but sends attachments next to the
+// request in a multipart/related body, returning the attachments of the
+// response.
+func (client *Client) CallWithAttachments(ctx context.Context, url, method string, params, reply interface{}, attachments Attachments) (replyAttachments Attachments, err error) {
+	client.init()
+
+	ctx, cancel, policy := client.callOptions(method).apply(ctx, client)
+	defer cancel()
+
+	var idSession IDSession
+	if idSession, err = client.IDStore.New(); err != nil {
+		return
+	}
+
+	defer checkClose(&err, idSession)
+
+	var message []byte
+	if message, err = client.encodeCall(idSession.ID(), url, method, params); err != nil {
+		return
+	}
+
+	var body bytes.Buffer
+	var contentType string
+	if contentType, err = writeMultipart(&body, message, "application/json", attachments); err != nil {
+		return
+	}
+
+	var resp *http.Response
+	if resp, err = client.send(ctx, url, method, body.Bytes(), http.Header{"Content-Type": {contentType}}, policy); err != nil {
+		return
+	}
+
+	defer checkClose(&err, resp.Body)
+	boundary, ok := isMultipartRelated(resp.Header)
+	if !ok {
+		err = decodeReply(resp.Body, reply, client.ParseMode)
+		return
+	}
+	if message, _, replyAttachments, err = readMultipart(resp.Body, boundary, 1<<62); err != nil {
+		return
+	}
+	err = decodeReply(bytes.NewReader(message), reply, client.ParseMode)
+	return
+}
@@ -0,0 +1,97 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// BaggageHeader is the W3C baggage header used to carry Baggage between
+// client and server.
+const BaggageHeader = "Baggage"
+
+// Baggage is a set of key-value pairs, such as a tenant or debug flag, that
+// follow a request across RPC hops. The client sends the baggage of the
+// call context, and the server makes the received baggage available to
+// handlers through BaggageFromContext, so calls made with the handler's
+// request context pass it on.
+type Baggage map[string]string
+
+type baggageKey struct{}
+
+// WithBaggage returns a copy of ctx carrying the baggage of ctx merged with
+// b, entries of b taking precedence.
+func WithBaggage(ctx context.Context, b Baggage) context.Context {
+	merged := make(Baggage)
+	for key, value := range BaggageFromContext(ctx) {
+		merged[key] = value
+	}
+	for key, value := range b {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, baggageKey{}, merged)
+}
+
+// BaggageFromContext returns the baggage carried by ctx. The result must
+// not be modified; use WithBaggage to add entries.
+func BaggageFromContext(ctx context.Context) Baggage {
+	b, _ := ctx.Value(baggageKey{}).(Baggage)
+	return b
+}
+
+// String encodes b in the W3C baggage header format.
+func (b Baggage) String() string {
+	members := make([]string, 0, len(b))
+	for key, value := range b {
+		members = append(members, url.PathEscape(key)+"="+url.PathEscape(value))
+	}
+	sort.Strings(members)
+	return strings.Join(members, ",")
+}
+
+// ParseBaggage decodes a W3C baggage header. Member properties are
+// ignored, as are malformed members.
+func ParseBaggage(header string) Baggage {
+	b := make(Baggage)
+	for _, member := range strings.Split(header, ",") {
+		if i := strings.IndexByte(member, ';'); i >= 0 {
+			member = member[:i]
+		}
+		i := strings.IndexByte(member, '=')
+		if i < 0 {
+			continue
+		}
+		key, err := url.PathUnescape(strings.TrimSpace(member[:i]))
+		if err != nil || key == "" {
+			continue
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(member[i+1:]))
+		if err != nil {
+			continue
+		}
+		b[key] = value
+	}
+	return b
+}
+
+// setBaggageHeader sends the baggage of ctx with req.
+func setBaggageHeader(ctx context.Context, req *http.Request) {
+	if b := BaggageFromContext(ctx); len(b) != 0 {
+		req.Header.Set(BaggageHeader, b.String())
+	}
+}
+
+// withBaggage adds the baggage received with r to its context.
+func withBaggage(r *http.Request) *http.Request {
+	header := r.Header.Values(BaggageHeader)
+	if len(header) == 0 {
+		return r
+	}
+	b := ParseBaggage(strings.Join(header, ","))
+	if len(b) == 0 {
+		return r
+	}
+	return r.WithContext(WithBaggage(r.Context(), b))
+}
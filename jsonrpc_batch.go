@@ -0,0 +1,126 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// DefaultMaxBatchSize is the number of calls a batch may hold unless
+// Server.MaxBatchSize says otherwise.
+const DefaultMaxBatchSize = 100
+
+// isBatch reports whether the body of r is a JSON array, leaving the body
+// unread.
+func isBatch(r *http.Request) bool {
+	br := bufio.NewReader(r.Body)
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{br, r.Body}
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return false
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+		default:
+			return b[0] == '['
+		}
+	}
+}
+
+// serveBatch serves a batch request: it runs the calls concurrently, except
+// those of methods registered WithOrdered, which run one at a time in batch
+// order, and writes the array of their responses. Notifications have no
+// response; a batch of notifications only is answered with 204 No Content.
+func (s *Server) serveBatch(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	var calls []json.RawMessage
+	if err == nil {
+		err = json.Unmarshal(body, &calls)
+	}
+	maxSize := s.MaxBatchSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxBatchSize
+	}
+	switch {
+	case err != nil:
+		err = &Error{Code: E_PARSE, Message: err.Error()}
+	case len(calls) == 0:
+		err = &Error{Code: E_INVALID_REQ, Message: "rpc: empty batch"}
+	case len(calls) > maxSize:
+		err = &Error{Code: E_INVALID_REQ, Message: fmt.Sprintf("rpc: batch of %d calls exceeds the limit of %d", len(calls), maxSize)}
+	}
+	if err != nil {
+		s.stats.request()
+		s.stats.fail(methodStage(err), err)
+		(&CodecRequest{request: &serverRequest{}}).WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	responses := make([][]byte, len(calls))
+	var wg sync.WaitGroup
+	var last chan struct{} // done when the last ordered call is
+	for i, call := range calls {
+		wg.Add(1)
+		prev, done := last, make(chan struct{})
+		if s.isOrderedCall(call) {
+			last = done
+		} else {
+			prev = nil
+		}
+		go func(i int, call json.RawMessage) {
+			defer wg.Done()
+			defer close(done)
+			if prev != nil {
+				<-prev
+			}
+			responses[i] = s.serveBatchCall(r, call)
+		}(i, call)
+	}
+	wg.Wait()
+
+	var buf bytes.Buffer
+	for _, resp := range responses {
+		if len(resp) == 0 {
+			continue
+		}
+		if buf.Len() == 0 {
+			buf.WriteByte('[')
+		} else {
+			buf.WriteByte(',')
+		}
+		buf.Write(resp)
+	}
+	if buf.Len() == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	buf.WriteString("]\n")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// serveBatchCall serves one call of a batch and returns its response, which
+// is empty for a notification.
+func (s *Server) serveBatchCall(r *http.Request, call json.RawMessage) []byte {
+	sub := r.Clone(r.Context())
+	// Conditional and delta responses apply to single calls only.
+	sub.Header.Del("If-None-Match")
+	sub.Header.Del(DeltaBaseHeader)
+	sub.Body = ioutil.NopCloser(bytes.NewReader(call))
+	sub.ContentLength = int64(len(call))
+
+	w := &bufferResponseWriter{header: make(http.Header)}
+	s.serve(w, sub)
+	return bytes.TrimSpace(w.body.Bytes())
+}
@@ -0,0 +1,125 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeBatch(t *testing.T) {
+	s := new(Server)
+	if err := s.Register("item.get", func(r *http.Request, args *CacheArgs, reply *int) error {
+		*reply = args.N
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantArray  bool
+		wantIDs    []string
+		wantCodes  []ErrorCode
+	}{
+		{"mixed", `[{"jsonrpc":"2.0","id":1,"method":"item.get","params":{"n":1}},{"jsonrpc":"2.0","id":"b","method":"item.put"},{"jsonrpc":"2.0","method":"item.get"},{"jsonrpc":"2.0","id":3,"method":"item.get","params":{"n":"x"}}]`,
+			http.StatusOK, true, []string{`1`, `"b"`, `3`}, []ErrorCode{0, E_NO_METHOD, E_BAD_PARAMS}},
+		{"notifications only", `[{"jsonrpc":"2.0","method":"item.get"},{"jsonrpc":"2.0","method":"item.put"}]`,
+			http.StatusNoContent, false, nil, nil},
+		{"empty", `[]`, http.StatusOK, false, []string{`null`}, []ErrorCode{E_INVALID_REQ}},
+		{"not requests", `[1,2]`, http.StatusOK, true, []string{`null`, `null`}, []ErrorCode{E_INVALID_REQ, E_INVALID_REQ}},
+		{"malformed", `[{"jsonrpc":"2.0"`, http.StatusOK, false, []string{`null`}, []ErrorCode{E_PARSE}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusNoContent {
+				if w.Body.Len() != 0 {
+					t.Errorf("body = %q, want none", w.Body)
+				}
+				return
+			}
+			body := strings.TrimSpace(w.Body.String())
+			if strings.HasPrefix(body, "[") != tt.wantArray {
+				t.Fatalf("body = %s, want array %v", body, tt.wantArray)
+			}
+			var responses []Response
+			if !tt.wantArray {
+				responses = make([]Response, 1)
+				if err := json.Unmarshal([]byte(body), &responses[0]); err != nil {
+					t.Fatalf("%v: %q", err, body)
+				}
+			} else if err := json.Unmarshal([]byte(body), &responses); err != nil {
+				t.Fatalf("%v: %q", err, body)
+			}
+			if len(responses) != len(tt.wantIDs) {
+				t.Fatalf("%d responses, want %d: %s", len(responses), len(tt.wantIDs), w.Body)
+			}
+			for i, resp := range responses {
+				var code ErrorCode
+				if resp.Error != nil {
+					code = resp.Error.Code
+				}
+				if string(resp.ID) != tt.wantIDs[i] || code != tt.wantCodes[i] {
+					t.Errorf("response %d has id %s code %d, want id %s code %d", i, resp.ID, code, tt.wantIDs[i], tt.wantCodes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestServeBatchConcurrent(t *testing.T) {
+	const size = 4
+	// Each call waits for the next one to finish, so the calls only complete
+	// if they run concurrently, and in reverse order.
+	var finished [size + 1]chan struct{}
+	for i := range finished {
+		finished[i] = make(chan struct{})
+	}
+	close(finished[size])
+	s := new(Server)
+	if err := s.Register("item.get", func(r *http.Request, args *CacheArgs, reply *int) error {
+		select {
+		case <-finished[args.N+1]:
+		case <-time.After(5 * time.Second):
+			return fmt.Errorf("call %d ran alone", args.N)
+		}
+		*reply = args.N
+		close(finished[args.N])
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := make([]string, size)
+	for i := range calls {
+		calls[i] = fmt.Sprintf(`{"jsonrpc":"2.0","id":"call-%d","method":"item.get","params":{"n":%d}}`, i, i)
+	}
+	r := httptest.NewRequest("POST", "/", strings.NewReader("["+strings.Join(calls, ",")+"]"))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	var responses []Response
+	if err := json.Unmarshal(w.Body.Bytes(), &responses); err != nil {
+		t.Fatalf("%v: %q", err, w.Body)
+	}
+	if len(responses) != size {
+		t.Fatalf("%d responses, want %d", len(responses), size)
+	}
+	for i, resp := range responses {
+		if want := fmt.Sprintf(`"call-%d"`, i); string(resp.ID) != want || string(resp.Result) != fmt.Sprint(i) {
+			t.Errorf("response %d = id %s result %s error %v, want id %s result %d", i, resp.ID, resp.Result, resp.Error, want, i)
+		}
+	}
+}
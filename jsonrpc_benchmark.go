@@ -0,0 +1,42 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// SleepArgs are the params of system.sleep.
+type SleepArgs struct {
+	// Milliseconds is how long the call sleeps before replying.
+	Milliseconds int64 `json:"ms"`
+}
+
+// EnableBenchmarkMethods registers system.echo, which replies with its
+// params unchanged, and system.sleep, which replies after a delay. They
+// exercise only the transport and codec, for benchmarking a deployment.
+func (s *Server) EnableBenchmarkMethods() (err error) {
+	if err = s.Register("system.echo", echo,
+		WithSummary("Replies with the params unchanged.")); err != nil {
+		return
+	}
+	err = s.Register("system.sleep", sleep,
+		WithSummary("Replies with an empty object after sleeping for the given number of milliseconds."))
+	return
+}
+
+func echo(r *http.Request, args *json.RawMessage, reply *json.RawMessage) error {
+	*reply = *args
+	return nil
+}
+
+func sleep(r *http.Request, args *SleepArgs, reply *struct{}) error {
+	timer := time.NewTimer(time.Duration(args.Milliseconds) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-r.Context().Done():
+		return r.Context().Err()
+	}
+}
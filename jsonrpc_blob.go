@@ -0,0 +1,634 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxBlobSize is the default of Server.MaxBlobSize.
+const DefaultMaxBlobSize = 64 << 20
+
+// ErrBlobTooLarge is the error of a []byte or io.Reader value exceeding its
+// size limit.
+var ErrBlobTooLarge = errors.New("rpc: base64 value too large")
+
+// errBlobSyntax is the error of a malformed message read while streaming.
+var errBlobSyntax = errors.New("rpc: malformed JSON")
+
+var (
+	typeOfBytes  = reflect.TypeOf([]byte(nil))
+	typeOfReader = reflect.TypeOf((*io.Reader)(nil)).Elem()
+)
+
+// blobField is a []byte or io.Reader field of an args or reply struct,
+// streamed as a base64 string.
+type blobField struct {
+	name   string // JSON member name
+	index  int
+	reader bool // whether the field is an io.Reader
+}
+
+// blobFieldsOf returns the []byte and io.Reader fields of the struct t is
+// or points to.
+func blobFieldsOf(t reflect.Type) (fields []blobField) {
+	if t == nil {
+		return nil
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Anonymous || f.Type != typeOfBytes && f.Type != typeOfReader {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("json"); tag == "-" {
+			continue
+		} else if tag = strings.Split(tag, ",")[0]; tag != "" {
+			name = tag
+		}
+		fields = append(fields, blobField{name: name, index: i, reader: f.Type == typeOfReader})
+	}
+	return
+}
+
+// lookupBlob returns the field decoded from the member name, matching
+// names like encoding/json does.
+func lookupBlob(fields []blobField, name string) *blobField {
+	for i := range fields {
+		if fields[i].name == name {
+			return &fields[i]
+		}
+	}
+	for i := range fields {
+		if strings.EqualFold(fields[i].name, name) {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+// streamsParams reports whether any of methods takes params to stream.
+func streamsParams(methods map[string]*methodSpec) bool {
+	for _, spec := range methods {
+		if spec.argBlobs != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// maxBlobSize returns the limit of streamed params.
+func (s *Server) maxBlobSize() int64 {
+	if s.MaxBlobSize > 0 {
+		return s.MaxBlobSize
+	}
+	return DefaultMaxBlobSize
+}
+
+// readBlobs streams the []byte and io.Reader params of a JSON request out
+// of its body, which is replaced by the rest of the request. Params are
+// only streamed if the method precedes them in the request, as it does in
+// requests sent by Client, and not for calls run as jobs or queued, which
+// outlive the request.
+func (s *Server) readBlobs(r *http.Request) (b *blobs, err error) {
+	if !s.routing().blobs {
+		return
+	}
+	if _, ok := s.codec(r).(jsonCodec); !ok {
+		return
+	}
+	var reduced []byte
+	reduced, b, err = readEnvelope(r.Body, "params", func(members map[string]json.RawMessage) []blobField {
+		var method string
+		if json.Unmarshal(members["method"], &method) != nil {
+			return nil
+		}
+		spec, err := s.get(s.routing().version(s.resolve(method), r.Header))
+		if err != nil || spec.async || spec.durable {
+			return nil
+		}
+		return spec.argBlobs
+	}, s.maxBlobSize())
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(reduced))
+
+	var corrupt base64.CorruptInputError
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrBlobTooLarge) || errors.As(err, &corrupt):
+		err = &Error{Code: E_BAD_PARAMS, Message: err.Error()}
+	default:
+		err = &Error{Code: E_PARSE, Message: err.Error()}
+	}
+	return
+}
+
+// ----------------------------------------------------------------------------
+// Decoding
+// ----------------------------------------------------------------------------
+
+// blobs holds the values streamed out of a message: []byte values in
+// memory, io.Reader values in temporary files.
+type blobs struct {
+	values []blobValue
+}
+
+type blobValue struct {
+	field blobField
+	data  []byte
+	file  *blobFile
+}
+
+// blobFile is an io.Reader value spooled to a temporary file, which is
+// removed when it is closed.
+type blobFile struct {
+	*os.File
+}
+
+func (f *blobFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+// read decodes a base64 string into the value of field, failing if it
+// decodes to more than limit bytes, if positive.
+func (b *blobs) read(field *blobField, src io.Reader, limit int64) (err error) {
+	value := blobValue{field: *field}
+	var sink io.Writer
+	var buf bytes.Buffer
+	if field.reader {
+		var file *os.File
+		if file, err = os.CreateTemp("", "jsonrpc-blob-*"); err != nil {
+			return
+		}
+		value.file = &blobFile{file}
+		b.values = append(b.values, value)
+		sink = file
+	} else {
+		sink = &buf
+	}
+
+	dec := base64.NewDecoder(base64.StdEncoding, src)
+	if limit > 0 {
+		dec = io.LimitReader(dec, limit+1)
+	}
+	var n int64
+	if n, err = io.Copy(sink, dec); err != nil {
+		return
+	}
+	if limit > 0 && n > limit {
+		return fmt.Errorf("%w: %q exceeds %d bytes", ErrBlobTooLarge, field.name, limit)
+	}
+	if field.reader {
+		_, err = value.file.Seek(0, io.SeekStart)
+	} else {
+		value.data = buf.Bytes()
+		b.values = append(b.values, value)
+	}
+	return
+}
+
+// fill sets the fields of the struct v points to to the streamed values.
+func (b *blobs) fill(v interface{}) {
+	if b == nil {
+		return
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return
+	}
+	rv = rv.Elem()
+	for _, value := range b.values {
+		if value.file != nil {
+			rv.Field(value.field.index).Set(reflect.ValueOf(io.Reader(value.file)))
+		} else {
+			rv.Field(value.field.index).SetBytes(value.data)
+		}
+	}
+}
+
+// close removes the temporary files.
+func (b *blobs) close() {
+	if b == nil {
+		return
+	}
+	for _, value := range b.values {
+		if value.file != nil {
+			value.file.Close()
+		}
+	}
+}
+
+// readEnvelope reads the JSON object of a request or response from r,
+// streaming the base64 strings of the fields of its member named member,
+// params or result, into blobs instead of holding them in memory. fieldsOf
+// is passed the members read before, e.g. to look up the method. The
+// object is returned with null in place of the streamed strings. On error,
+// it is returned with the members read before the failing one.
+func readEnvelope(r io.Reader, member string, fieldsOf func(members map[string]json.RawMessage) []blobField, limit int64) (reduced []byte, b *blobs, err error) {
+	sc := &blobScanner{bufio.NewReader(r)}
+	b = new(blobs)
+	members := make(map[string]json.RawMessage)
+	var out bytes.Buffer
+	out.WriteByte('{')
+	end := out.Len() // of the last complete member
+	err = sc.object(func(name string) (err error) {
+		if out.Len() > 1 {
+			out.WriteByte(',')
+		}
+		writeKey(&out, name)
+		start := out.Len()
+		var fields []blobField
+		if name == member {
+			fields = fieldsOf(members)
+		}
+		if c, _ := sc.peek(); fields != nil && c == '{' {
+			err = sc.streamObject(&out, fields, limit, b)
+		} else {
+			err = sc.capture(&out)
+		}
+		if err == nil {
+			members[name] = append(json.RawMessage(nil), out.Bytes()[start:]...)
+			end = out.Len()
+		}
+		return
+	})
+	if err != nil {
+		out.Truncate(end)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), b, err
+}
+
+func writeKey(out *bytes.Buffer, name string) {
+	key, _ := json.Marshal(name)
+	out.Write(key)
+	out.WriteByte(':')
+}
+
+// blobScanner reads JSON values one at a time, without buffering strings
+// that are streamed.
+type blobScanner struct {
+	r *bufio.Reader
+}
+
+// next returns the next byte that is not white space.
+func (sc *blobScanner) next() (c byte, err error) {
+	for {
+		if c, err = sc.r.ReadByte(); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next byte that is not white space without consuming it.
+func (sc *blobScanner) peek() (c byte, err error) {
+	if c, err = sc.next(); err == nil {
+		sc.r.UnreadByte()
+	}
+	return
+}
+
+// expect consumes the byte want.
+func (sc *blobScanner) expect(want byte) error {
+	c, err := sc.next()
+	if err == nil && c != want {
+		err = fmt.Errorf("%w: expected %q, found %q", errBlobSyntax, want, c)
+	}
+	return err
+}
+
+// object reads an object, calling member to read the value of each member.
+func (sc *blobScanner) object(member func(name string) error) (err error) {
+	if err = sc.expect('{'); err != nil {
+		return
+	}
+	if c, _ := sc.peek(); c == '}' {
+		sc.r.ReadByte()
+		return
+	}
+	for {
+		var key bytes.Buffer
+		var name string
+		if err = sc.capture(&key); err != nil {
+			return
+		}
+		if json.Unmarshal(key.Bytes(), &name) != nil {
+			return fmt.Errorf("%w: invalid member name %s", errBlobSyntax, key.Bytes())
+		}
+		if err = sc.expect(':'); err != nil {
+			return
+		}
+		if err = member(name); err != nil {
+			return
+		}
+		var c byte
+		if c, err = sc.next(); err != nil {
+			return
+		}
+		switch c {
+		case '}':
+			return
+		case ',':
+		default:
+			return fmt.Errorf("%w: expected ',' or '}', found %q", errBlobSyntax, c)
+		}
+	}
+}
+
+// streamObject reads an object into out, streaming the strings of fields
+// into b and writing null in their place.
+func (sc *blobScanner) streamObject(out *bytes.Buffer, fields []blobField, limit int64, b *blobs) error {
+	out.WriteByte('{')
+	first := true
+	err := sc.object(func(name string) error {
+		if !first {
+			out.WriteByte(',')
+		}
+		first = false
+		writeKey(out, name)
+		if field := lookupBlob(fields, name); field != nil {
+			if c, _ := sc.peek(); c == '"' {
+				sc.r.ReadByte()
+				out.WriteString("null")
+				src := &stringReader{r: sc.r}
+				if err := b.read(field, src, limit); err != nil {
+					return err
+				}
+				if !src.done {
+					return fmt.Errorf("%w: trailing data in %q", errBlobSyntax, name)
+				}
+				return nil
+			}
+		}
+		return sc.capture(out)
+	})
+	out.WriteByte('}')
+	return err
+}
+
+// capture copies the next value to out. It only finds where the value
+// ends; the value is checked when out is decoded.
+func (sc *blobScanner) capture(out *bytes.Buffer) (err error) {
+	var c byte
+	if c, err = sc.next(); err != nil {
+		return
+	}
+	out.WriteByte(c)
+	switch c {
+	case '"':
+		return sc.copyString(out)
+	case '{', '[':
+		for depth := 1; depth > 0; {
+			if c, err = sc.next(); err != nil {
+				return
+			}
+			out.WriteByte(c)
+			switch c {
+			case '"':
+				if err = sc.copyString(out); err != nil {
+					return
+				}
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		return
+	}
+	for {
+		if c, err = sc.r.ReadByte(); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		switch c {
+		case ',', '}', ']', ' ', '\t', '\r', '\n':
+			return sc.r.UnreadByte()
+		}
+		out.WriteByte(c)
+	}
+}
+
+// copyString copies the rest of a string, escapes included, to out.
+func (sc *blobScanner) copyString(out *bytes.Buffer) error {
+	for escaped := false; ; {
+		c, err := sc.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		out.WriteByte(c)
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			return nil
+		}
+	}
+}
+
+// stringReader reads the unescaped rest of a string.
+type stringReader struct {
+	r       *bufio.Reader
+	pending []byte // of an escape
+	done    bool   // whether the closing quote was read
+}
+
+func (s *stringReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if len(s.pending) != 0 {
+			k := copy(p[n:], s.pending)
+			s.pending = s.pending[k:]
+			n += k
+			continue
+		}
+		if s.done {
+			break
+		}
+		var c byte
+		if c, err = s.r.ReadByte(); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+		switch c {
+		case '"':
+			s.done = true
+		case '\\':
+			if s.pending, err = s.escape(); err != nil {
+				return
+			}
+		default:
+			p[n] = c
+			n++
+		}
+	}
+	if n == 0 && s.done {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// escape returns the bytes an escape sequence stands for.
+func (s *stringReader) escape() ([]byte, error) {
+	c, err := s.r.ReadByte()
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	switch c {
+	case '"', '\\', '/':
+		return []byte{c}, nil
+	case 'b':
+		return []byte{'\b'}, nil
+	case 'f':
+		return []byte{'\f'}, nil
+	case 'n':
+		return []byte{'\n'}, nil
+	case 'r':
+		return []byte{'\r'}, nil
+	case 't':
+		return []byte{'\t'}, nil
+	case 'u':
+		var hex [4]byte
+		if _, err = io.ReadFull(s.r, hex[:]); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		code, err := strconv.ParseUint(string(hex[:]), 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid escape \\u%s", errBlobSyntax, hex[:])
+		}
+		buf := make([]byte, utf8.UTFMax)
+		return buf[:utf8.EncodeRune(buf, rune(code))], nil
+	}
+	return nil, fmt.Errorf("%w: invalid escape \\%c", errBlobSyntax, c)
+}
+
+// ----------------------------------------------------------------------------
+// Encoding
+// ----------------------------------------------------------------------------
+
+// blobMarker stands in for an io.Reader value while the message around it
+// is encoded.
+type blobMarker string
+
+func (blobMarker) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (m blobMarker) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + m + `"`), nil
+}
+
+// substituteBlobs returns a copy of v, a struct or a pointer to one, whose
+// non-nil []byte and io.Reader fields are replaced by unique markers, each
+// encoded as a base64 string, along with the values the markers stand for.
+// streams is nil if there is nothing to stream.
+func substituteBlobs(v interface{}, fields []blobField) (substituted interface{}, streams map[string]io.Reader, err error) {
+	rv := reflect.ValueOf(v)
+	ptr := rv.Kind() == reflect.Ptr
+	if ptr {
+		if rv.IsNil() {
+			return v, nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return v, nil, nil
+	}
+	copied := reflect.New(rv.Type()).Elem()
+	copied.Set(rv)
+	for _, field := range fields {
+		fv := copied.Field(field.index)
+		if fv.IsNil() {
+			continue
+		}
+		marker := make([]byte, 12)
+		if _, err = rand.Read(marker); err != nil {
+			return
+		}
+		encoded := base64.StdEncoding.EncodeToString(marker)
+		if streams == nil {
+			streams = make(map[string]io.Reader)
+		}
+		if field.reader {
+			streams[encoded] = fv.Interface().(io.Reader)
+			fv.Set(reflect.ValueOf(blobMarker(encoded)))
+		} else {
+			streams[encoded] = bytes.NewReader(fv.Bytes())
+			fv.SetBytes(marker)
+		}
+	}
+	if ptr {
+		return copied.Addr().Interface(), streams, nil
+	}
+	return copied.Interface(), streams, nil
+}
+
+// writeBlobs writes data, an encoded message, to w, streaming the values
+// in place of the markers standing for them as base64.
+func writeBlobs(w io.Writer, data []byte, streams map[string]io.Reader) (err error) {
+	type splice struct {
+		at     int
+		marker string
+	}
+	var splices []splice
+	for marker := range streams {
+		if at := bytes.Index(data, []byte(`"`+marker+`"`)); at != -1 {
+			splices = append(splices, splice{at + 1, marker})
+		}
+	}
+	sort.Slice(splices, func(i, j int) bool { return splices[i].at < splices[j].at })
+
+	written := 0
+	for _, s := range splices {
+		if _, err = w.Write(data[written:s.at]); err != nil {
+			return
+		}
+		enc := base64.NewEncoder(base64.StdEncoding, w)
+		if _, err = io.Copy(enc, streams[s.marker]); err != nil {
+			return
+		}
+		if err = enc.Close(); err != nil {
+			return
+		}
+		written = s.at + len(s.marker)
+	}
+	_, err = w.Write(data[written:])
+	return
+}
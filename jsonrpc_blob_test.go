@@ -0,0 +1,150 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type BlobArgs struct {
+	Name string    `json:"name"`
+	Data []byte    `json:"data"`
+	Body io.Reader `json:"body,omitempty"`
+}
+
+type BlobReply struct {
+	Name string    `json:"name"`
+	Data []byte    `json:"data"`
+	Body io.Reader `json:"body"`
+}
+
+func newBlobServer(t *testing.T, maxBlobSize int64) *httptest.Server {
+	s := &Server{MaxBlobSize: maxBlobSize}
+	if err := s.Register("blob.echo", func(r *http.Request, args *BlobArgs, reply *BlobReply) error {
+		reply.Name, reply.Data = args.Name, args.Data
+		if args.Body != nil {
+			body, err := ioutil.ReadAll(args.Body)
+			if err != nil {
+				return err
+			}
+			reply.Body = bytes.NewReader(body)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestStreamBlobs(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+	tests := []struct {
+		name        string
+		stream      bool // whether the client streams
+		maxBlobSize int64
+		data        []byte
+		body        []byte
+		wantCode    ErrorCode
+	}{
+		{"small", true, 0, []byte("hello"), []byte("world"), 0},
+		{"large", true, 0, large, large, 0},
+		{"empty", true, 0, []byte{}, nil, 0},
+		{"nil", true, 0, nil, nil, 0},
+		{"unstreamed client", false, 0, large, nil, 0},
+		{"data too large", true, 1024, large, nil, E_BAD_PARAMS},
+		{"body too large", true, 1024, nil, large, E_BAD_PARAMS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newBlobServer(t, tt.maxBlobSize)
+			client := &Client{StreamBlobs: tt.stream}
+			args := &BlobArgs{Name: tt.name, Data: tt.data}
+			if tt.body != nil {
+				args.Body = bytes.NewReader(tt.body)
+			}
+			var reply BlobReply
+			err := client.Call(context.Background(), ts.URL, "blob.echo", args, &reply)
+			if tt.wantCode != 0 {
+				var rpcErr *Error
+				if !errors.As(err, &rpcErr) || rpcErr.Code != tt.wantCode {
+					t.Fatalf("err = %v, want code %d", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reply.Name != tt.name {
+				t.Errorf("name = %q, want %q", reply.Name, tt.name)
+			}
+			if !bytes.Equal(reply.Data, tt.data) {
+				t.Errorf("data = %d bytes, want %d", len(reply.Data), len(tt.data))
+			}
+			if !tt.stream {
+				return
+			}
+			if tt.body == nil {
+				if reply.Body != nil {
+					t.Errorf("body = %v, want nil", reply.Body)
+				}
+				return
+			}
+			if reply.Body == nil {
+				t.Fatal("body is nil")
+			}
+			got, _ := ioutil.ReadAll(reply.Body)
+			reply.Body.(io.Closer).Close()
+			if !bytes.Equal(got, tt.body) {
+				t.Errorf("body = %d bytes, want %d", len(got), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestReadEnvelope(t *testing.T) {
+	fields := []blobField{{name: "data", index: 1}}
+	tests := []struct {
+		name        string
+		in          string
+		wantReduced string
+		wantData    string
+		wantErr     bool
+	}{
+		{"streams field", `{"method":"m","params":{"name":"x","data":"aGVsbG8="},"id":1}`,
+			`{"method":"m","params":{"name":"x","data":null},"id":1}`, "hello", false},
+		{"escaped slash", `{"params":{"data":"aGk\/"}}`, `{"params":{"data":null}}`, "hi?", false},
+		{"keeps nested values", `{"params":{"n":[1,{"a":"}"}],"data":null}}`,
+			`{"params":{"n":[1,{"a":"}"}],"data":null}}`, "", false},
+		{"array params", `{"params":["aGVsbG8="]}`, `{"params":["aGVsbG8="]}`, "", false},
+		{"corrupt base64", `{"id":1,"params":{"data":"!!!!"}}`, `{"id":1}`, "", true},
+		{"truncated", `{"id":1,"params":{"data":"aGVs`, `{"id":1}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reduced, b, err := readEnvelope(strings.NewReader(tt.in), "params", func(map[string]json.RawMessage) []blobField {
+				return fields
+			}, 0)
+			defer b.close()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if string(reduced) != tt.wantReduced {
+				t.Errorf("reduced = %s, want %s", reduced, tt.wantReduced)
+			}
+			var args BlobArgs
+			b.fill(&args)
+			if string(args.Data) != tt.wantData {
+				t.Errorf("data = %q, want %q", args.Data, tt.wantData)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultResponseCacheSize is the number of results the in-memory
+// ResponseCache keeps.
+const DefaultResponseCacheSize = 1024
+
+// CacheEntry is a cached result.
+type CacheEntry struct {
+	Key     string          `json:"key"`
+	Result  json.RawMessage `json:"result"`
+	Expires time.Time       `json:"expires"`
+}
+
+// ResponseCache stores the results of methods registered WithCache.
+type ResponseCache interface {
+	// Get returns the entry stored under key, if it has not expired.
+	Get(key string) (entry CacheEntry, ok bool)
+	// Put stores an entry, replacing any under the same key.
+	Put(entry CacheEntry) error
+}
+
+// WithCache caches the results of the method for ttl in the Server's
+// ResponseCache, keyed by the method name and params. Cached results are
+// shared by all callers, so only use it for methods whose results do not
+// depend on the caller. Middleware runs for cached calls as well.
+func WithCache(ttl time.Duration) MethodOption {
+	return func(m *methodSpec) { m.cacheTTL = ttl }
+}
+
+func (s *Server) responseCache() ResponseCache {
+	s.cacheOnce.Do(func() {
+		s.Lock()
+		defer s.Unlock()
+		if s.cache = s.ResponseCache; s.cache == nil {
+			s.cache = &memoryCache{entries: make(map[string]CacheEntry), clock: s.Clock}
+		}
+	})
+	return s.cache
+}
+
+// cached wraps the handler of a method registered WithCache.
+func (s *Server) cached(ttl time.Duration, next CallHandler) CallHandler {
+	return func(r *http.Request, method string, args, reply interface{}) (err error) {
+		params, err := json.Marshal(args)
+		if err != nil {
+			return next(r, method, args, reply)
+		}
+		key := method + "\x00" + string(params)
+		cache := s.responseCache()
+		if entry, ok := cache.Get(key); ok && json.Unmarshal(entry.Result, reply) == nil {
+			return nil
+		}
+
+		if err = next(r, method, args, reply); err != nil {
+			return
+		}
+		if result, err := json.Marshal(reply); err == nil {
+			// A failure to cache does not fail the call.
+			cache.Put(CacheEntry{Key: key, Result: result, Expires: s.clock().Now().Add(ttl)})
+		}
+		return nil
+	}
+}
+
+// memoryCache is the default ResponseCache. It evicts the oldest entries
+// beyond DefaultResponseCacheSize.
+type memoryCache struct {
+	sync.Mutex
+	entries map[string]CacheEntry
+	order   []string
+	clock   Clock
+}
+
+func (c *memoryCache) Get(key string) (entry CacheEntry, ok bool) {
+	c.Lock()
+	defer c.Unlock()
+	if entry, ok = c.entries[key]; ok && clockOr(c.clock).Now().After(entry.Expires) {
+		c.remove(key)
+		return CacheEntry{}, false
+	}
+	return
+}
+
+func (c *memoryCache) Put(entry CacheEntry) error {
+	c.Lock()
+	defer c.Unlock()
+	c.put(entry)
+	return nil
+}
+
+func (c *memoryCache) put(entry CacheEntry) {
+	if _, ok := c.entries[entry.Key]; !ok {
+		c.order = append(c.order, entry.Key)
+	}
+	c.entries[entry.Key] = entry
+	for len(c.order) > DefaultResponseCacheSize {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// remove deletes the entry stored under key.
+func (c *memoryCache) remove(key string) {
+	delete(c.entries, key)
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// FileCache is a ResponseCache persisted to an append-only log file, so
+// that a restarted server starts with the results cached before. The log
+// is compacted, dropping expired and evicted entries, when it is opened
+// and once it holds more than twice as many records as the cache has
+// entries and more than DefaultResponseCacheSize.
+type FileCache struct {
+	memoryCache
+	path    string
+	file    *os.File
+	records int // in the log
+}
+
+// OpenFileCache opens or creates the cache log at path and loads the
+// entries that have not expired by clock, which also times their expiry
+// later. A nil clock is the system clock.
+func OpenFileCache(path string, clock Clock) (c *FileCache, err error) {
+	c = &FileCache{memoryCache: memoryCache{entries: make(map[string]CacheEntry), clock: clock}, path: path}
+
+	var file *os.File
+	if file, err = os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0o600); err != nil {
+		return nil, err
+	}
+	now := clockOr(clock).Now()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		var entry CacheEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			// A torn write at the end of the log.
+			continue
+		}
+		if entry.Expires.After(now) {
+			c.put(entry)
+		}
+	}
+	err = scanner.Err()
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.compact(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// compact rewrites the log with only the entries in the cache that have
+// not expired. c must be locked.
+func (c *FileCache) compact() (err error) {
+	tmp := c.path + ".tmp"
+	var file *os.File
+	if file, err = os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600); err != nil {
+		return
+	}
+	now := clockOr(c.clock).Now()
+	records := 0
+	for _, key := range c.order {
+		entry := c.entries[key]
+		if now.After(entry.Expires) {
+			continue
+		}
+		if err = writeEntry(file, entry); err != nil {
+			file.Close()
+			return
+		}
+		records++
+	}
+	if err = file.Sync(); err == nil {
+		err = os.Rename(tmp, c.path)
+	}
+	if err != nil {
+		file.Close()
+		return
+	}
+	if c.file != nil {
+		c.file.Close()
+	}
+	c.file, c.records = file, records
+	return nil
+}
+
+func writeEntry(file *os.File, entry CacheEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// Put stores the entry and appends it to the log. Entries are not synced
+// to disk, as losing the last of them in a crash only costs a cache miss.
+func (c *FileCache) Put(entry CacheEntry) error {
+	c.Lock()
+	defer c.Unlock()
+	c.put(entry)
+	if err := writeEntry(c.file, entry); err != nil {
+		return err
+	}
+	if c.records++; c.records > 2*len(c.entries) && c.records > DefaultResponseCacheSize {
+		return c.compact()
+	}
+	return nil
+}
+
+// Close closes the log file.
+func (c *FileCache) Close() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.file.Close()
+}
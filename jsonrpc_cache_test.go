@@ -0,0 +1,116 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// stoppedClock is a Clock whose time only moves when set.
+type stoppedClock struct {
+	systemClock
+	now time.Time
+}
+
+func (c *stoppedClock) Now() time.Time {
+	return c.now
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	clock := &stoppedClock{now: time.Unix(1000, 0)}
+	c := &memoryCache{entries: make(map[string]CacheEntry), clock: clock}
+	tests := []struct {
+		key       string
+		ttl       time.Duration
+		wantFound bool
+	}{
+		{"live", time.Hour, true},
+		{"expired", -time.Second, false},
+	}
+	for _, tt := range tests {
+		c.Put(CacheEntry{Key: tt.key, Result: json.RawMessage("1"), Expires: clock.now.Add(tt.ttl)})
+	}
+	for _, tt := range tests {
+		if _, ok := c.Get(tt.key); ok != tt.wantFound {
+			t.Errorf("Get(%q) found = %v, want %v", tt.key, ok, tt.wantFound)
+		}
+	}
+	if len(c.order) != len(c.entries) {
+		t.Errorf("order holds %d keys for %d entries", len(c.order), len(c.entries))
+	}
+}
+
+func TestFileCacheCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	c, err := OpenFileCache(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	expires := time.Now().Add(time.Hour)
+	tests := []struct {
+		name string
+		puts int
+		keys int
+	}{
+		{"overwrites", 10 * DefaultResponseCacheSize, 3},
+		{"evictions", 3 * DefaultResponseCacheSize, 3 * DefaultResponseCacheSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < tt.puts; i++ {
+				if err := c.Put(CacheEntry{Key: fmt.Sprint(i % tt.keys), Result: json.RawMessage("1"), Expires: expires}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if records := countLines(t, path); records > 2*DefaultResponseCacheSize+1 {
+				t.Errorf("log holds %d records, want at most %d", records, 2*DefaultResponseCacheSize+1)
+			}
+		})
+	}
+}
+
+func countLines(t *testing.T, path string) (n int) {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		n++
+	}
+	return
+}
+
+type CacheArgs struct {
+	N int `json:"n"`
+}
+
+func TestResponseCache(t *testing.T) {
+	s := new(Server)
+	calls := 0
+	if err := s.Register("item.count", func(r *http.Request, args *CacheArgs, reply *int) error {
+		calls++
+		*reply = args.N
+		return nil
+	}, WithCache(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	d := s.Dispatcher()
+	for i := 0; i < 3; i++ {
+		d.Handle(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"item.count","params":{"n":1}}`))
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+	if s.responseCache() != s.responseCache() {
+		t.Error("responseCache is not stable")
+	}
+}
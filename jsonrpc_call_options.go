@@ -0,0 +1,84 @@
+package jsonrpc
+
+import (
+	"context"
+	"path"
+	"time"
+)
+
+// CallOptions tune the calls of a method.
+type CallOptions struct {
+	// Timeout bounds each call, in addition to any deadline of its context.
+	Timeout time.Duration
+
+	// Retry overrides the client's RetryPolicy.
+	Retry *RetryPolicy
+
+	// Trace, if set, receives the phases of each call.
+	Trace *CallTrace
+
+	// Conditional makes Call cache the results of methods registered
+	// WithETag and serve them again when the server reports them unchanged.
+	Conditional bool
+
+	// CacheTTL, if positive, makes Call keep results in the same cache,
+	// tagged or not, and serve them without asking the server for that
+	// long.
+	CacheTTL time.Duration
+
+	// Priority is the priority of batched calls of the method whose
+	// BatchCall.Priority is zero.
+	Priority int
+}
+
+// SetMethodOptions sets the default options of calls to method, which is
+// either an exact name or a path.Match pattern such as "download.*". Exact
+// names take precedence over patterns, which are tried in the order set.
+func (client *Client) SetMethodOptions(method string, opts CallOptions) {
+	client.Lock()
+	defer client.Unlock()
+	if client.methodOptions == nil {
+		client.methodOptions = make(map[string]*CallOptions)
+	}
+	if _, ok := client.methodOptions[method]; !ok {
+		client.methodPatterns = append(client.methodPatterns, method)
+	}
+	client.methodOptions[method] = &opts
+}
+
+// callOptions returns the options that apply to calls of method.
+func (client *Client) callOptions(method string) *CallOptions {
+	client.Lock()
+	defer client.Unlock()
+	if opts, ok := client.methodOptions[method]; ok {
+		return opts
+	}
+	for _, pattern := range client.methodPatterns {
+		if ok, _ := path.Match(pattern, method); ok {
+			return client.methodOptions[pattern]
+		}
+	}
+	return &CallOptions{}
+}
+
+// retryPolicy returns the retry policy in effect under opts.
+func (opts *CallOptions) retryPolicy(client *Client) *RetryPolicy {
+	if opts.Retry != nil {
+		return opts.Retry
+	}
+	return client.Retry
+}
+
+// apply bounds ctx by the timeout, attaches the trace and resolves the
+// retry policy.
+func (opts *CallOptions) apply(ctx context.Context, client *Client) (context.Context, context.CancelFunc, *RetryPolicy) {
+	policy := opts.retryPolicy(client)
+	if opts.Trace != nil {
+		ctx = WithCallTrace(ctx, opts.Trace)
+	}
+	if opts.Timeout <= 0 {
+		return ctx, func() {}, policy
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	return ctx, cancel, policy
+}
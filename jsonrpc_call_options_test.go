@@ -0,0 +1,81 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCallOptionsCacheTTL(t *testing.T) {
+	var calls int
+	s := new(Server)
+	if err := s.Register("item.get", func(r *http.Request, args *CacheArgs, reply *int) error {
+		calls++
+		*reply = args.N * calls
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	clock := &stoppedClock{now: time.Unix(1000, 0)}
+	client := &Client{Clock: clock}
+	client.SetMethodOptions("item.*", CallOptions{CacheTTL: time.Minute})
+
+	tests := []struct {
+		name      string
+		advance   time.Duration
+		n         int
+		want      int
+		wantCalls int
+	}{
+		{"first", 0, 1, 1, 1},
+		{"cached", 30 * time.Second, 1, 1, 1},
+		{"other params", 0, 2, 4, 2},
+		{"expired", 31 * time.Second, 1, 3, 3},
+	}
+	for _, tt := range tests {
+		clock.now = clock.now.Add(tt.advance)
+		var reply int
+		if err := client.Call(context.Background(), ts.URL, "item.get", &CacheArgs{N: tt.n}, &reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply != tt.want || calls != tt.wantCalls {
+			t.Errorf("%s: reply = %d after %d calls, want %d after %d", tt.name, reply, calls, tt.want, tt.wantCalls)
+		}
+	}
+}
+
+func TestCallOptionsPriority(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	s := new(Server)
+	for _, method := range []string{"low", "urgent", "explicit"} {
+		method := method
+		if err := s.Register(method, func(ctx context.Context) error {
+			mu.Lock()
+			order = append(order, method)
+			mu.Unlock()
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	client := &Client{MaxBatchSize: 1}
+	client.SetMethodOptions("urgent", CallOptions{Priority: 5})
+	calls := []BatchCall{{Method: "low"}, {Method: "urgent"}, {Method: "explicit", Priority: 10}}
+	if err := client.Batch(context.Background(), ts.URL, calls); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"explicit", "urgent", "low"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
@@ -0,0 +1,65 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// digestAlgorithms are the Digest header (RFC 3230) algorithms verified by
+// servers with VerifyChecksums set.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+	"md5":     md5.New,
+}
+
+// checksum returns the base64 digest of body with the algorithm new.
+func checksum(new func() hash.Hash, body []byte) string {
+	h := new()
+	h.Write(body)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// verifyChecksum checks the body of r against its Content-MD5 and Digest
+// headers, if any, and replaces it with the verified copy. Digests with
+// unknown algorithms are ignored.
+func verifyChecksum(r *http.Request) (ok bool, err error) {
+	contentMD5 := r.Header.Get("Content-MD5")
+	digest := r.Header.Get("Digest")
+	if contentMD5 == "" && digest == "" {
+		return true, nil
+	}
+
+	var body []byte
+	if body, err = io.ReadAll(r.Body); err != nil {
+		return
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if contentMD5 != "" && checksum(md5.New, body) != contentMD5 {
+		return false, nil
+	}
+	for _, d := range strings.Split(digest, ",") {
+		algorithm, value, found := strings.Cut(strings.TrimSpace(d), "=")
+		if !found {
+			continue
+		}
+		if new, known := digestAlgorithms[strings.ToLower(algorithm)]; known && checksum(new, body) != value {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// setChecksum sets the Digest header of a request with body.
+func setChecksum(req *http.Request, body []byte) {
+	req.Header.Set("Digest", "SHA-256="+checksum(sha256.New, body))
+}
@@ -0,0 +1,44 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVerifyChecksums(t *testing.T) {
+	s := &Server{VerifyChecksums: true, MaxRequestSize: 1024}
+	if err := s.Register("item.get", func(r *http.Request, args *CacheArgs, reply *int) error {
+		*reply = args.N
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	small := []byte(`{"jsonrpc":"2.0","id":1,"method":"item.get","params":{"n":1}}`)
+	large := []byte(`{"jsonrpc":"2.0","id":1,"method":"item.get","params":{"n":1,"pad":"` + strings.Repeat("x", 2048) + `"}}`)
+	tests := []struct {
+		name       string
+		body       []byte
+		digest     string
+		wantStatus int
+	}{
+		{"valid", small, "sha-256=" + checksum(sha256.New, small), http.StatusOK},
+		{"mismatch", small, "sha-256=" + checksum(sha256.New, large), http.StatusBadRequest},
+		{"too large", large, "sha-256=" + checksum(sha256.New, large), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", bytes.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("Digest", tt.digest)
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
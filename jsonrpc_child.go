@@ -0,0 +1,51 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+)
+
+// DefaultForwardHeaders are the inbound request headers a Client forwards
+// on child calls unless Client.ForwardHeaders is set: trace context,
+// correlation ids and credentials.
+var DefaultForwardHeaders = []string{
+	"Traceparent",
+	"Tracestate",
+	"X-Request-Id",
+	"X-Correlation-Id",
+	"Authorization",
+}
+
+type inboundKey struct{}
+
+// ChildContext returns the context for calls a handler makes to other
+// services while serving r. It carries the deadline, cancellation and
+// baggage of r, and the headers of r so that a Client forwards those listed
+// in its ForwardHeaders.
+func ChildContext(r *http.Request) context.Context {
+	return context.WithValue(r.Context(), inboundKey{}, r.Header)
+}
+
+// CallChild calls method on url on behalf of the inbound request r,
+// preserving its context as ChildContext describes.
+func (client *Client) CallChild(r *http.Request, url, method string, params, reply interface{}) error {
+	return client.Call(ChildContext(r), url, method, params, reply)
+}
+
+// setForwardHeaders copies the configured inbound headers carried by ctx
+// to req.
+func (client *Client) setForwardHeaders(ctx context.Context, req *http.Request) {
+	inbound, ok := ctx.Value(inboundKey{}).(http.Header)
+	if !ok {
+		return
+	}
+	names := client.ForwardHeaders
+	if names == nil {
+		names = DefaultForwardHeaders
+	}
+	for _, name := range names {
+		if values := inbound.Values(name); len(values) != 0 {
+			req.Header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+}
@@ -6,25 +6,129 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"reflect"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type Client struct {
 	sync.Mutex
 	IDStore IDStore
 	Base    http.RoundTripper
+
+	// Decompressors maps content codings to decoders. When set, the client
+	// advertises them in Accept-Encoding and decodes matching responses.
+	// Note that advertising codings disables the transparent gzip support of
+	// http.Transport, so include "gzip" here if it is still wanted.
+	Decompressors map[string]Decompressor
+
+	// Dictionaries maps the ids of compression dictionaries held by the
+	// client to their decoders. The ids are advertised in DictionaryHeader
+	// and their codings in Accept-Encoding.
+	Dictionaries map[string]DictionaryDecompressor
+
+	// TimeoutHeader names the header advertising the deadline of each call
+	// to the server. Defaults to DefaultTimeoutHeader.
+	TimeoutHeader string
+
+	// DisableTimeoutHeader stops the client from advertising deadlines.
+	DisableTimeoutHeader bool
+
+	// Retry, if set, retries failed calls.
+	Retry *RetryPolicy
+
+	// Accounting, if set, summarizes the calls made by the client.
+	Accounting *Accounting
+
+	// MaxResponseSize, if positive, caps the bytes of a response body the
+	// client reads. Calls with larger responses fail with
+	// ErrResponseTooLarge. For streams it caps the whole stream.
+	MaxResponseSize int64
+
+	// ForwardHeaders lists the inbound request headers forwarded on calls
+	// made with a ChildContext. Defaults to DefaultForwardHeaders.
+	ForwardHeaders []string
+
+	// Rewrite, if set, maps the method name and params of each call before
+	// it is encoded, e.g. to add a namespace prefix per endpoint or to map
+	// internal names to a vendor's naming scheme. Call options, retries and
+	// accounting still refer to the original method name.
+	Rewrite func(url, method string, params interface{}) (string, interface{})
+
+	// SendChecksums sets a Digest header with the SHA-256 checksum of every
+	// request body, for servers with VerifyChecksums set.
+	SendChecksums bool
+
+	// ParseMode controls how strictly responses are checked.
+	ParseMode ParseMode
+
+	// StreamBlobs streams the []byte and io.Reader fields of params and
+	// replies of Call as base64 instead of encoding them in memory. io.Reader
+	// fields of replies are spooled to temporary files, removed when the
+	// reader is closed. Calls streaming params are not retried and their
+	// bodies are not checksummed.
+	StreamBlobs bool
+
+	// MaxBatchSize, if positive, caps the calls per request sent by Batch.
+	// Otherwise Batch asks each server for its limits with system.info.
+	MaxBatchSize int
+
+	// Clock times the delays between retries and the age of cached
+	// results. Defaults to SystemClock.
+	Clock Clock
+
+	// StatsWindow is the period covered by Stats. Defaults to
+	// DefaultStatsWindow; windows under 6ms are raised to 6ms.
+	StatsWindow time.Duration
+
+	methodOptions  map[string]*CallOptions
+	methodPatterns []string
+	statistics     *clientStats
+	etags          *etagCache
+	limits         map[string]*ServerLimits // by url
 }
 
 func (client *Client) Call(ctx context.Context, url, method string, params, reply interface{}) (err error) {
-	client.Lock()
-	if client.IDStore == nil {
-		client.IDStore = DefaultIDStore()
+	if opts := client.callOptions(method); reply != nil && (opts.Conditional || opts.CacheTTL > 0) {
+		return client.callConditional(ctx, url, method, params, reply, opts.CacheTTL)
 	}
-	if client.Base == nil {
-		client.Base = http.DefaultTransport
+	return client.do(ctx, url, method, params, nil, func(resp *http.Response) error {
+		return client.decodeReply(resp.Body, reply)
+	})
+}
+
+// decodeReply decodes a response into reply, streaming its []byte and
+// io.Reader fields if StreamBlobs is set.
+func (client *Client) decodeReply(r io.Reader, reply interface{}) (err error) {
+	var fields []blobField
+	if client.StreamBlobs && reply != nil {
+		fields = blobFieldsOf(reflect.TypeOf(reply))
 	}
-	client.Unlock()
+	if fields == nil {
+		return decodeReply(r, reply, client.ParseMode)
+	}
+	reduced, blobs, err := readEnvelope(r, "result", func(map[string]json.RawMessage) []blobField {
+		return fields
+	}, 0)
+	if err == nil {
+		err = decodeReply(bytes.NewReader(reduced), reply, client.ParseMode)
+	}
+	if err != nil {
+		blobs.close()
+		return
+	}
+	blobs.fill(reply)
+	return
+}
+
+// do sends a call with the given extra headers and passes the response to
+// decode.
+func (client *Client) do(ctx context.Context, url, method string, params interface{}, header http.Header, decode func(*http.Response) error) (err error) {
+	client.init()
+
+	ctx, cancel, policy := client.callOptions(method).apply(ctx, client)
+	defer cancel()
 
 	var idSession IDSession
 	if idSession, err = client.IDStore.New(); err != nil {
@@ -34,26 +138,86 @@ func (client *Client) Call(ctx context.Context, url, method string, params, repl
 	defer checkClose(&err, idSession)
 
 	var body []byte
-	if body, err = EncodeCall(idSession.ID(), method, params); err != nil {
+	var streams map[string]io.Reader
+	if client.StreamBlobs {
+		body, streams, err = client.encodeStreamed(idSession.ID(), url, method, params)
+	} else {
+		body, err = client.encodeCall(idSession.ID(), url, method, params)
+	}
+	if err != nil {
+		return
+	}
+
+	var resp *http.Response
+	if streams != nil {
+		resp, err = client.sendStreamed(ctx, url, method, body, streams, header)
+	} else {
+		resp, err = client.send(ctx, url, method, body, header, policy)
+	}
+	if err != nil {
+		return
+	}
+
+	defer checkClose(&err, resp.Body)
+	if err = decode(resp); err != nil {
 		return
 	}
+	return
+}
+
+// init fills in the defaults for unset fields.
+func (client *Client) init() {
+	client.Lock()
+	if client.IDStore == nil {
+		client.IDStore = DefaultIDStore()
+	}
+	if client.Base == nil {
+		client.Base = http.DefaultTransport
+	}
+	client.Unlock()
+}
+
+// post sends an encoded request body to url with the given extra headers.
+func (client *Client) post(ctx context.Context, url string, body []byte, header http.Header) (resp *http.Response, err error) {
+	return client.postReader(ctx, url, bytes.NewReader(body), body, header)
+}
 
+// postReader sends a request body read from r to url. body is the body
+// to checksum, or nil if it is streamed.
+func (client *Client) postReader(ctx context.Context, url string, r io.Reader, body []byte, header http.Header) (resp *http.Response, err error) {
 	var req *http.Request
-	if req, err = http.NewRequest("POST", url, bytes.NewReader(body)); err != nil {
+	if req, err = http.NewRequest("POST", url, r); err != nil {
+		if closer, ok := r.(io.Closer); ok {
+			closer.Close()
+		}
 		return
 	}
 
 	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	client.setTimeoutHeader(ctx, req)
+	setBaggageHeader(ctx, req)
+	client.setForwardHeaders(ctx, req)
+	if client.SendChecksums && body != nil {
+		setChecksum(req, body)
+	}
+	if len(client.Decompressors) != 0 || len(client.Dictionaries) != 0 {
+		req.Header.Set("Accept-Encoding", client.acceptEncoding())
+	}
+	if len(client.Dictionaries) != 0 {
+		req.Header.Set(DictionaryHeader, client.dictionaryIDs())
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
 
-	var resp *http.Response
 	if resp, err = client.Base.RoundTrip(req); err != nil {
 		return
 	}
-
-	defer checkClose(&err, resp.Body)
-	if err = DecodeReply(resp.Body, reply); err != nil {
+	if err = client.decompress(resp); err != nil {
 		return
 	}
+	client.limitResponse(resp)
 	return
 }
 
@@ -91,24 +255,60 @@ type clientResponse struct {
 	Id *json.RawMessage `json:"id"`
 }
 
-func EncodeCall(id interface{}, method string, params interface{}) (body []byte, err error) {
-	return json.Marshal(&clientRequest{Version, id, method, params})
+// encodeCall encodes a call to url after applying Rewrite.
+func (client *Client) encodeCall(id interface{}, url, method string, params interface{}) ([]byte, error) {
+	if client.Rewrite != nil {
+		method, params = client.Rewrite(url, method, params)
+	}
+	return EncodeCall(id, method, params)
 }
 
-func DecodeReply(r io.Reader, reply interface{}) (err error) {
-	var response clientResponse
-	if err = json.NewDecoder(r).Decode(&response); err != nil {
-		return
+// encodeStreamed encodes a call to url like encodeCall, except for the
+// []byte and io.Reader fields of params, which are left out to be streamed
+// with writeBlobs. streams is nil if params has none.
+func (client *Client) encodeStreamed(id interface{}, url, method string, params interface{}) (body []byte, streams map[string]io.Reader, err error) {
+	if client.Rewrite != nil {
+		method, params = client.Rewrite(url, method, params)
 	}
-	if response.Error != nil {
-		return response.Error
+	if fields := blobFieldsOf(reflect.TypeOf(params)); fields != nil {
+		if params, streams, err = substituteBlobs(params, fields); err != nil {
+			return
+		}
 	}
-	if err = json.Unmarshal(*response.Result, reply); err != nil {
-		return
+	body, err = EncodeCall(id, method, params)
+	return
+}
+
+// sendStreamed posts body with the streams in place of their markers,
+// without retrying.
+func (client *Client) sendStreamed(ctx context.Context, url, method string, body []byte, streams map[string]io.Reader, header http.Header) (resp *http.Response, err error) {
+	if client.Accounting != nil {
+		if err = client.Accounting.reserve(url, method, len(body)); err != nil {
+			return
+		}
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeBlobs(pw, body, streams))
+	}()
+	stats := client.stats()
+	start := time.Now()
+	resp, err = client.postReader(stats.trace(ctx, url), url, pr, nil, header)
+	stats.record(url, start, resp, err)
+	if client.Accounting != nil {
+		client.Accounting.record(url, method, start, resp, err)
 	}
 	return
 }
 
+func EncodeCall(id interface{}, method string, params interface{}) (body []byte, err error) {
+	return json.Marshal(&clientRequest{Version, id, method, params})
+}
+
+func DecodeReply(r io.Reader, reply interface{}) (err error) {
+	return decodeReply(r, reply, ParseDefault)
+}
+
 type IDStore interface {
 	New() (IDSession, error)
 }
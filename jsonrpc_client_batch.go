@@ -0,0 +1,217 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"sort"
+)
+
+// ErrNoResponse is the error of a batched call the server did not answer.
+var ErrNoResponse = errors.New("rpc: no response to batched call")
+
+// batchMethod stands for the method of batch requests in accounting and
+// retry policies.
+const batchMethod = "rpc.batch"
+
+// BatchCall is one call of a batch made with Client.Batch.
+type BatchCall struct {
+	Method string
+	Params interface{}
+
+	// Reply receives the result of the call, unless nil.
+	Reply interface{}
+
+	// Priority orders the calls when the batch is split into several
+	// requests: calls of higher priority are sent first. Zero stands for
+	// the Priority of the method's CallOptions.
+	Priority int
+
+	// Error is set by Batch to the error of the call.
+	Error error
+}
+
+// Batch sends calls to url in batch requests, setting the Reply and Error
+// of each call. Batches larger than MaxBatchSize, or than the limits
+// reported by the server's system.info method, are split into several
+// requests sent one after the other, highest priority calls first, as are
+// batches the server rejects as a whole, with status 413 or an error
+// response without an id. The returned error is that of a request that
+// failed before any call could be answered.
+func (client *Client) Batch(ctx context.Context, url string, calls []BatchCall) (err error) {
+	client.init()
+	if len(calls) == 0 {
+		return
+	}
+	limits := client.batchLimits(ctx, url)
+
+	encoded := make([]*encodedCall, len(calls))
+	for i := range calls {
+		call := &encodedCall{call: &calls[i], priority: calls[i].Priority}
+		if call.priority == 0 {
+			call.priority = client.callOptions(call.call.Method).Priority
+		}
+		var idSession IDSession
+		if idSession, err = client.IDStore.New(); err != nil {
+			return
+		}
+		call.body, err = client.encodeCall(idSession.ID(), url, call.call.Method, call.call.Params)
+		if err == nil {
+			call.id, err = json.Marshal(idSession.ID())
+		}
+		idSession.Close()
+		if err != nil {
+			return
+		}
+		encoded[i] = call
+	}
+
+	for _, chunk := range splitBatch(encoded, limits) {
+		if err = client.sendBatch(ctx, url, chunk); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// encodedCall is a BatchCall with its encoded request.
+type encodedCall struct {
+	call     *BatchCall
+	id       json.RawMessage
+	body     []byte
+	priority int // of the call, or else of its method
+}
+
+// splitBatch splits calls into batches within limits, highest priority
+// calls first. calls is sorted in place.
+func splitBatch(calls []*encodedCall, limits *ServerLimits) (chunks [][]*encodedCall) {
+	sort.SliceStable(calls, func(i, j int) bool {
+		return calls[i].priority > calls[j].priority
+	})
+	var chunk []*encodedCall
+	size := int64(1) // the brackets, less the first comma
+	for _, call := range calls {
+		callSize := int64(len(call.body)) + 1
+		if len(chunk) != 0 &&
+			(limits.MaxBatchSize > 0 && len(chunk) >= limits.MaxBatchSize ||
+				limits.MaxRequestSize > 0 && size+callSize > limits.MaxRequestSize) {
+			chunks = append(chunks, chunk)
+			chunk, size = nil, 1
+		}
+		chunk = append(chunk, call)
+		size += callSize
+	}
+	return append(chunks, chunk)
+}
+
+// batchLimits returns the limits batches to url must stay within.
+func (client *Client) batchLimits(ctx context.Context, url string) (limits *ServerLimits) {
+	if client.MaxBatchSize > 0 {
+		return &ServerLimits{MaxBatchSize: client.MaxBatchSize}
+	}
+	client.Lock()
+	limits = client.limits[url]
+	client.Unlock()
+	if limits != nil {
+		return
+	}
+
+	// Servers that do not report their limits, whether they answer with an
+	// error, another status or not at all, get the batch unsplit.
+	var info ServerInfo
+	if errInfo := client.Call(ctx, url, "system.info", nil, &info); errInfo != nil {
+		var transportErr *neturl.Error
+		if errors.As(errInfo, &transportErr) || ctx.Err() != nil {
+			// Ask again next time.
+			return &ServerLimits{}
+		}
+		info = ServerInfo{}
+	}
+	limits = &info.Limits
+	client.Lock()
+	if client.limits == nil {
+		client.limits = make(map[string]*ServerLimits)
+	}
+	client.limits[url] = limits
+	client.Unlock()
+	return
+}
+
+// sendBatch sends calls in one batch request. A batch the server rejects
+// as a whole is split in two and retried; any other response that is not
+// an array of responses fails.
+func (client *Client) sendBatch(ctx context.Context, url string, calls []*encodedCall) (err error) {
+	body := []byte{'['}
+	for i, call := range calls {
+		if i > 0 {
+			body = append(body, ',')
+		}
+		body = append(body, call.body...)
+	}
+	body = append(body, ']')
+
+	var resp *http.Response
+	if resp, err = client.send(ctx, url, batchMethod, body, nil, client.Retry); err != nil {
+		return
+	}
+	var data []byte
+	data, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return
+	}
+
+	var responses []json.RawMessage
+	if err = json.Unmarshal(data, &responses); err != nil {
+		rejection := batchRejection(resp, data, client.ParseMode)
+		if rejection == nil {
+			return fmt.Errorf("rpc: invalid response to batch request (%s)", resp.Status)
+		}
+		if len(calls) > 1 {
+			if err = client.sendBatch(ctx, url, calls[:len(calls)/2]); err != nil {
+				return
+			}
+			return client.sendBatch(ctx, url, calls[len(calls)/2:])
+		}
+		calls[0].call.Error = rejection
+		return nil
+	}
+
+	byID := make(map[string]json.RawMessage, len(responses))
+	for _, response := range responses {
+		var probe struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if json.Unmarshal(response, &probe) == nil {
+			byID[string(probe.ID)] = response
+		}
+	}
+	for _, call := range calls {
+		response, ok := byID[string(call.id)]
+		if !ok {
+			call.call.Error = ErrNoResponse
+			continue
+		}
+		call.call.Error = decodeReply(bytes.NewReader(response), call.call.Reply, client.ParseMode)
+	}
+	return
+}
+
+// batchRejection returns the error of a response rejecting a batch as a
+// whole: status 413, or an error without an id. It returns nil for other
+// responses.
+func batchRejection(resp *http.Response, data []byte, mode ParseMode) error {
+	if resp.StatusCode == http.StatusRequestEntityTooLarge {
+		return fmt.Errorf("rpc: batch request answered %s", resp.Status)
+	}
+	var response rawResponse
+	if json.Unmarshal(data, &response) != nil || !isNull(response.ID) || isNull(response.Error) {
+		return nil
+	}
+	return decodeReply(bytes.NewReader(data), nil, mode)
+}
@@ -0,0 +1,140 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBatchSplit(t *testing.T) {
+	tests := []struct {
+		name string
+		// reject answers batches of more than one call.
+		reject       func(w http.ResponseWriter)
+		wantErr      bool
+		wantRequests int
+	}{
+		{"accepted", nil, false, 1},
+		{"too large", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		}, false, 7},
+		{"rejected without id", func(w http.ResponseWriter) {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"batch too large"}}`))
+		}, false, 7},
+		{"error with id", func(w http.ResponseWriter) {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32603,"message":"internal"}}`))
+		}, true, 1},
+		{"server error", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html>bad gateway</html>"))
+		}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				body, _ := ioutil.ReadAll(r.Body)
+				var calls []struct {
+					ID json.RawMessage `json:"id"`
+				}
+				json.Unmarshal(body, &calls)
+				if len(calls) > 1 && tt.reject != nil {
+					tt.reject(w)
+					return
+				}
+				responses := make([]string, len(calls))
+				for i, call := range calls {
+					responses[i] = fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%d}`, call.ID, i)
+				}
+				w.Write([]byte("[" + strings.Join(responses, ",") + "]"))
+			}))
+			defer ts.Close()
+
+			client := &Client{MaxBatchSize: 100}
+			calls := make([]BatchCall, 4)
+			for i := range calls {
+				calls[i] = BatchCall{Method: "item.get", Reply: new(int)}
+			}
+			err := client.Batch(context.Background(), ts.URL, calls)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("sent %d requests, want %d", requests, tt.wantRequests)
+			}
+			if tt.wantErr {
+				return
+			}
+			for i, call := range calls {
+				if call.Error != nil {
+					t.Errorf("call %d: %v", i, call.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchLimitsUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		info func(w http.ResponseWriter)
+	}{
+		{"no method", func(w http.ResponseWriter) {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"no method"}}`))
+		}},
+		{"not found", func(w http.ResponseWriter) {
+			http.NotFound(w, nil)
+		}},
+		{"method not allowed", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			infos, batches := 0, 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				var calls []struct {
+					ID json.RawMessage `json:"id"`
+				}
+				if json.Unmarshal(body, &calls) != nil {
+					infos++
+					tt.info(w)
+					return
+				}
+				batches++
+				responses := make([]string, len(calls))
+				for i, call := range calls {
+					responses[i] = fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%d}`, call.ID, i)
+				}
+				w.Write([]byte("[" + strings.Join(responses, ",") + "]"))
+			}))
+			defer ts.Close()
+
+			client := new(Client)
+			for round := 0; round < 2; round++ {
+				calls := make([]BatchCall, 3)
+				for i := range calls {
+					calls[i] = BatchCall{Method: "item.get", Reply: new(int)}
+				}
+				if err := client.Batch(context.Background(), ts.URL, calls); err != nil {
+					t.Fatal(err)
+				}
+				for i, call := range calls {
+					if call.Error != nil || *call.Reply.(*int) != i {
+						t.Errorf("call %d = %d, %v", i, *call.Reply.(*int), call.Error)
+					}
+				}
+			}
+			if infos != 1 || batches != 2 {
+				t.Errorf("%d system.info requests and %d batches, want 1 and 2", infos, batches)
+			}
+		})
+	}
+}
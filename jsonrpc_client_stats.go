@@ -0,0 +1,240 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultStatsWindow is the period Client.Stats covers unless
+	// Client.StatsWindow is set.
+	DefaultStatsWindow = time.Minute
+
+	statsBuckets          = 6
+	minStatsWindow        = statsBuckets * time.Millisecond
+	statsSamplesPerBucket = 1024
+)
+
+// ClientStats summarizes the requests a Client sent over the last window,
+// per endpoint. Every attempt counts, and an attempt fails if it got no
+// response or a 5xx status; JSON-RPC errors are answers, not failures.
+type ClientStats struct {
+	Window    time.Duration
+	Total     EndpointStats
+	Endpoints map[string]*EndpointStats
+}
+
+// EndpointStats summarizes the requests sent to one endpoint.
+type EndpointStats struct {
+	Requests    int64
+	Failures    int64
+	SuccessRate float64
+
+	// Latency percentiles of the requests, until the response headers
+	// arrived, estimated from a sample.
+	P50, P90, P99 time.Duration
+
+	LastFailure time.Time
+
+	// Connection reuse, from httptrace: requests sent over a reused
+	// connection, over a new one, and TLS handshakes made and among them
+	// those that resumed a session. Endpoints whose handshakes rarely
+	// resume renegotiate TLS on every new connection.
+	ReusedConns   int64
+	NewConns      int64
+	TLSHandshakes int64
+	TLSResumed    int64
+}
+
+// String summarizes the statistics on one line for debug output.
+func (e *EndpointStats) String() string {
+	return fmt.Sprintf("requests=%d failures=%d success=%.3f p50=%s p90=%s p99=%s conns=%d/%d reused tls=%d/%d resumed",
+		e.Requests, e.Failures, e.SuccessRate, e.P50, e.P90, e.P99,
+		e.ReusedConns, e.ReusedConns+e.NewConns, e.TLSResumed, e.TLSHandshakes)
+}
+
+// Healthy reports whether at least the fraction minSuccessRate of requests
+// succeeded. Endpoints without requests are healthy.
+func (e *EndpointStats) Healthy(minSuccessRate float64) bool {
+	return e.Requests == 0 || e.SuccessRate >= minSuccessRate
+}
+
+// clientStats keeps a ring of time buckets per endpoint.
+type clientStats struct {
+	sync.Mutex
+	window    time.Duration
+	endpoints map[string]*[statsBuckets]statsBucket
+}
+
+type statsBucket struct {
+	slot        int64
+	requests    int64
+	failures    int64
+	latencies   []time.Duration
+	lastFailure time.Time
+
+	reusedConns   int64
+	newConns      int64
+	tlsHandshakes int64
+	tlsResumed    int64
+}
+
+func (s *clientStats) bucketSize() time.Duration {
+	return s.window / statsBuckets
+}
+
+// bucket returns the current bucket of endpoint. s must be locked.
+func (s *clientStats) bucket(endpoint string, now time.Time) *statsBucket {
+	slot := now.UnixNano() / int64(s.bucketSize())
+	buckets := s.endpoints[endpoint]
+	if buckets == nil {
+		buckets = new([statsBuckets]statsBucket)
+		s.endpoints[endpoint] = buckets
+	}
+	// Times before 1970 give negative slots.
+	b := &buckets[(slot%statsBuckets+statsBuckets)%statsBuckets]
+	if b.slot != slot {
+		*b = statsBucket{slot: slot, latencies: b.latencies[:0]}
+	}
+	return b
+}
+
+func (s *clientStats) record(endpoint string, start time.Time, resp *http.Response, err error) {
+	now := time.Now()
+	s.Lock()
+	defer s.Unlock()
+	b := s.bucket(endpoint, now)
+	b.requests++
+	if err != nil || resp.StatusCode >= 500 {
+		b.failures++
+		b.lastFailure = now
+		return
+	}
+	latency := now.Sub(start)
+	if len(b.latencies) < statsSamplesPerBucket {
+		b.latencies = append(b.latencies, latency)
+	} else if i := rand.Int63n(b.requests - b.failures); i < statsSamplesPerBucket {
+		b.latencies[i] = latency
+	}
+}
+
+// trace returns ctx with an httptrace.ClientTrace counting connection and
+// TLS session reuse for endpoint.
+func (s *clientStats) trace(ctx context.Context, endpoint string) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			s.Lock()
+			b := s.bucket(endpoint, time.Now())
+			if info.Reused {
+				b.reusedConns++
+			} else {
+				b.newConns++
+			}
+			s.Unlock()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err != nil {
+				return
+			}
+			s.Lock()
+			b := s.bucket(endpoint, time.Now())
+			b.tlsHandshakes++
+			if state.DidResume {
+				b.tlsResumed++
+			}
+			s.Unlock()
+		},
+	})
+}
+
+func (s *clientStats) snapshot() *ClientStats {
+	oldest := time.Now().UnixNano()/int64(s.bucketSize()) - statsBuckets + 1
+	stats := &ClientStats{Window: s.window, Endpoints: make(map[string]*EndpointStats)}
+	var all []time.Duration
+
+	s.Lock()
+	defer s.Unlock()
+	for endpoint, buckets := range s.endpoints {
+		e := new(EndpointStats)
+		var latencies []time.Duration
+		for i := range buckets {
+			b := &buckets[i]
+			if b.slot < oldest {
+				continue
+			}
+			e.Requests += b.requests
+			e.Failures += b.failures
+			latencies = append(latencies, b.latencies...)
+			if b.lastFailure.After(e.LastFailure) {
+				e.LastFailure = b.lastFailure
+			}
+			e.ReusedConns += b.reusedConns
+			e.NewConns += b.newConns
+			e.TLSHandshakes += b.tlsHandshakes
+			e.TLSResumed += b.tlsResumed
+		}
+		if e.Requests == 0 {
+			continue
+		}
+		e.summarize(latencies)
+		stats.Endpoints[endpoint] = e
+
+		stats.Total.Requests += e.Requests
+		stats.Total.Failures += e.Failures
+		if e.LastFailure.After(stats.Total.LastFailure) {
+			stats.Total.LastFailure = e.LastFailure
+		}
+		stats.Total.ReusedConns += e.ReusedConns
+		stats.Total.NewConns += e.NewConns
+		stats.Total.TLSHandshakes += e.TLSHandshakes
+		stats.Total.TLSResumed += e.TLSResumed
+		all = append(all, latencies...)
+	}
+	stats.Total.summarize(all)
+	return stats
+}
+
+// summarize computes the success rate and latency percentiles.
+func (e *EndpointStats) summarize(latencies []time.Duration) {
+	if e.Requests > 0 {
+		e.SuccessRate = float64(e.Requests-e.Failures) / float64(e.Requests)
+	}
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+	e.P50, e.P90, e.P99 = percentile(0.5), percentile(0.9), percentile(0.99)
+}
+
+// stats returns the statistics of the client, creating them on first use.
+func (client *Client) stats() *clientStats {
+	client.Lock()
+	defer client.Unlock()
+	if client.statistics == nil {
+		window := client.StatsWindow
+		if window <= 0 {
+			window = DefaultStatsWindow
+		} else if window < minStatsWindow {
+			window = minStatsWindow
+		}
+		client.statistics = &clientStats{window: window, endpoints: make(map[string]*[statsBuckets]statsBucket)}
+	}
+	return client.statistics
+}
+
+// Stats returns success rates and latencies of the requests sent over the
+// last StatsWindow, overall and per endpoint, e.g. for routing decisions
+// or displaying upstream health.
+func (client *Client) Stats() *ClientStats {
+	return client.stats().snapshot()
+}
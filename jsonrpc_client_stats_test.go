@@ -0,0 +1,31 @@
+package jsonrpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClientStatsWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		window time.Duration
+		now    time.Time
+		want   time.Duration
+	}{
+		{"default", 0, time.Now(), DefaultStatsWindow},
+		{"nanosecond", time.Nanosecond, time.Now(), minStatsWindow},
+		{"under bucket count", statsBuckets - 1, time.Now(), minStatsWindow},
+		{"before 1970", time.Minute, time.Unix(-3600, 0), time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := (&Client{StatsWindow: tt.window}).stats()
+			if s.window != tt.want {
+				t.Errorf("window = %s, want %s", s.window, tt.want)
+			}
+			s.Lock()
+			s.bucket("http://example.com", tt.now).requests++
+			s.Unlock()
+		})
+	}
+}
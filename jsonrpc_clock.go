@@ -0,0 +1,104 @@
+package jsonrpc
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Clock tells the time and schedules timers for retry backoff, rate
+// limits, cache expiry and idle timeouts. Tests substitute a fake clock,
+// such as jsonrpctest.FakeClock, to run these instantly and
+// deterministically. A nil Clock is the system clock.
+type Clock interface {
+	Now() time.Time
+
+	// NewTimer returns a timer sending the time on its channel after d.
+	NewTimer(d time.Duration) Timer
+
+	// AfterFunc returns a timer calling f in its own goroutine after d.
+	// Its channel is nil.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer created by a Clock, as time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Rand is a source of randomness for the jitter of retry backoff. A
+// *rand.Rand seeded with a constant makes the delays reproducible. A nil
+// Rand is the global source of math/rand.
+type Rand interface {
+	Int63n(n int64) int64
+}
+
+// SystemClock is the Clock of the time package.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type globalRand struct{}
+
+func (globalRand) Int63n(n int64) int64 {
+	return rand.Int63n(n)
+}
+
+// clockOr returns c, or the system clock if c is nil.
+func clockOr(c Clock) Clock {
+	if c == nil {
+		return SystemClock
+	}
+	return c
+}
+
+// randOr returns r, or the global source if r is nil.
+func randOr(r Rand) Rand {
+	if r == nil {
+		return globalRand{}
+	}
+	return r
+}
+
+// since returns the time elapsed on c since t.
+func since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// wait waits for d on c, or until done is closed, reporting whether d
+// elapsed.
+func wait(c Clock, d time.Duration, done <-chan struct{}) bool {
+	timer := c.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return true
+	case <-done:
+		return false
+	}
+}
+
+func (s *Server) clock() Clock {
+	return clockOr(s.Clock)
+}
@@ -0,0 +1,104 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-webdl/jsonrpc"
+	"github.com/go-webdl/jsonrpc/jsonrpctest"
+)
+
+func TestFileCacheClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	path := filepath.Join(t.TempDir(), "cache.log")
+	clock := jsonrpctest.NewFakeClock(start)
+	c, err := jsonrpc.OpenFileCache(path, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put(jsonrpc.CacheEntry{Key: "k", Result: json.RawMessage("1"), Expires: start.Add(time.Minute)})
+	if _, ok := c.Get("k"); !ok {
+		t.Error("entry expired early")
+	}
+	clock.Advance(2 * time.Minute)
+	if _, ok := c.Get("k"); ok {
+		t.Error("entry did not expire")
+	}
+	c.Put(jsonrpc.CacheEntry{Key: "k", Result: json.RawMessage("2"), Expires: start.Add(3 * time.Minute)})
+	c.Close()
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		wantFound bool
+	}{
+		{"before expiry", start.Add(2 * time.Minute), true},
+		{"after expiry", start.Add(4 * time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := jsonrpc.OpenFileCache(path, jsonrpctest.NewFakeClock(tt.now))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			if _, ok := c.Get("k"); ok != tt.wantFound {
+				t.Errorf("found = %v, want %v", ok, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestAccountingClock(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":1}`))
+	}))
+	defer ts.Close()
+
+	start := time.Unix(1000, 0)
+	clock := jsonrpctest.NewFakeClock(start)
+	var reports []*jsonrpc.AccountingReport
+	accounting := &jsonrpc.Accounting{
+		Interval: time.Minute,
+		MaxCalls: 1,
+		Clock:    clock,
+		OnReport: func(report *jsonrpc.AccountingReport) { reports = append(reports, report) },
+	}
+	client := &jsonrpc.Client{Accounting: accounting}
+	call := func() error {
+		var reply int
+		return client.Call(context.Background(), ts.URL, "item.get", nil, &reply)
+	}
+
+	tests := []struct {
+		name        string
+		advance     time.Duration
+		wantErr     error
+		wantReports int
+	}{
+		{"first", 0, nil, 0},
+		{"over budget", 30 * time.Second, jsonrpc.ErrBudgetExceeded, 0},
+		{"next interval", 30 * time.Second, nil, 1},
+	}
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+		if err := call(); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if len(reports) != tt.wantReports {
+			t.Errorf("%s: %d reports, want %d", tt.name, len(reports), tt.wantReports)
+		}
+	}
+	if got := reports[0]; !got.Start.Equal(start) || !got.End.Equal(start.Add(time.Minute)) {
+		t.Errorf("report covers %s to %s, want %s to %s", got.Start, got.End, start, start.Add(time.Minute))
+	}
+	if snapshot := accounting.Snapshot(); !snapshot.End.Equal(clock.Now()) {
+		t.Errorf("snapshot ends at %s, want %s", snapshot.End, clock.Now())
+	}
+}
@@ -2,7 +2,10 @@ package jsonrpc
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
 )
 
 var null = json.RawMessage([]byte("null"))
@@ -24,8 +27,7 @@ type serverRequest struct {
 	Params *json.RawMessage `json:"params"`
 
 	// The request id. MUST be a string, number or null.
-	// Our implementation will not do type checking for id.
-	// It will be copied as it is.
+	// It is copied as it is into the response.
 	Id *json.RawMessage `json:"id"`
 }
 
@@ -67,6 +69,15 @@ func (c *Codec) NewRequest(r *http.Request) *CodecRequest {
 	return newCodecRequest(r, c.errorMapper)
 }
 
+// jsonCodec adapts Codec to the ServerCodec interface.
+type jsonCodec struct {
+	*Codec
+}
+
+func (c jsonCodec) NewRequest(r *http.Request) ServerCodecRequest {
+	return c.Codec.NewRequest(r)
+}
+
 // ----------------------------------------------------------------------------
 // CodecRequest
 // ----------------------------------------------------------------------------
@@ -75,25 +86,111 @@ func (c *Codec) NewRequest(r *http.Request) *CodecRequest {
 func newCodecRequest(r *http.Request, errorMapper func(error) error) *CodecRequest {
 	// Decode the request body and check if RPC method is valid.
 	req := new(serverRequest)
-	err := json.NewDecoder(r.Body).Decode(req)
+	var raw json.RawMessage
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&raw)
+	if err == nil {
+		// Only whitespace may follow the request.
+		if _, errTrailing := decoder.Token(); errTrailing != io.EOF {
+			err = errors.New("rpc: unexpected data after the request")
+		}
+	}
+	if err == nil {
+		err = json.Unmarshal(raw, req)
+	}
 
-	if err != nil {
-		err = &Error{
-			Code:    E_PARSE,
-			Message: err.Error(),
-			Data:    req,
+	invalidID := req.Id != nil && !isValidID(*req.Id)
+	if invalidID {
+		// Not an id the response could echo.
+		req.Id = nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		// Valid JSON, but not a request object.
+		err = &Error{Code: E_INVALID_REQ, Message: err.Error(), Data: req}
+	case err != nil:
+		err = &Error{Code: E_PARSE, Message: err.Error(), Data: req}
+	case invalidID:
+		err = &Error{Code: E_INVALID_REQ, Message: "rpc: id must be a string, number or null"}
+	case req.Method == "":
+		err = &Error{Code: E_INVALID_REQ, Message: "rpc: request has no method"}
+	}
+
+	// A valid request without an id member is a notification. An id of
+	// null decodes to nil as well, so look for the member itself.
+	var notification bool
+	if err == nil {
+		var probe struct {
+			Id json.RawMessage `json:"id"`
 		}
+		json.Unmarshal(raw, &probe)
+		notification = probe.Id == nil
 	}
 
 	r.Body.Close()
-	return &CodecRequest{request: req, err: err, errorMapper: errorMapper}
+	return &CodecRequest{request: req, raw: raw, err: err, notification: notification, errorMapper: errorMapper}
+}
+
+// checkStrict fails the request if it violates the specification, as
+// Server.StrictRequests asks for. Requests failing it are answered even
+// if they lack an id.
+func (c *CodecRequest) checkStrict() {
+	if c.err != nil {
+		return
+	}
+	if c.err = checkStrict(c.raw); c.err != nil {
+		c.notification = false
+	}
+}
+
+// checkStrict checks the members of a request against the specification:
+// exactly jsonrpc "2.0", method, and optionally params, an object or array,
+// and id.
+func checkStrict(raw json.RawMessage) error {
+	var members map[string]json.RawMessage
+	json.Unmarshal(raw, &members)
+	var version string
+	if json.Unmarshal(members["jsonrpc"], &version) != nil || version != Version {
+		return &Error{Code: E_INVALID_REQ, Message: "rpc: jsonrpc member must be \"" + Version + "\""}
+	}
+	if params, ok := members["params"]; ok && !isArray(params) && !isObject(params) {
+		return &Error{Code: E_INVALID_REQ, Message: "rpc: params must be an object or an array"}
+	}
+	for name := range members {
+		switch name {
+		case "jsonrpc", "method", "params", "id":
+		default:
+			return &Error{Code: E_INVALID_REQ, Message: "rpc: unexpected member " + strconv.Quote(name)}
+		}
+	}
+	return nil
+}
+
+// isValidID reports whether a request id is a string or a number. Null ids
+// decode to nil.
+func isValidID(id json.RawMessage) bool {
+	if len(id) == 0 {
+		return false
+	}
+	switch c := id[0]; {
+	case c == '"':
+		return true
+	case c == '-' || c >= '0' && c <= '9':
+		var n json.Number
+		return json.Unmarshal(id, &n) == nil
+	}
+	return false
 }
 
 // CodecRequest decodes and encodes a single request.
 type CodecRequest struct {
-	request     *serverRequest
-	err         error
-	errorMapper func(error) error
+	request      *serverRequest
+	raw          json.RawMessage // the request as received
+	err          error
+	notification bool // whether the request expects no response
+	errorMapper  func(error) error
 }
 
 // Method returns the RPC method for the current request.
@@ -126,12 +223,21 @@ func (c *CodecRequest) ReadRequest(args interface{}) error {
 		if err := json.Unmarshal(*c.request.Params, args); err != nil {
 			// Clearly JSON params is not a structured object,
 			// fallback and attempt an unmarshal with JSON params as
-			// array value and RPC params is struct. Unmarshal into
-			// array containing the request struct.
-			params := [1]interface{}{args}
-			if err = json.Unmarshal(*c.request.Params, &params); err != nil {
+			// array value and RPC params is struct. A single element
+			// may hold the whole request struct; otherwise decode the
+			// array elements into the struct fields in declaration
+			// order.
+			if params := *c.request.Params; isArray(params) {
+				single := [1]interface{}{args}
+				if arrayLen(params) == 1 && json.Unmarshal(params, &single) == nil {
+					err = nil
+				} else {
+					err = decodePositional(params, args)
+				}
+			}
+			if err != nil {
 				c.err = &Error{
-					Code:    E_INVALID_REQ,
+					Code:    E_BAD_PARAMS,
 					Message: err.Error(),
 					Data:    c.request.Params,
 				}
@@ -142,7 +248,12 @@ func (c *CodecRequest) ReadRequest(args interface{}) error {
 }
 
 // WriteResponse encodes the response and writes it to the ResponseWriter.
+// Notifications are answered with 204 No Content instead.
 func (c *CodecRequest) WriteResponse(w http.ResponseWriter, reply interface{}) {
+	if c.notification {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 	res := &serverResponse{
 		Version: Version,
 		Result:  reply,
@@ -151,9 +262,15 @@ func (c *CodecRequest) WriteResponse(w http.ResponseWriter, reply interface{}) {
 	c.writeServerResponse(w, res)
 }
 
+// WriteError encodes the error and writes it to the ResponseWriter.
+// Notifications are answered with 204 No Content instead.
 func (c *CodecRequest) WriteError(w http.ResponseWriter, status int, err error) {
+	if c.notification {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 	err = c.tryToMapIfNotAnErrorAlready(err)
-	jsonErr, ok := err.(*Error)
+	jsonErr, ok := errorOf(err)
 	if !ok {
 		jsonErr = &Error{
 			Code:    E_SERVER,
@@ -169,7 +286,7 @@ func (c *CodecRequest) WriteError(w http.ResponseWriter, status int, err error)
 }
 
 func (c CodecRequest) tryToMapIfNotAnErrorAlready(err error) error {
-	if _, ok := err.(*Error); ok || c.errorMapper == nil {
+	if _, ok := errorOf(err); ok || c.errorMapper == nil {
 		return err
 	}
 	return c.errorMapper(err)
@@ -178,12 +295,48 @@ func (c CodecRequest) tryToMapIfNotAnErrorAlready(err error) error {
 func (c *CodecRequest) writeServerResponse(w http.ResponseWriter, res *serverResponse) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	encoder := json.NewEncoder(w)
-	err := encoder.Encode(res)
+	if err := encoder.Encode(res); err != nil {
+		// The result cannot be encoded, and nothing was written yet. The
+		// error is answered like any other, with status 200.
+		if watcher, ok := w.(*encodeWatcher); ok {
+			watcher.failed = true
+		}
+		encoder.Encode(&serverResponse{
+			Version: Version,
+			Error:   &Error{Code: E_INTERNAL, Message: err.Error()},
+			Id:      res.Id,
+		})
+	}
+}
 
-	// Not sure in which case will this happen. But seems harmless.
+// writeStreamed writes the response like WriteResponse, streaming the
+// []byte and io.Reader fields of reply as base64. Readers are closed once
+// sent if they are io.Closers. An error means the response was cut short.
+func (c *CodecRequest) writeStreamed(w http.ResponseWriter, reply interface{}, fields []blobField) (err error) {
+	if c.notification {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	substituted, streams, err := substituteBlobs(reply, fields)
+	var data []byte
+	if err == nil {
+		data, err = json.Marshal(&serverResponse{Version: Version, Result: substituted, Id: c.request.Id})
+	}
+	for _, stream := range streams {
+		if closer, ok := stream.(io.Closer); ok {
+			defer closer.Close()
+		}
+	}
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, err.Error())
+		// Answer the failure like WriteResponse.
+		c.writeServerResponse(w, &serverResponse{Version: Version, Result: reply, Id: c.request.Id})
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err = writeBlobs(w, data, streams); err == nil {
+		_, err = w.Write([]byte{'\n'})
 	}
+	return
 }
 
 type EmptyResponse struct {
@@ -0,0 +1,110 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorCodes(t *testing.T) {
+	s := &Server{RecoverPanics: true}
+	if err := s.Register("item.get", func(r *http.Request, args *CacheArgs, reply *int) error {
+		if args.N < 0 {
+			panic("negative")
+		}
+		*reply = args.N
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		body     string
+		wantCode ErrorCode
+	}{
+		{"valid", `{"jsonrpc":"2.0","id":1,"method":"item.get","params":{"n":1}}`, 0},
+		{"trailing whitespace", `{"jsonrpc":"2.0","id":1,"method":"item.get","params":{"n":1}}` + " \n", 0},
+		{"unknown method", `{"jsonrpc":"2.0","id":1,"method":"item.put"}`, E_NO_METHOD},
+		{"invalid params", `{"jsonrpc":"2.0","id":1,"method":"item.get","params":{"n":"x"}}`, E_BAD_PARAMS},
+		{"malformed", `{"jsonrpc":"2.0","id":1,"method":`, E_PARSE},
+		{"trailing garbage", `{"jsonrpc":"2.0","id":1,"method":"item.get"} garbage`, E_PARSE},
+		{"trailing value", `{"jsonrpc":"2.0","id":1,"method":"item.get"}{}`, E_PARSE},
+		{"panic", `{"jsonrpc":"2.0","id":1,"method":"item.get","params":{"n":-1}}`, E_INTERNAL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			var resp Response
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("%v: %q", err, w.Body)
+			}
+			var code ErrorCode
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			if code != tt.wantCode {
+				t.Errorf("code = %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestResponseID(t *testing.T) {
+	s := new(Server)
+	if err := s.Register("item.get", func(r *http.Request, args *CacheArgs, reply *int) error {
+		*reply = args.N
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		id       string
+		method   string
+		params   string
+		wantID   string
+		wantCode ErrorCode
+	}{
+		{"string", `"abc"`, "item.get", `{"n":1}`, `"abc"`, 0},
+		{"number", `7`, "item.get", `{"n":1}`, `7`, 0},
+		{"null", `null`, "item.get", `{"n":1}`, `null`, 0},
+		{"float", `1.5`, "item.get", `{"n":1}`, `1.5`, 0},
+		{"exponent", `-2e3`, "item.get", `{"n":1}`, `-2e3`, 0},
+		{"object", `{"a":1}`, "item.get", `{"n":1}`, `null`, E_INVALID_REQ},
+		{"bool", `true`, "item.get", `{"n":1}`, `null`, E_INVALID_REQ},
+		{"unknown method", `"abc"`, "item.put", `{}`, `"abc"`, E_NO_METHOD},
+		{"invalid params", `7`, "item.get", `{"n":"x"}`, `7`, E_BAD_PARAMS},
+		{"no method", `1.5`, "", `{}`, `1.5`, E_INVALID_REQ},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"jsonrpc":"2.0","id":` + tt.id + `,"method":"` + tt.method + `","params":` + tt.params + `}`
+			r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			var resp Response
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("%v: %q", err, w.Body)
+			}
+			if string(resp.ID) != tt.wantID {
+				t.Errorf("id = %s, want %s", resp.ID, tt.wantID)
+			}
+			var code ErrorCode
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			if code != tt.wantCode {
+				t.Errorf("code = %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package jsonrpc
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Compressor is a resettable compressing writer for one content coding.
+// *gzip.Writer satisfies it, as do the zstd and brotli encoders of the
+// common third-party packages (e.g. klauspost/compress/zstd, andybalholm/brotli).
+type Compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// NewGzipCompressor creates gzip compressors for RegisterCompression.
+func NewGzipCompressor(w io.Writer) (Compressor, error) {
+	return gzip.NewWriter(w), nil
+}
+
+// compression is a registered content coding with its pool of compressors.
+type compression struct {
+	coding     string
+	dictionary string // id of the dictionary, if any
+	new        func(w io.Writer) (Compressor, error)
+	pool       sync.Pool
+}
+
+func (c *compression) get(w io.Writer) (compressor Compressor, err error) {
+	if v := c.pool.Get(); v != nil {
+		compressor = v.(Compressor)
+		compressor.Reset(w)
+		return
+	}
+	return c.new(w)
+}
+
+// RegisterCompression enables response compression with the given content
+// coding, negotiated against the request's Accept-Encoding header. When the
+// client accepts several registered codings with the same quality, the one
+// registered first wins. Compressors are pooled and reused across responses.
+//
+//	s.RegisterCompression("zstd", func(w io.Writer) (jsonrpc.Compressor, error) {
+//		return zstd.NewWriter(w)
+//	})
+//	s.RegisterCompression("gzip", jsonrpc.NewGzipCompressor)
+func (s *Server) RegisterCompression(coding string, newCompressor func(w io.Writer) (Compressor, error)) {
+	s.Lock()
+	defer s.Unlock()
+	coding = strings.ToLower(coding)
+	// Responses being compressed hold on to the current compressions, so
+	// replace them rather than modify them.
+	added := &compression{coding: coding, new: newCompressor}
+	compressions := make([]*compression, 0, len(s.compressions)+1)
+	replaced := false
+	for _, c := range s.compressions {
+		if c.coding == coding {
+			c, replaced = added, true
+		}
+		compressions = append(compressions, c)
+	}
+	if !replaced {
+		compressions = append(compressions, added)
+	}
+	s.compressions = compressions
+}
+
+// negotiateCompression picks the registered compression preferred by the
+// request's Accept-Encoding header, or nil if none is acceptable.
+func (s *Server) negotiateCompression(r *http.Request) *compression {
+	if c := s.negotiateDictionary(r); c != nil {
+		return c
+	}
+
+	s.Lock()
+	compressions := s.compressions
+	s.Unlock()
+	if len(compressions) == 0 {
+		return nil
+	}
+
+	accepted := parseAcceptEncoding(r.Header.Get("Accept-Encoding"))
+	var best *compression
+	var bestQ float64
+	for _, c := range compressions {
+		q, ok := accepted[c.coding]
+		if !ok {
+			q = accepted["*"]
+		}
+		if q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	return best
+}
+
+// parseAcceptEncoding returns the quality value of each listed coding.
+func parseAcceptEncoding(header string) map[string]float64 {
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		coding, params := part, ""
+		if i := strings.Index(part, ";"); i != -1 {
+			coding, params = part[:i], part[i+1:]
+		}
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		accepted[coding] = q
+	}
+	return accepted
+}
+
+// compressResponseWriter compresses everything written to the response body.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	compression *compression
+	compressor  Compressor
+	err         error
+}
+
+func newCompressResponseWriter(w http.ResponseWriter, c *compression) *compressResponseWriter {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if c.dictionary != "" {
+		w.Header().Add("Vary", DictionaryHeader)
+	}
+	return &compressResponseWriter{ResponseWriter: w, compression: c}
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if status == http.StatusNotModified || status == http.StatusNoContent {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.compressor == nil && w.err == nil {
+		w.Header().Set("Content-Encoding", w.compression.coding)
+		if w.compression.dictionary != "" {
+			w.Header().Set(DictionaryHeader, w.compression.dictionary)
+		}
+		w.Header().Del("Content-Length")
+		w.compressor, w.err = w.compression.get(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if w.compressor == nil && w.err == nil {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.compressor.Write(b)
+}
+
+// Flush flushes the compressor before flushing the connection, so partial
+// results reach the client promptly.
+func (w *compressResponseWriter) Flush() {
+	if flusher, ok := w.compressor.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close finishes the compressed stream and returns the compressor to its pool.
+func (w *compressResponseWriter) Close() (err error) {
+	if w.compressor == nil {
+		return
+	}
+	err = w.compressor.Close()
+	w.compression.pool.Put(w.compressor)
+	w.compressor = nil
+	return
+}
+
+// Decompressor wraps a compressed response body for one content coding.
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+// GzipDecompressor decodes gzip response bodies.
+func GzipDecompressor(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// acceptEncoding returns the Accept-Encoding header advertising the
+// client's decompressors and dictionary codings, in a stable order.
+func (client *Client) acceptEncoding() string {
+	set := make(map[string]bool, len(client.Decompressors)+len(client.Dictionaries))
+	for coding := range client.Decompressors {
+		set[coding] = true
+	}
+	for _, d := range client.Dictionaries {
+		set[strings.ToLower(d.Coding)] = true
+	}
+	codings := make([]string, 0, len(set))
+	for coding := range set {
+		codings = append(codings, coding)
+	}
+	sort.Strings(codings)
+	return strings.Join(codings, ", ")
+}
+
+// decompress replaces the body of resp with its decoded form if it was
+// compressed with one of the client's decompressors.
+func (client *Client) decompress(resp *http.Response) (err error) {
+	coding := strings.ToLower(resp.Header.Get("Content-Encoding"))
+	decompressor, ok := client.decompressorOf(resp, coding)
+	if coding == "" || !ok {
+		return
+	}
+	var body io.ReadCloser
+	if body, err = decompressor(resp.Body); err != nil {
+		resp.Body.Close()
+		return
+	}
+	resp.Body = &decompressedBody{body, resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	return
+}
+
+// decompressedBody closes both the decoder and the underlying body.
+type decompressedBody struct {
+	io.ReadCloser
+	raw io.ReadCloser
+}
+
+func (b *decompressedBody) Close() (err error) {
+	defer checkClose(&err, b.raw)
+	return b.ReadCloser.Close()
+}
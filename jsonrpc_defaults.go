@@ -0,0 +1,85 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// fieldDefault is the default value of an args struct field, declared with
+// a tag such as `jsonrpc:"default=10"`.
+type fieldDefault struct {
+	name  string
+	index []int
+	text  string
+	value reflect.Value
+}
+
+var typeOfDuration = reflect.TypeOf(time.Duration(0))
+
+// defaultsOf parses the defaults declared by the fields of args type t.
+// Defaults of string fields are taken literally, those of time.Duration
+// fields are parsed with time.ParseDuration and all others are JSON.
+func defaultsOf(t reflect.Type) (defaults []fieldDefault, err error) {
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for _, f := range jsonFields(t) {
+		text, ok := "", false
+		for _, opt := range tagOptions(t.FieldByIndex(f.index).Tag.Get("jsonrpc")) {
+			if strings.HasPrefix(opt, "default=") {
+				text, ok = opt[len("default="):], true
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		ft := f.typ
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		value := reflect.New(ft)
+		switch {
+		case ft == typeOfDuration:
+			var d time.Duration
+			if d, err = time.ParseDuration(text); err == nil {
+				value.Elem().SetInt(int64(d))
+			}
+		case ft.Kind() == reflect.String:
+			value.Elem().SetString(text)
+		default:
+			err = json.Unmarshal([]byte(text), value.Interface())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rpc: invalid default of %s.%s: %w", t, f.name, err)
+		}
+		defaults = append(defaults, fieldDefault{f.name, f.index, text, value.Elem()})
+	}
+	return
+}
+
+// applyDefaults sets the declared defaults on args, a pointer to a new
+// args value, before params are decoded over them.
+func (m *methodSpec) applyDefaults(args interface{}) {
+	if len(m.defaults) == 0 {
+		return
+	}
+	v := reflect.ValueOf(args).Elem()
+	for _, d := range m.defaults {
+		field := fieldByIndex(v, d.index)
+		for field.Kind() == reflect.Ptr {
+			field.Set(reflect.New(field.Type().Elem()))
+			field = field.Elem()
+		}
+		switch d.value.Kind() {
+		case reflect.Slice, reflect.Map:
+			// Decode again so that handlers cannot modify the default.
+			json.Unmarshal([]byte(d.text), field.Addr().Interface())
+		default:
+			field.Set(d.value)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package jsonrpc
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type DefaultArgs struct {
+	Limit   int           `json:"limit" jsonrpc:"default=10,required"`
+	After   int           `json:"after" jsonrpc:"required,default=5"`
+	IDs     []int         `json:"ids" jsonrpc:"default=[1,2],required"`
+	Sort    string        `json:"sort" jsonrpc:"default=name, asc"`
+	Timeout time.Duration `json:"timeout" jsonrpc:"default=1m"`
+	Plain   int           `json:"plain"`
+}
+
+func TestDefaultsOf(t *testing.T) {
+	s := new(Server)
+	if err := s.Register("item.list", func(r *http.Request, args *DefaultArgs, reply *DefaultArgs) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := s.get("item.list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := spec.newArgs().(*DefaultArgs)
+	want := &DefaultArgs{Limit: 10, After: 5, IDs: []int{1, 2}, Sort: "name, asc", Timeout: time.Minute}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %+v, want %+v", args, want)
+	}
+
+	tests := []struct {
+		field        string
+		wantRequired bool
+	}{
+		{"limit", true},
+		{"after", true},
+		{"ids", true},
+		{"sort", false},
+		{"plain", false},
+	}
+	fields := make(map[string]bool)
+	for _, f := range jsonFields(reflect.TypeOf(DefaultArgs{})) {
+		fields[f.name] = f.required
+	}
+	for _, tt := range tests {
+		if fields[tt.field] != tt.wantRequired {
+			t.Errorf("%s required = %v, want %v", tt.field, fields[tt.field], tt.wantRequired)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// ResultHashHeader carries the hash of the result of methods registered
+	// WithDelta.
+	ResultHashHeader = "X-Jsonrpc-Result-Hash"
+
+	// DeltaBaseHeader carries, on requests, the hash of the result the
+	// client holds and, on responses, the hash of the result the returned
+	// JSON Patch applies to.
+	DeltaBaseHeader = "X-Jsonrpc-Delta-Base"
+)
+
+// DefaultDeltaHistory is the number of recent results per method kept to
+// compute deltas from.
+const DefaultDeltaHistory = 16
+
+// ErrDeltaBase is returned by CallDelta when the server sent a delta
+// against a result the DeltaState does not hold.
+var ErrDeltaBase = errors.New("rpc: delta against unknown result")
+
+// WithDelta lets clients polling the method with CallDelta receive a JSON
+// Patch against their previous result instead of the full result, when the
+// patch is smaller. The server remembers the last history results, or
+// DefaultDeltaHistory if history is not positive.
+func WithDelta(history int) MethodOption {
+	if history <= 0 {
+		history = DefaultDeltaHistory
+	}
+	return func(m *methodSpec) { m.delta = &deltaHistory{size: history} }
+}
+
+// deltaHistory holds the recent results of a method by hash.
+type deltaHistory struct {
+	sync.Mutex
+	size    int
+	results map[string]json.RawMessage
+	order   []string
+}
+
+// encode returns the result to send for reply given the request's delta
+// base, and sets the delta headers of the response.
+func (h *deltaHistory) encode(w http.ResponseWriter, r *http.Request, reply interface{}) interface{} {
+	result, err := json.Marshal(reply)
+	if err != nil {
+		return reply
+	}
+	hash := resultHash(result)
+	w.Header().Set(ResultHashHeader, hash)
+
+	h.Lock()
+	base, ok := h.results[r.Header.Get(DeltaBaseHeader)]
+	h.remember(hash, result)
+	h.Unlock()
+	if !ok {
+		return json.RawMessage(result)
+	}
+
+	patch, err := diffJSON(base, result)
+	if err != nil || len(patch) >= len(result) {
+		return json.RawMessage(result)
+	}
+	w.Header().Set(DeltaBaseHeader, r.Header.Get(DeltaBaseHeader))
+	return json.RawMessage(patch)
+}
+
+func (h *deltaHistory) remember(hash string, result json.RawMessage) {
+	if h.results == nil {
+		h.results = make(map[string]json.RawMessage)
+	}
+	if _, ok := h.results[hash]; ok {
+		return
+	}
+	h.results[hash] = result
+	h.order = append(h.order, hash)
+	if len(h.order) > h.size {
+		delete(h.results, h.order[0])
+		h.order = h.order[1:]
+	}
+}
+
+func resultHash(result []byte) string {
+	sum := sha256.Sum256(result)
+	return hex.EncodeToString(sum[:16])
+}
+
+// DeltaState is the last result of a method polled with CallDelta. The
+// zero value holds no result. A DeltaState must not be shared by
+// concurrent calls.
+type DeltaState struct {
+	hash   string
+	result json.RawMessage
+}
+
+// Result returns the last result, or nil if there is none.
+func (state *DeltaState) Result() json.RawMessage {
+	return state.result
+}
+
+// CallDelta is like Call for methods registered WithDelta. It sends the
+// hash of the result held by state, applies the delta the server may send
+// instead of the full result, and stores the new result in state.
+func (client *Client) CallDelta(ctx context.Context, url, method string, params interface{}, state *DeltaState, reply interface{}) (err error) {
+	var header http.Header
+	if state.hash != "" {
+		header = http.Header{DeltaBaseHeader: {state.hash}}
+	}
+
+	var result json.RawMessage
+	err = client.do(ctx, url, method, params, header, func(resp *http.Response) (err error) {
+		if err = decodeReply(resp.Body, &result, client.ParseMode); err != nil {
+			return
+		}
+		if base := resp.Header.Get(DeltaBaseHeader); base != "" {
+			if base != state.hash {
+				return ErrDeltaBase
+			}
+			if result, err = applyJSONPatch(state.result, result); err != nil {
+				return
+			}
+		}
+		state.hash, state.result = resp.Header.Get(ResultHashHeader), result
+		return
+	})
+	if err != nil || reply == nil {
+		return
+	}
+	return json.Unmarshal(result, reply)
+}
+
+// patchOperation is a JSON Patch (RFC 6902) operation. Only the add,
+// remove and replace operations are generated and applied.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func newPatchOperation(op, path string, value interface{}) patchOperation {
+	raw, _ := json.Marshal(value)
+	return patchOperation{Op: op, Path: path, Value: raw}
+}
+
+// diffJSON returns the JSON Patch transforming document a into b.
+func diffJSON(a, b []byte) (patch []byte, err error) {
+	var va, vb interface{}
+	if err = unmarshalNumber(a, &va); err != nil {
+		return
+	}
+	if err = unmarshalNumber(b, &vb); err != nil {
+		return
+	}
+	ops := []patchOperation{}
+	diffValue("", va, vb, &ops)
+	return json.Marshal(ops)
+}
+
+func diffValue(path string, a, b interface{}, ops *[]patchOperation) {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		if b, ok := b.(map[string]interface{}); ok {
+			for _, key := range sortedKeys(a) {
+				if vb, ok := b[key]; ok {
+					diffValue(path+"/"+escapePointer(key), a[key], vb, ops)
+				} else {
+					*ops = append(*ops, patchOperation{Op: "remove", Path: path + "/" + escapePointer(key)})
+				}
+			}
+			for _, key := range sortedKeys(b) {
+				if _, ok := a[key]; !ok {
+					*ops = append(*ops, newPatchOperation("add", path+"/"+escapePointer(key), b[key]))
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if b, ok := b.([]interface{}); ok {
+			for i := 0; i < len(a) && i < len(b); i++ {
+				diffValue(path+"/"+strconv.Itoa(i), a[i], b[i], ops)
+			}
+			for i := len(a); i < len(b); i++ {
+				*ops = append(*ops, newPatchOperation("add", path+"/-", b[i]))
+			}
+			for i := len(a) - 1; i >= len(b); i-- {
+				*ops = append(*ops, patchOperation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		*ops = append(*ops, newPatchOperation("replace", path, b))
+	}
+}
+
+// applyJSONPatch applies a JSON Patch to a document.
+func applyJSONPatch(doc, patch []byte) (result []byte, err error) {
+	var ops []patchOperation
+	if err = json.Unmarshal(patch, &ops); err != nil {
+		return
+	}
+	var v interface{}
+	if err = unmarshalNumber(doc, &v); err != nil {
+		return
+	}
+	for _, op := range ops {
+		var tokens []string
+		if op.Path != "" {
+			if !strings.HasPrefix(op.Path, "/") {
+				return nil, fmt.Errorf("rpc: invalid patch path %q", op.Path)
+			}
+			tokens = strings.Split(op.Path[1:], "/")
+		}
+		if v, err = patchValue(v, tokens, op); err != nil {
+			return
+		}
+	}
+	return json.Marshal(v)
+}
+
+func patchValue(v interface{}, tokens []string, op patchOperation) (interface{}, error) {
+	switch op.Op {
+	case "add", "remove", "replace":
+	default:
+		return nil, fmt.Errorf("rpc: unsupported patch operation %q", op.Op)
+	}
+	var value interface{}
+	if op.Op != "remove" {
+		if err := unmarshalNumber(op.Value, &value); err != nil {
+			return nil, err
+		}
+	}
+	return patchPath(v, tokens, op.Op, value)
+}
+
+func patchPath(v interface{}, tokens []string, op string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	token, last := unescapePointer(tokens[0]), len(tokens) == 1
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		child, ok := v[token]
+		switch {
+		case !ok && !(last && op == "add"):
+			return nil, fmt.Errorf("rpc: patch path member %q not found", token)
+		case last && op == "remove":
+			delete(v, token)
+		case last:
+			v[token] = value
+		default:
+			var err error
+			if v[token], err = patchPath(child, tokens[1:], op, value); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+
+	case []interface{}:
+		if last && op == "add" && token == "-" {
+			return append(v, value), nil
+		}
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i > len(v) || (i == len(v) && !(last && op == "add")) {
+			return nil, fmt.Errorf("rpc: patch path index %q out of range", token)
+		}
+		switch {
+		case last && op == "add":
+			v = append(v, nil)
+			copy(v[i+1:], v[i:])
+			v[i] = value
+		case last && op == "remove":
+			v = append(v[:i], v[i+1:]...)
+		case last:
+			v[i] = value
+		default:
+			if v[i], err = patchPath(v[i], tokens[1:], op, value); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("rpc: patch path member %q of a scalar", token)
+}
+
+func unmarshalNumber(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+func escapePointer(token string) string {
+	return pointerEscaper.Replace(token)
+}
+
+func unescapePointer(token string) string {
+	return pointerUnescaper.Replace(token)
+}
@@ -0,0 +1,148 @@
+package jsonrpc
+
+import (
+	"compress/flate"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DictionaryHeader lists, on requests, the ids of the compression
+// dictionaries the client holds, and names, on responses, the dictionary
+// the body was compressed with.
+const DictionaryHeader = "X-Jsonrpc-Dictionary"
+
+// DeflateDictCoding is the content coding of deflate with a dictionary.
+// It is a token of its own rather than "deflate", since proxies and
+// clients decoding deflate responses do not hold the dictionary.
+const DeflateDictCoding = "x-deflate-dict"
+
+// DictionaryDecompressor decodes responses compressed with one dictionary.
+type DictionaryDecompressor struct {
+	// Coding is the content coding the dictionary is used with.
+	Coding string
+
+	Decompressor Decompressor
+}
+
+// NewDeflateDictCompressor creates deflate compressors primed with dict for
+// RegisterDictionary. Dictionaries for other codings, such as zstd, can be
+// plugged in the same way with third-party encoders.
+func NewDeflateDictCompressor(dict []byte) func(w io.Writer) (Compressor, error) {
+	return func(w io.Writer) (Compressor, error) {
+		return flate.NewWriterDict(w, flate.DefaultCompression, dict)
+	}
+}
+
+// DeflateDictDecompressor decodes responses compressed by a
+// NewDeflateDictCompressor with the same dict.
+func DeflateDictDecompressor(dict []byte) DictionaryDecompressor {
+	return DictionaryDecompressor{
+		Coding: DeflateDictCoding,
+		Decompressor: func(r io.Reader) (io.ReadCloser, error) {
+			return flate.NewReaderDict(r, dict), nil
+		},
+	}
+}
+
+// BuildDictionary builds a dictionary of at most size bytes from samples of
+// typical payloads. Later samples are placed at the end of the dictionary,
+// where they are cheapest to reference, so samples should be ordered from
+// least to most representative.
+func BuildDictionary(samples [][]byte, size int) []byte {
+	dict := make([]byte, 0, size)
+	for i := len(samples) - 1; i >= 0 && len(dict) < size; i-- {
+		sample := samples[i]
+		if room := size - len(dict); len(sample) > room {
+			sample = sample[len(sample)-room:]
+		}
+		dict = append(append([]byte(nil), sample...), dict...)
+	}
+	return dict
+}
+
+// RegisterDictionary enables response compression with a precomputed
+// dictionary, identified by id, for clients listing the id in
+// DictionaryHeader and coding in Accept-Encoding. coding must be a token
+// of its own, such as DeflateDictCoding, not one intermediaries decode
+// without the dictionary. A dictionary the client holds is preferred over
+// the compressions of RegisterCompression; when it holds several, the one
+// registered first wins. The server names the dictionary it used in the
+// DictionaryHeader of the response.
+//
+//	dict := jsonrpc.BuildDictionary(samples, 32<<10)
+//	s.RegisterDictionary("v1", jsonrpc.DeflateDictCoding, jsonrpc.NewDeflateDictCompressor(dict))
+//	client.Dictionaries = map[string]jsonrpc.DictionaryDecompressor{
+//		"v1": jsonrpc.DeflateDictDecompressor(dict),
+//	}
+func (s *Server) RegisterDictionary(id, coding string, newCompressor func(w io.Writer) (Compressor, error)) {
+	s.Lock()
+	defer s.Unlock()
+	c := &compression{coding: strings.ToLower(coding), dictionary: id, new: newCompressor}
+	// Replace rather than update in place so that pooled compressors primed
+	// with the old dictionary are dropped.
+	dictionaries := make([]*compression, 0, len(s.dictionaries)+1)
+	for _, d := range s.dictionaries {
+		if d.dictionary != id {
+			dictionaries = append(dictionaries, d)
+		} else if c != nil {
+			dictionaries = append(dictionaries, c)
+			c = nil
+		}
+	}
+	if c != nil {
+		dictionaries = append(dictionaries, c)
+	}
+	s.dictionaries = dictionaries
+}
+
+// negotiateDictionary picks the first registered dictionary the request's
+// DictionaryHeader lists and whose coding its Accept-Encoding accepts, or
+// nil if there is none.
+func (s *Server) negotiateDictionary(r *http.Request) *compression {
+	s.Lock()
+	dictionaries := s.dictionaries
+	s.Unlock()
+	if len(dictionaries) == 0 {
+		return nil
+	}
+
+	held := make(map[string]bool)
+	for _, header := range r.Header.Values(DictionaryHeader) {
+		for _, id := range strings.Split(header, ",") {
+			held[strings.TrimSpace(id)] = true
+		}
+	}
+	accepted := parseAcceptEncoding(r.Header.Get("Accept-Encoding"))
+	for _, c := range dictionaries {
+		if held[c.dictionary] && accepted[c.coding] > 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+// dictionaryIDs returns the DictionaryHeader advertising the client's
+// dictionaries, in a stable order.
+func (client *Client) dictionaryIDs() string {
+	ids := make([]string, 0, len(client.Dictionaries))
+	for id := range client.Dictionaries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ", ")
+}
+
+// decompressorOf returns the decompressor of resp's content coding,
+// preferring the dictionary named by the response.
+func (client *Client) decompressorOf(resp *http.Response, coding string) (decompressor Decompressor, ok bool) {
+	if id := resp.Header.Get(DictionaryHeader); id != "" {
+		if d, held := client.Dictionaries[id]; held && strings.EqualFold(d.Coding, coding) {
+			resp.Header.Del(DictionaryHeader)
+			return d.Decompressor, true
+		}
+	}
+	decompressor, ok = client.Decompressors[coding]
+	return
+}
@@ -0,0 +1,70 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type DictArgs struct{}
+
+type DictReply struct {
+	Text string `json:"text"`
+}
+
+func TestDictionaryNegotiation(t *testing.T) {
+	dict := BuildDictionary([][]byte{[]byte(`{"jsonrpc":"2.0","result":{"text":"`)}, 1024)
+	text := strings.Repeat("dictionary ", 100)
+	s := new(Server)
+	s.RegisterDictionary("v1", DeflateDictCoding, NewDeflateDictCompressor(dict))
+	if err := s.Register("dict.get", func(r *http.Request, args *DictArgs, reply *DictReply) error {
+		reply.Text = text
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		dictionaries   string
+		wantCoding     string
+	}{
+		{"negotiated", DeflateDictCoding, "v1", DeflateDictCoding},
+		{"coding not accepted", "deflate, gzip", "v1", ""},
+		{"wildcard", "*", "v1", ""},
+		{"dictionary not held", DeflateDictCoding, "v2", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", ts.URL, bytes.NewReader([]byte(`{"jsonrpc":"2.0","id":1,"method":"dict.get"}`)))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			req.Header.Set(DictionaryHeader, tt.dictionaries)
+			resp, err := http.DefaultTransport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if got := resp.Header.Get("Content-Encoding"); got != tt.wantCoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantCoding)
+			}
+		})
+	}
+
+	t.Run("client", func(t *testing.T) {
+		client := &Client{Dictionaries: map[string]DictionaryDecompressor{"v1": DeflateDictDecompressor(dict)}}
+		var reply DictReply
+		if err := client.Call(context.Background(), ts.URL, "dict.get", &DictArgs{}, &reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply.Text != text {
+			t.Errorf("text = %q, want %q", reply.Text, text)
+		}
+	})
+}
@@ -0,0 +1,235 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// DiscoverMethod is the method OpenRPC servers describe themselves with.
+const DiscoverMethod = "rpc.discover"
+
+// ReadOpenRPC reads an OpenRPC document from a file, e.g. one published by
+// the vendor of an endpoint that does not answer rpc.discover.
+func ReadOpenRPC(path string) (doc *OpenRPCDocument, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return
+	}
+	doc = new(OpenRPCDocument)
+	if err = json.Unmarshal(data, doc); err != nil {
+		doc = nil
+	}
+	return
+}
+
+// Discover asks the server at url to describe its methods with
+// rpc.discover. If the server has no such method, Discover returns
+// fallback instead, such as a document read with ReadOpenRPC or learned
+// with InferOpenRPC, if not nil. Other errors are returned as they are.
+func (client *Client) Discover(ctx context.Context, url string, fallback *OpenRPCDocument) (doc *OpenRPCDocument, err error) {
+	doc = new(OpenRPCDocument)
+	if err = client.Call(ctx, url, DiscoverMethod, nil, doc); err != nil {
+		var rpcErr *Error
+		if fallback != nil && errors.As(err, &rpcErr) && rpcErr.Code == E_NO_METHOD {
+			return fallback, nil
+		}
+		return nil, err
+	}
+	return
+}
+
+// ObservedCall is a successful call seen in recorded traffic.
+type ObservedCall struct {
+	Method string
+	Params json.RawMessage
+	Result json.RawMessage
+}
+
+// InferOpenRPC learns an OpenRPC document from recorded calls, for
+// endpoints that neither answer rpc.discover nor publish a document. Param
+// and result schemas are inferred from the JSON values seen: a param is
+// required if every call passed it, and values of differing types are
+// left untyped.
+func InferOpenRPC(info OpenRPCInfo, calls []ObservedCall) *OpenRPCDocument {
+	type observed struct {
+		calls     int
+		byName    int                // calls passing params by name
+		named     map[string]*Schema // by name
+		passed    map[string]int     // calls passing each named param
+		byOrder   int                // calls passing params by position
+		positions []*Schema
+		minLen    int // fewest params passed by position
+		result    *Schema
+	}
+	methods := make(map[string]*observed)
+	for _, call := range calls {
+		m := methods[call.Method]
+		if m == nil {
+			m = &observed{named: make(map[string]*Schema), passed: make(map[string]int)}
+			methods[call.Method] = m
+		}
+		m.calls++
+		var params interface{}
+		json.Unmarshal(call.Params, &params)
+		switch params := params.(type) {
+		case map[string]interface{}:
+			m.byName++
+			for key, value := range params {
+				m.named[key] = mergeSchema(m.named[key], schemaOfValue(value))
+				m.passed[key]++
+			}
+		case []interface{}:
+			if m.byOrder == 0 || len(params) < m.minLen {
+				m.minLen = len(params)
+			}
+			m.byOrder++
+			for i, value := range params {
+				if i == len(m.positions) {
+					m.positions = append(m.positions, nil)
+				}
+				m.positions[i] = mergeSchema(m.positions[i], schemaOfValue(value))
+			}
+		}
+		m.result = mergeSchema(m.result, inferSchema(call.Result))
+	}
+
+	names := make([]string, 0, len(methods))
+	for name := range methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	doc := &OpenRPCDocument{OpenRPC: OpenRPCVersion, Info: info}
+	for _, name := range names {
+		m := methods[name]
+		method := &OpenRPCMethod{
+			Name:           name,
+			ParamStructure: "by-name",
+			Params:         []*ContentDescriptor{},
+			Result:         &ContentDescriptor{Name: "result", Schema: orAny(m.result)},
+		}
+		switch {
+		case m.byOrder == 0:
+			for _, key := range sortedProperties(m.named) {
+				method.Params = append(method.Params, &ContentDescriptor{
+					Name:     key,
+					Required: m.passed[key] == m.calls,
+					Schema:   orAny(m.named[key]),
+				})
+			}
+		case m.byName == 0:
+			method.ParamStructure = "by-position"
+			for i, schema := range m.positions {
+				method.Params = append(method.Params, &ContentDescriptor{
+					Name:     "param" + strconv.Itoa(i+1),
+					Required: i < m.minLen && m.byOrder == m.calls,
+					Schema:   orAny(schema),
+				})
+			}
+		default:
+			// Mixed structures cannot be described param by param.
+			method.ParamStructure = "either"
+		}
+		doc.Methods = append(doc.Methods, method)
+	}
+	return doc
+}
+
+func sortedProperties(m map[string]*Schema) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func orAny(schema *Schema) *Schema {
+	if schema == nil {
+		return &Schema{}
+	}
+	return schema
+}
+
+// inferSchema returns the schema of a JSON value, or nil for null or a
+// missing value, which tell nothing about the type.
+func inferSchema(raw json.RawMessage) *Schema {
+	var v interface{}
+	if len(raw) == 0 || json.Unmarshal(raw, &v) != nil {
+		return nil
+	}
+	return schemaOfValue(v)
+}
+
+// schemaOfValue returns the schema of a decoded JSON value, or nil for
+// null.
+func schemaOfValue(v interface{}) *Schema {
+	switch v := v.(type) {
+	case bool:
+		return &Schema{Type: "boolean"}
+	case float64:
+		if v == float64(int64(v)) {
+			return &Schema{Type: "integer"}
+		}
+		return &Schema{Type: "number"}
+	case string:
+		return &Schema{Type: "string"}
+	case []interface{}:
+		schema := &Schema{Type: "array"}
+		for _, elem := range v {
+			schema.Items = mergeSchema(schema.Items, schemaOfValue(elem))
+		}
+		return schema
+	case map[string]interface{}:
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		for key, value := range v {
+			schema.Properties[key] = orAny(schemaOfValue(value))
+			schema.Required = append(schema.Required, key)
+		}
+		sort.Strings(schema.Required)
+		return schema
+	}
+	return nil
+}
+
+// mergeSchema returns a schema admitting the values of both a and b.
+// Properties are required if they are in both.
+func mergeSchema(a, b *Schema) *Schema {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.Type != b.Type:
+		if (a.Type == "integer" || a.Type == "number") && (b.Type == "integer" || b.Type == "number") {
+			return &Schema{Type: "number"}
+		}
+		return &Schema{}
+	case a.Type == "array":
+		return &Schema{Type: "array", Items: mergeSchema(a.Items, b.Items)}
+	case a.Type == "object":
+		merged := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		for key, schema := range a.Properties {
+			merged.Properties[key] = schema
+		}
+		for key, schema := range b.Properties {
+			if other, ok := merged.Properties[key]; ok {
+				merged.Properties[key] = orAny(mergeSchema(other, schema))
+			} else {
+				merged.Properties[key] = schema
+			}
+		}
+		inB := stringSet(b.Required)
+		for _, key := range a.Required {
+			if inB[key] {
+				merged.Required = append(merged.Required, key)
+			}
+		}
+		return merged
+	}
+	return a
+}
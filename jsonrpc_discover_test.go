@@ -0,0 +1,38 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscoverFallback(t *testing.T) {
+	fallback := &OpenRPCDocument{Info: OpenRPCInfo{Title: "fallback"}}
+	tests := []struct {
+		name         string
+		code         ErrorCode
+		wantFallback bool
+	}{
+		{"no method", E_NO_METHOD, true},
+		{"internal error", E_INTERNAL, false},
+		{"unauthorized", 1000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"error":{"code":%d,"message":"failed"}}`, tt.code)
+			}))
+			defer ts.Close()
+			doc, err := new(Client).Discover(context.Background(), ts.URL, fallback)
+			if tt.wantFallback {
+				if err != nil || doc != fallback {
+					t.Errorf("Discover = %v, %v, want the fallback", doc, err)
+				}
+			} else if err == nil {
+				t.Errorf("Discover = %v, want error", doc)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// Request is a decoded JSON-RPC request.
+type Request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// Response is a decoded JSON-RPC response.
+type Response struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// Dispatcher runs JSON-RPC messages through a Server's registry,
+// middleware and validation without an HTTP transport, so the same methods
+// can be served over queues, CLIs, test harnesses or custom transports.
+//
+// Handlers called through a Dispatcher receive an *http.Request that only
+// carries the context passed to Handle.
+type Dispatcher struct {
+	server *Server
+}
+
+// Dispatcher returns a Dispatcher for the Server's methods.
+func (s *Server) Dispatcher() *Dispatcher {
+	return &Dispatcher{s}
+}
+
+// Handle dispatches an encoded request and returns the encoded response.
+func (d *Dispatcher) Handle(ctx context.Context, raw []byte) []byte {
+	return d.handle(ctx, raw, nil)
+}
+
+// handle dispatches an encoded request with the given headers.
+func (d *Dispatcher) handle(ctx context.Context, raw []byte, header http.Header) []byte {
+	if header = header.Clone(); header == nil {
+		header = make(http.Header)
+	}
+	r := &http.Request{
+		Method:        "POST",
+		URL:           &url.URL{},
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(raw)),
+		ContentLength: int64(len(raw)),
+	}
+	w := &bufferResponseWriter{header: make(http.Header)}
+	if r = r.WithContext(ctx); isBatch(r) {
+		d.server.serveBatch(w, r)
+	} else {
+		d.server.serve(w, r)
+	}
+	return bytes.TrimRight(w.body.Bytes(), "\n")
+}
+
+// HandleRequest dispatches a decoded request and returns the decoded response.
+func (d *Dispatcher) HandleRequest(ctx context.Context, req *Request) (resp *Response, err error) {
+	var raw []byte
+	if raw, err = json.Marshal(req); err != nil {
+		return
+	}
+	resp = new(Response)
+	if err = json.Unmarshal(d.Handle(ctx, raw), resp); err != nil {
+		resp = nil
+	}
+	return
+}
+
+// bufferResponseWriter collects a response in memory.
+type bufferResponseWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func (w *bufferResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
@@ -0,0 +1,109 @@
+package jsonrpc
+
+// MethodDoc documents a registered method for introspection and generated
+// documentation.
+type MethodDoc struct {
+	// A short, single line description of the method.
+	Summary string `json:"summary,omitempty"`
+
+	// A longer explanation of the method's behaviour.
+	Description string `json:"description,omitempty"`
+
+	// Descriptions of the individual params.
+	Params []ParamDoc `json:"params,omitempty"`
+
+	// Example calls.
+	Examples []Example `json:"examples,omitempty"`
+
+	// Tags used to group related methods.
+	Tags []string `json:"tags,omitempty"`
+
+	// Deprecated marks methods that clients should stop calling.
+	Deprecated bool `json:"deprecated,omitempty"`
+}
+
+// ParamDoc documents a single member of a method's params.
+type ParamDoc struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// Example is a sample pair of params and result.
+type Example struct {
+	Name   string      `json:"name,omitempty"`
+	Params interface{} `json:"params"`
+	Result interface{} `json:"result"`
+}
+
+// MethodOption configures a method at registration time.
+type MethodOption func(*methodSpec)
+
+// WithReplace lets the registration replace a method registered under the
+// same name instead of failing, e.g. when reloading plugins.
+func WithReplace() MethodOption {
+	return func(m *methodSpec) { m.replace = true }
+}
+
+// WithReplyOnly marks a handler registered as func(*http.Request, *Reply)
+// error, whose single pointer argument would otherwise be taken for the
+// args.
+func WithReplyOnly() MethodOption {
+	return func(m *methodSpec) {
+		if m.noArgs || !m.noReply || m.returnsReply {
+			return
+		}
+		m.argsType, m.replyType = typeOfNoArgs, m.argsType
+		m.noArgs, m.noReply = true, false
+	}
+}
+
+// WithDoc sets the documentation of the method, replacing any set so far.
+func WithDoc(doc MethodDoc) MethodOption {
+	return func(m *methodSpec) { m.doc = doc }
+}
+
+// WithSummary sets the summary of the method.
+func WithSummary(summary string) MethodOption {
+	return func(m *methodSpec) { m.doc.Summary = summary }
+}
+
+// WithDescription sets the description of the method.
+func WithDescription(description string) MethodOption {
+	return func(m *methodSpec) { m.doc.Description = description }
+}
+
+// WithParamDoc documents a member of the method's params.
+func WithParamDoc(name, description string, required bool) MethodOption {
+	return func(m *methodSpec) {
+		m.doc.Params = append(m.doc.Params, ParamDoc{name, description, required})
+	}
+}
+
+// WithExample adds an example call of the method.
+func WithExample(name string, params, result interface{}) MethodOption {
+	return func(m *methodSpec) {
+		m.doc.Examples = append(m.doc.Examples, Example{name, params, result})
+	}
+}
+
+// WithTags adds tags to the method.
+func WithTags(tags ...string) MethodOption {
+	return func(m *methodSpec) { m.doc.Tags = append(m.doc.Tags, tags...) }
+}
+
+// WithDeprecated marks the method as deprecated.
+func WithDeprecated() MethodOption {
+	return func(m *methodSpec) { m.doc.Deprecated = true }
+}
+
+// MethodDoc returns the documentation registered for method.
+func (s *Server) MethodDoc(method string) (doc MethodDoc, ok bool) {
+	s.Lock()
+	defer s.Unlock()
+	spec, ok := s.methods[method]
+	if ok {
+		doc = spec.doc
+	}
+	return
+}
@@ -0,0 +1,59 @@
+package jsonrpc
+
+import (
+	"context"
+	"time"
+)
+
+// GoingAwayMethod is the notification sent to the sessions of a draining
+// Server.
+const GoingAwayMethod = "rpc.goingAway"
+
+// GoingAway are the params of the rpc.goingAway notification.
+type GoingAway struct {
+	// GraceMs is how long, in milliseconds, the server keeps the
+	// connection open for the client to finish and reconnect elsewhere.
+	GraceMs int64 `json:"graceMs"`
+
+	// Reason is a human-readable explanation, such as "restart".
+	Reason string `json:"reason,omitempty"`
+}
+
+// Drain prepares the Server for shutdown: it stops serving new connections,
+// sends an rpc.goingAway notification to every open session so clients can
+// fail over proactively, and waits for the sessions to end. Sessions still
+// open after grace, or once ctx is done, are closed.
+func (s *Server) Drain(ctx context.Context, grace time.Duration, reason string) {
+	s.Lock()
+	s.draining = true
+	s.Unlock()
+
+	sessions := s.Sessions()
+	params := &GoingAway{GraceMs: grace.Milliseconds(), Reason: reason}
+	for _, session := range sessions {
+		session.Notify(GoingAwayMethod, params)
+	}
+
+	timer := s.clock().NewTimer(grace)
+	defer timer.Stop()
+wait:
+	for _, session := range sessions {
+		select {
+		case <-session.Done():
+		case <-timer.C():
+			break wait
+		case <-ctx.Done():
+			break wait
+		}
+	}
+	for _, session := range sessions {
+		session.Close()
+	}
+}
+
+// Draining reports whether Drain has been called.
+func (s *Server) Draining() bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.draining
+}
@@ -0,0 +1,154 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Dynamic is a JSON value whose shape is not known ahead of time, for
+// exploratory tooling. It decodes into the generic interface{} tree of
+// encoding/json, with numbers kept as json.Number, and supports lookups by
+// dotted path:
+//
+//	var result jsonrpc.Dynamic
+//	err := client.Call(ctx, url, "download.list", nil, &result)
+//	id := result.Get("items.0.id").String()
+type Dynamic struct {
+	value interface{}
+	ok    bool
+}
+
+// NewDynamic wraps an already decoded value.
+func NewDynamic(v interface{}) *Dynamic {
+	return &Dynamic{value: v, ok: true}
+}
+
+// CallDynamic calls method and returns its result as a Dynamic.
+func (client *Client) CallDynamic(ctx context.Context, url, method string, params interface{}) (result *Dynamic, err error) {
+	result = new(Dynamic)
+	err = client.Call(ctx, url, method, params, result)
+	return
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Dynamic) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	d.value, d.ok = v, true
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d *Dynamic) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.value)
+}
+
+// Get returns the value at path, a dot separated list of object member
+// names and array indexes such as "items.0.id". The empty path is d
+// itself. Missing values report false from Exists.
+func (d *Dynamic) Get(path string) *Dynamic {
+	if path == "" {
+		return d
+	}
+	v, ok := d.value, d.ok
+	for _, key := range strings.Split(path, ".") {
+		if !ok {
+			break
+		}
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v, ok = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if ok = err == nil && i >= 0 && i < len(node); ok {
+				v = node[i]
+			}
+		default:
+			ok = false
+		}
+	}
+	if !ok {
+		return &Dynamic{}
+	}
+	return &Dynamic{value: v, ok: true}
+}
+
+// Exists reports whether the value is present. A JSON null is present.
+func (d *Dynamic) Exists() bool {
+	return d.ok
+}
+
+// Value returns the underlying value: nil, bool, json.Number, string,
+// []interface{} or map[string]interface{}.
+func (d *Dynamic) Value() interface{} {
+	return d.value
+}
+
+// String returns strings as they are and other values as JSON. Missing
+// values return the empty string.
+func (d *Dynamic) String() string {
+	if !d.ok {
+		return ""
+	}
+	if s, ok := d.value.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(d.value)
+	return string(b)
+}
+
+// Int returns the value as an integer, or 0 if it is not a number.
+func (d *Dynamic) Int() int64 {
+	n, _ := d.value.(json.Number)
+	i, err := n.Int64()
+	if err != nil {
+		f, _ := n.Float64()
+		i = int64(f)
+	}
+	return i
+}
+
+// Float returns the value as a float, or 0 if it is not a number.
+func (d *Dynamic) Float() float64 {
+	n, _ := d.value.(json.Number)
+	f, _ := n.Float64()
+	return f
+}
+
+// Bool returns the value as a bool, or false if it is not a bool.
+func (d *Dynamic) Bool() bool {
+	b, _ := d.value.(bool)
+	return b
+}
+
+// Len returns the number of elements of an array or members of an object.
+func (d *Dynamic) Len() int {
+	switch v := d.value.(type) {
+	case []interface{}:
+		return len(v)
+	case map[string]interface{}:
+		return len(v)
+	}
+	return 0
+}
+
+// Decode decodes the value into v, for switching to static types once the
+// shape is known.
+func (d *Dynamic) Decode(v interface{}) error {
+	if !d.ok {
+		return errors.New("rpc: decode of missing value")
+	}
+	b, err := json.Marshal(d.value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
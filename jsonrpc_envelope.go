@@ -0,0 +1,84 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// Envelope is a complete decoded response: its id, raw result and error,
+// plus any members beyond those the specification defines, such as meta
+// information some servers add.
+type Envelope struct {
+	Response
+
+	// Extensions holds the members other than jsonrpc, id, result and error.
+	Extensions map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *Envelope) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &e.Response); err != nil {
+		return err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	e.Extensions = extensions(members)
+	return nil
+}
+
+// extensions removes the members the specification defines and returns
+// the rest, or nil.
+func extensions(members map[string]json.RawMessage) map[string]json.RawMessage {
+	for _, name := range []string{"jsonrpc", "id", "result", "error"} {
+		delete(members, name)
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	return members
+}
+
+// DecodeEnvelope is like DecodeReply but also returns the envelope of the
+// response. The envelope is returned even when the response carries an
+// error.
+func DecodeEnvelope(r io.Reader, reply interface{}) (envelope *Envelope, err error) {
+	return decodeEnvelope(r, reply, ParseDefault)
+}
+
+// decodeEnvelope decodes a response according to mode. Responses rejected
+// in ParseStrict mode have no envelope.
+func decodeEnvelope(r io.Reader, reply interface{}, mode ParseMode) (envelope *Envelope, err error) {
+	var data json.RawMessage
+	if err = json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+	var members map[string]json.RawMessage
+	if err = json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	err = decodeReply(bytes.NewReader(data), reply, mode)
+	if errors.Is(err, ErrInvalidResponse) {
+		return nil, err
+	}
+	envelope = &Envelope{Response: Response{ID: members["id"], Result: members["result"]}}
+	json.Unmarshal(members["jsonrpc"], &envelope.Version)
+	envelope.Error, _ = err.(*Error)
+	envelope.Extensions = extensions(members)
+	return
+}
+
+// CallEnvelope is like Call but also returns the envelope of the response.
+// The response is checked according to ParseMode.
+func (client *Client) CallEnvelope(ctx context.Context, url, method string, params, reply interface{}) (envelope *Envelope, err error) {
+	err = client.do(ctx, url, method, params, nil, func(resp *http.Response) (err error) {
+		envelope, err = decodeEnvelope(resp.Body, reply, client.ParseMode)
+		return
+	})
+	return
+}
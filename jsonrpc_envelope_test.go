@@ -0,0 +1,60 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCallEnvelopeParseMode(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         ParseMode
+		response     string
+		wantEnvelope bool
+		wantErr      error
+		wantCode     ErrorCode
+	}{
+		{"default", ParseDefault, `{"jsonrpc":"2.0","id":1,"result":1,"meta":{}}`, true, nil, 0},
+		{"strict rejects missing version", ParseStrict, `{"id":1,"result":1}`, false, ErrInvalidResponse, 0},
+		{"strict accepts valid", ParseStrict, `{"jsonrpc":"2.0","id":1,"result":1,"meta":{}}`, true, nil, 0},
+		{"lenient string error", ParseLenient, `{"jsonrpc":"2.0","id":1,"error":"boom","meta":{}}`, true, nil, E_SERVER},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.response))
+			}))
+			defer ts.Close()
+
+			client := &Client{ParseMode: tt.mode}
+			var reply int
+			envelope, err := client.CallEnvelope(context.Background(), ts.URL, "m", nil, &reply)
+			var rpcErr *Error
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantCode != 0:
+				if !errors.As(err, &rpcErr) || rpcErr.Code != tt.wantCode {
+					t.Fatalf("err = %v, want code %d", err, tt.wantCode)
+				}
+			case err != nil:
+				t.Fatal(err)
+			}
+			if (envelope != nil) != tt.wantEnvelope {
+				t.Fatalf("envelope = %v, want envelope %v", envelope, tt.wantEnvelope)
+			}
+			if envelope != nil && envelope.Extensions["meta"] == nil {
+				t.Errorf("extensions = %v, want meta", envelope.Extensions)
+			}
+			if rpcErr != nil && envelope.Error != rpcErr {
+				t.Errorf("envelope error = %v, want %v", envelope.Error, rpcErr)
+			}
+		})
+	}
+}
@@ -32,3 +32,9 @@ type Error struct {
 func (e *Error) Error() string {
 	return e.Message
 }
+
+// errorOf returns the *Error that err is or wraps.
+func errorOf(err error) (jsonErr *Error, ok bool) {
+	ok = errors.As(err, &jsonErr)
+	return
+}
@@ -0,0 +1,116 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// allErrors returns the predefined and the registered definitions along
+// with their identifiers, failing if two names map to the same one.
+func (reg *ErrorRegistry) allErrors() (defs []ErrorDef, names []string, err error) {
+	defs = append(append([]ErrorDef(nil), predefinedErrors...), reg.Errors()...)
+	seen := make(map[string]ErrorDef, len(defs))
+	for _, def := range defs {
+		name := identifier(def.Name)
+		if other, ok := seen[name]; ok {
+			return nil, nil, fmt.Errorf("rpc: errors %q (%d) and %q (%d) both map to identifier %s", other.Name, other.Code, def.Name, def.Code, name)
+		}
+		seen[name] = def
+		names = append(names, name)
+	}
+	return
+}
+
+// GenerateGo writes a Go source file for package pkg declaring a constant
+// per error code, named Code<Name>, and a map of their messages, so client
+// code never hard-codes codes that drift from the server.
+func (reg *ErrorRegistry) GenerateGo(w io.Writer, pkg string) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by jsonrpc.ErrorRegistry.GenerateGo. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	fmt.Fprintf(&buf, "import \"github.com/go-webdl/jsonrpc\"\n\n")
+
+	defs, names, err := reg.allErrors()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&buf, "const (\n")
+	for i, def := range defs {
+		if def.Description != "" {
+			fmt.Fprintf(&buf, "// Code%s: %s\n", names[i], def.Description)
+		}
+		fmt.Fprintf(&buf, "Code%s jsonrpc.ErrorCode = %d\n", names[i], def.Code)
+	}
+	fmt.Fprintf(&buf, ")\n\n")
+
+	fmt.Fprintf(&buf, "// ErrorMessages maps each error code to its message.\n")
+	fmt.Fprintf(&buf, "var ErrorMessages = map[jsonrpc.ErrorCode]string{\n")
+	for i, def := range defs {
+		fmt.Fprintf(&buf, "Code%s: %s,\n", names[i], strconv.Quote(def.Description))
+	}
+	fmt.Fprintf(&buf, "}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// GenerateTypeScript writes a TypeScript module exporting the error codes
+// and their messages.
+func (reg *ErrorRegistry) GenerateTypeScript(w io.Writer) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by jsonrpc.ErrorRegistry.GenerateTypeScript. DO NOT EDIT.\n\n")
+
+	defs, names, err := reg.allErrors()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&buf, "export const ErrorCode = {\n")
+	for i, def := range defs {
+		fmt.Fprintf(&buf, "  %s: %d,\n", names[i], def.Code)
+	}
+	fmt.Fprintf(&buf, "} as const;\n\n")
+	fmt.Fprintf(&buf, "export type ErrorCode = (typeof ErrorCode)[keyof typeof ErrorCode];\n\n")
+
+	fmt.Fprintf(&buf, "export const ErrorMessages: Record<number, string> = {\n")
+	for i, def := range defs {
+		fmt.Fprintf(&buf, "  [ErrorCode.%s]: %s,\n", names[i], strconv.Quote(def.Description))
+	}
+	fmt.Fprintf(&buf, "};\n")
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// identifier turns an error name such as "not_found" or "quota exceeded"
+// into an exported identifier such as NotFound or QuotaExceeded.
+func identifier(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(r) {
+			b.WriteRune('_')
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "Unnamed"
+	}
+	return b.String()
+}
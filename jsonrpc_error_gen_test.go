@@ -0,0 +1,35 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGenerateErrorsCollision(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		wantErr bool
+	}{
+		{"distinct", []string{"not_found", "rate limited"}, false},
+		{"same identifier", []string{"not_found", "not found"}, true},
+		{"predefined identifier", []string{"parse error"}, true},
+		{"case", []string{"Conflict", "conflict"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := new(ErrorRegistry)
+			for i, name := range tt.names {
+				reg.MustRegister(ErrorCode(1000+i), name, "")
+			}
+			for _, generate := range []func() error{
+				func() error { return reg.GenerateGo(new(bytes.Buffer), "errs") },
+				func() error { return reg.GenerateTypeScript(new(bytes.Buffer)) },
+			} {
+				if err := generate(); (err != nil) != tt.wantErr {
+					t.Errorf("err = %v, want error %v", err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package jsonrpc
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrorDef declares an application error code.
+type ErrorDef struct {
+	Code        ErrorCode `json:"code"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+}
+
+// New returns an *Error with the declared code, using the description as
+// its message.
+func (def ErrorDef) New(data interface{}) *Error {
+	return &Error{Code: def.Code, Message: def.Description, Data: data}
+}
+
+// ErrorRegistry is the central list of error codes an application may
+// return, in the -32000 to -32099 server range or outside the range
+// reserved by the specification.
+type ErrorRegistry struct {
+	sync.Mutex
+	defs map[ErrorCode]ErrorDef
+}
+
+// predefinedErrors are the codes of the specification and of this package,
+// which are always allowed.
+var predefinedErrors = []ErrorDef{
+	{E_PARSE, "ParseError", "Invalid JSON was received by the server."},
+	{E_INVALID_REQ, "InvalidRequest", "The JSON sent is not a valid Request object."},
+	{E_NO_METHOD, "MethodNotFound", "The method does not exist / is not available."},
+	{E_BAD_PARAMS, "InvalidParams", "Invalid method parameter(s)."},
+	{E_INTERNAL, "InternalError", "Internal JSON-RPC error."},
+	{E_SERVER, "ServerError", "Generic server error."},
+	{E_MAINTENANCE, "Maintenance", "The method is unavailable during maintenance."},
+	{E_QUOTA, "QuotaExceeded", "The call exceeds the quota of its tenant."},
+}
+
+func isPredefinedError(code ErrorCode) bool {
+	for _, def := range predefinedErrors {
+		if def.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Register declares an error code and returns its definition.
+func (reg *ErrorRegistry) Register(code ErrorCode, name, description string) (def ErrorDef, err error) {
+	if code >= -32768 && code <= -32100 || isPredefinedError(code) {
+		err = fmt.Errorf("rpc: error code %d is reserved", code)
+		return
+	}
+	reg.Lock()
+	defer reg.Unlock()
+	if reg.defs == nil {
+		reg.defs = make(map[ErrorCode]ErrorDef)
+	} else if existing, ok := reg.defs[code]; ok {
+		err = fmt.Errorf("rpc: error code %d already registered as %s", code, existing.Name)
+		return
+	}
+	def = ErrorDef{code, name, description}
+	reg.defs[code] = def
+	return
+}
+
+// MustRegister is like Register but panics on error. It simplifies
+// declaring errors as package level variables.
+func (reg *ErrorRegistry) MustRegister(code ErrorCode, name, description string) ErrorDef {
+	def, err := reg.Register(code, name, description)
+	if err != nil {
+		panic(err)
+	}
+	return def
+}
+
+// Lookup returns the definition of code, including predefined codes.
+func (reg *ErrorRegistry) Lookup(code ErrorCode) (def ErrorDef, ok bool) {
+	for _, def := range predefinedErrors {
+		if def.Code == code {
+			return def, true
+		}
+	}
+	reg.Lock()
+	def, ok = reg.defs[code]
+	reg.Unlock()
+	return
+}
+
+// Errors returns the registered definitions ordered by code, excluding
+// the predefined ones.
+func (reg *ErrorRegistry) Errors() []ErrorDef {
+	reg.Lock()
+	defs := make([]ErrorDef, 0, len(reg.defs))
+	for _, def := range reg.defs {
+		defs = append(defs, def)
+	}
+	reg.Unlock()
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Code > defs[j].Code })
+	return defs
+}
+
+// check replaces errors carrying an unregistered code with an internal
+// error, so clients only ever see declared codes.
+func (reg *ErrorRegistry) check(err error) error {
+	jsonErr, ok := errorOf(err)
+	if !ok {
+		return err
+	}
+	if _, ok = reg.Lookup(jsonErr.Code); ok {
+		return jsonErr
+	}
+	return &Error{
+		Code:    E_INTERNAL,
+		Message: fmt.Sprintf("rpc: handler returned unregistered error code %d", jsonErr.Code),
+	}
+}
@@ -0,0 +1,69 @@
+package jsonrpc
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrorTranslator maps application errors to JSON-RPC errors at the server
+// boundary, so handlers can return plain domain errors and the mapping lives
+// in one place. Rules are tried in the order they were added; errors no rule
+// matches are left untouched.
+type ErrorTranslator struct {
+	rules []func(err error) *Error
+}
+
+// AddIs translates errors matching target according to errors.Is into an
+// error with the given code and message.
+func (t *ErrorTranslator) AddIs(target error, code ErrorCode, message string) {
+	t.rules = append(t.rules, func(err error) *Error {
+		if !errors.Is(err, target) {
+			return nil
+		}
+		return &Error{Code: code, Message: message}
+	})
+}
+
+// AddAs translates errors matching the type of target according to
+// errors.As. target must be a non-nil pointer to the error type, as for
+// errors.As; translate receives the matched error and builds the result.
+//
+//	t.AddAs(new(*NotFoundError), func(err error) *jsonrpc.Error {
+//		return &jsonrpc.Error{Code: 404, Message: err.Error(), Data: err.(*NotFoundError).ID}
+//	})
+func (t *ErrorTranslator) AddAs(target interface{}, translate func(err error) *Error) {
+	targetType := reflect.TypeOf(target)
+	if targetType == nil || targetType.Kind() != reflect.Ptr {
+		panic("rpc: AddAs target must be a non-nil pointer")
+	}
+	t.rules = append(t.rules, func(err error) *Error {
+		matched := reflect.New(targetType.Elem())
+		if !errors.As(err, matched.Interface()) {
+			return nil
+		}
+		if e, ok := matched.Elem().Interface().(error); ok {
+			return translate(e)
+		}
+		return translate(err)
+	})
+}
+
+// AddFunc adds a rule given as a function that returns nil when it does
+// not apply to err.
+func (t *ErrorTranslator) AddFunc(translate func(err error) *Error) {
+	t.rules = append(t.rules, translate)
+}
+
+// Translate returns the JSON-RPC error for err. Errors that are or wrap an
+// *Error translate to that *Error.
+func (t *ErrorTranslator) Translate(err error) error {
+	if jsonErr, ok := errorOf(err); ok {
+		return jsonErr
+	}
+	for _, rule := range t.rules {
+		if jsonErr := rule(err); jsonErr != nil {
+			return jsonErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultETagCacheSize is the number of results a Client keeps for
+// conditional calls.
+const DefaultETagCacheSize = 256
+
+// WithETag tags the results of the method with an ETag computed from
+// their encoding, and answers calls whose If-None-Match header lists the
+// tag of the current result with an empty 304 Not Modified response.
+// Clients make such calls for methods with CallOptions.Conditional set.
+func WithETag() MethodOption {
+	return func(m *methodSpec) { m.etag = true }
+}
+
+// conditional sets the ETag of result and reports whether the request
+// already holds it. It returns the encoded result to send otherwise.
+func conditional(w http.ResponseWriter, r *http.Request, reply interface{}) (result interface{}, notModified bool) {
+	raw, err := json.Marshal(reply)
+	if err != nil {
+		return reply, false
+	}
+	etag := `"` + resultHash(raw) + `"`
+	w.Header().Set("ETag", etag)
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/"); tag == etag || tag == "*" {
+			return nil, true
+		}
+	}
+	return json.RawMessage(raw), false
+}
+
+// etagCache holds the last tagged results of conditional calls.
+type etagCache struct {
+	sync.Mutex
+	entries map[string]*etagEntry
+	order   []string
+}
+
+type etagEntry struct {
+	etag   string
+	result json.RawMessage
+	stored time.Time
+}
+
+func (c *etagCache) get(key string) *etagEntry {
+	c.Lock()
+	defer c.Unlock()
+	return c.entries[key]
+}
+
+func (c *etagCache) put(key string, entry *etagEntry) {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = entry
+	if len(c.order) > DefaultETagCacheSize {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+func (client *Client) etagCache() *etagCache {
+	client.Lock()
+	defer client.Unlock()
+	if client.etags == nil {
+		client.etags = &etagCache{entries: make(map[string]*etagEntry)}
+	}
+	return client.etags
+}
+
+// callConditional is Call for methods with CallOptions.Conditional or
+// CacheTTL set. It decodes the result cached for the same url, method and
+// params while it is younger than ttl, and otherwise sends its ETag and
+// decodes it when the server reports it unchanged.
+func (client *Client) callConditional(ctx context.Context, url, method string, params, reply interface{}, ttl time.Duration) (err error) {
+	var key []byte
+	if key, err = json.Marshal([]interface{}{url, method, params}); err != nil {
+		return
+	}
+	cache := client.etagCache()
+	cached := cache.get(string(key))
+	clock := clockOr(client.Clock)
+	if cached != nil && ttl > 0 && since(clock, cached.stored) < ttl {
+		return json.Unmarshal(cached.result, reply)
+	}
+
+	var header http.Header
+	if cached != nil && cached.etag != "" {
+		header = http.Header{"If-None-Match": {cached.etag}}
+	}
+
+	var result json.RawMessage
+	err = client.do(ctx, url, method, params, header, func(resp *http.Response) (err error) {
+		if resp.StatusCode == http.StatusNotModified && cached != nil {
+			result = cached.result
+			cache.put(string(key), &etagEntry{cached.etag, result, clock.Now()})
+			return
+		}
+		if err = decodeReply(resp.Body, &result, client.ParseMode); err != nil {
+			return
+		}
+		if etag := resp.Header.Get("ETag"); etag != "" || ttl > 0 {
+			cache.put(string(key), &etagEntry{etag, result, clock.Now()})
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	return json.Unmarshal(result, reply)
+}
@@ -0,0 +1,210 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrSubscriptionClosed is returned by EventStream.Err after Close, or once
+// the server ended the subscription.
+var ErrSubscriptionClosed = errors.New("rpc: subscription closed")
+
+// maxEarlyEvents bounds the events a Peer buffers for subscriptions whose
+// id it does not know yet.
+const maxEarlyEvents = 64
+
+// eventStreamSize is the number of events buffered per EventStream.
+const eventStreamSize = 64
+
+// eventQueue holds the events of one subscription made with Subscribe.
+type eventQueue struct {
+	events  chan json.RawMessage
+	done    chan struct{} // closed by EventStream.Close
+	ended   chan struct{} // closed when the Peer's connection ends
+	dropped uint64        // events that did not fit in events
+}
+
+// EventStream iterates over the events of a subscription made with
+// Subscribe, decoding their data into T:
+//
+//	stream, err := jsonrpc.Subscribe[Progress](ctx, peer, "downloads")
+//	...
+//	defer stream.Close()
+//	for stream.Next(ctx) {
+//		progress := stream.Event()
+//		...
+//	}
+//	if err := stream.Err(); err != nil { ... }
+//
+// Events are delivered in order by the goroutine reading the connection,
+// which never waits for a stream: events arriving while the stream's
+// buffer is full are dropped, and counted by Dropped.
+type EventStream[T any] struct {
+	peer  *Peer
+	id    string
+	queue *eventQueue
+	event T
+	err   error
+}
+
+// Subscribe subscribes to topic on the remote end, which must have
+// subscriptions enabled, and returns the stream of its events.
+func Subscribe[T any](ctx context.Context, p *Peer, topic string) (stream *EventStream[T], err error) {
+	p.Lock()
+	p.subscribing++
+	p.Unlock()
+
+	var reply SubscriptionArgs
+	err = p.Call(ctx, "rpc.subscribe", &SubscribeArgs{Topic: topic}, &reply)
+
+	p.Lock()
+	defer p.Unlock()
+	p.subscribing--
+	early := p.early[reply.Subscription]
+	delete(p.early, reply.Subscription)
+	if p.subscribing == 0 {
+		p.early = nil
+	}
+	if err != nil {
+		return
+	}
+	if p.closed {
+		return nil, ErrSessionClosed
+	}
+
+	queue := &eventQueue{
+		events: make(chan json.RawMessage, eventStreamSize),
+		done:   make(chan struct{}),
+		ended:  make(chan struct{}),
+	}
+	for _, data := range early {
+		queue.events <- data
+	}
+	if p.streams == nil {
+		p.streams = make(map[string]*eventQueue)
+	}
+	p.streams[reply.Subscription] = queue
+	return &EventStream[T]{peer: p, id: reply.Subscription, queue: queue}, nil
+}
+
+// routeEvent passes an EventMethod notification to the stream of its
+// subscription, or ends the stream on a SubscriptionEndedMethod
+// notification, reporting whether it did so. Notifications of
+// subscriptions not made with Subscribe are left to the Peer's Server.
+func (p *Peer) routeEvent(raw json.RawMessage) bool {
+	var probe struct {
+		Method string `json:"method"`
+		Params struct {
+			Subscription string          `json:"subscription"`
+			Data         json.RawMessage `json:"data"`
+		} `json:"params"`
+	}
+	if json.Unmarshal(raw, &probe) != nil {
+		return false
+	}
+	id := probe.Params.Subscription
+	switch probe.Method {
+	case EventMethod:
+	case SubscriptionEndedMethod:
+		p.Lock()
+		queue, ok := p.streams[id]
+		delete(p.streams, id)
+		p.Unlock()
+		if ok {
+			close(queue.done)
+		}
+		return ok
+	default:
+		return false
+	}
+
+	p.Lock()
+	queue, ok := p.streams[id]
+	if !ok {
+		// The event may have overtaken the response to rpc.subscribe.
+		if p.subscribing > 0 && len(p.early[id]) < maxEarlyEvents {
+			if p.early == nil {
+				p.early = make(map[string][]json.RawMessage)
+			}
+			p.early[id] = append(p.early[id], probe.Params.Data)
+			ok = true
+		}
+		p.Unlock()
+		return ok
+	}
+	p.Unlock()
+
+	select {
+	case queue.events <- probe.Params.Data:
+	default:
+		atomic.AddUint64(&queue.dropped, 1)
+	}
+	return true
+}
+
+// Next waits for the next event, reporting whether there was one. It
+// returns false once the stream is closed, by either end, the connection
+// ends or ctx is done; Next may be called again after a context error.
+func (s *EventStream[T]) Next(ctx context.Context) bool {
+	var data json.RawMessage
+	select {
+	case data = <-s.queue.events:
+	default:
+		select {
+		case data = <-s.queue.events:
+		case <-s.queue.done:
+			s.err = ErrSubscriptionClosed
+			return false
+		case <-s.queue.ended:
+			s.err = ErrSessionClosed
+			return false
+		case <-ctx.Done():
+			s.err = ctx.Err()
+			return false
+		}
+	}
+
+	var event T
+	if s.err = json.Unmarshal(data, &event); s.err != nil {
+		return false
+	}
+	s.event, s.err = event, nil
+	return true
+}
+
+// Event returns the event received by the last call to Next.
+func (s *EventStream[T]) Event() T {
+	return s.event
+}
+
+// Dropped returns the number of events dropped because the stream was not
+// read fast enough.
+func (s *EventStream[T]) Dropped() uint64 {
+	return atomic.LoadUint64(&s.queue.dropped)
+}
+
+// Err returns the error that made Next return false.
+func (s *EventStream[T]) Err() error {
+	return s.err
+}
+
+// Close ends the subscription.
+func (s *EventStream[T]) Close() (err error) {
+	p := s.peer
+	p.Lock()
+	_, ok := p.streams[s.id]
+	delete(p.streams, s.id)
+	closed := p.closed
+	p.Unlock()
+	if !ok {
+		return
+	}
+	close(s.queue.done)
+	if closed {
+		return
+	}
+	var removed bool
+	return p.Call(context.Background(), "rpc.unsubscribe", &SubscriptionArgs{Subscription: s.id}, &removed)
+}
@@ -0,0 +1,154 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// DefaultExamplesPerMethod is the number of examples ExampleRecorder keeps
+// per method when PerMethod is zero.
+const DefaultExamplesPerMethod = 3
+
+// ExampleRecorder is a development middleware that captures the params and
+// results of real successful calls, anonymized, so they can be exported as
+// documented examples and show up in the OpenRPC output.
+//
+//	rec := &jsonrpc.ExampleRecorder{RedactFields: []string{"password", "email"}}
+//	s.Use(rec.Middleware)
+//	...
+//	rec.Export(s)
+type ExampleRecorder struct {
+	sync.Mutex
+
+	// PerMethod is the number of distinct examples kept per method.
+	PerMethod int
+
+	// RedactFields lists object member names, matched case-insensitively at
+	// any depth, whose values are replaced before recording.
+	RedactFields []string
+
+	// Anonymize, if set, rewrites each decoded params and result value after
+	// redaction.
+	Anonymize func(method string, v interface{}) interface{}
+
+	examples map[string][]Example
+	seen     map[string][][]byte
+}
+
+// Middleware records the calls it serves.
+func (e *ExampleRecorder) Middleware(next CallHandler) CallHandler {
+	return func(r *http.Request, method string, args, reply interface{}) error {
+		err := next(r, method, args, reply)
+		if err == nil {
+			e.record(method, args, reply)
+		}
+		return err
+	}
+}
+
+func (e *ExampleRecorder) record(method string, args, reply interface{}) {
+	perMethod := e.PerMethod
+	if perMethod <= 0 {
+		perMethod = DefaultExamplesPerMethod
+	}
+	e.Lock()
+	full := len(e.examples[method]) >= perMethod
+	e.Unlock()
+	if full {
+		return
+	}
+
+	params, err := e.anonymize(method, args)
+	if err != nil {
+		return
+	}
+	result, err := e.anonymize(method, reply)
+	if err != nil {
+		return
+	}
+	key, err := json.Marshal([]interface{}{params, result})
+	if err != nil {
+		return
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	if e.examples == nil {
+		e.examples = make(map[string][]Example)
+		e.seen = make(map[string][][]byte)
+	}
+	if len(e.examples[method]) >= perMethod {
+		return
+	}
+	for _, k := range e.seen[method] {
+		if bytes.Equal(k, key) {
+			return
+		}
+	}
+	e.seen[method] = append(e.seen[method], key)
+	e.examples[method] = append(e.examples[method], Example{
+		Name:   fmt.Sprintf("recorded %d", len(e.examples[method])+1),
+		Params: params,
+		Result: result,
+	})
+}
+
+// anonymize returns a redacted copy of v as a decoded JSON value.
+func (e *ExampleRecorder) anonymize(method string, v interface{}) (interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, err
+	}
+	decoded = redactValue(decoded, e.RedactFields)
+	if e.Anonymize != nil {
+		decoded = e.Anonymize(method, decoded)
+	}
+	return decoded, nil
+}
+
+// Examples returns the examples recorded for method.
+func (e *ExampleRecorder) Examples(method string) []Example {
+	e.Lock()
+	defer e.Unlock()
+	return append([]Example(nil), e.examples[method]...)
+}
+
+// Reset discards all recorded examples.
+func (e *ExampleRecorder) Reset() {
+	e.Lock()
+	e.examples = nil
+	e.seen = nil
+	e.Unlock()
+}
+
+// Export adds the recorded examples to the documentation of the methods
+// registered on s, after any examples declared with WithExample. Examples
+// exported before are not added again.
+func (e *ExampleRecorder) Export(s *Server) {
+	e.Lock()
+	defer e.Unlock()
+	s.Lock()
+	defer s.Unlock()
+	for method, examples := range e.examples {
+		spec, ok := s.methods[method]
+		if !ok {
+			continue
+		}
+		exported := make(map[string]bool)
+		for _, example := range spec.doc.Examples {
+			exported[example.Name] = true
+		}
+		for _, example := range examples {
+			if !exported[example.Name] {
+				spec.doc.Examples = append(spec.doc.Examples, example)
+			}
+		}
+	}
+}
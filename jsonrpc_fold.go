@@ -0,0 +1,57 @@
+package jsonrpc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// foldName adds name, a method or alias, to the case-insensitive index.
+// With CaseInsensitiveMethods set it fails if another name differs from it
+// only in case. s must be locked.
+func (s *Server) foldName(name string) error {
+	key := strings.ToLower(name)
+	if existing, ok := s.folded[key]; ok && existing != name && s.CaseInsensitiveMethods {
+		return fmt.Errorf("rpc: method %s conflicts with %s when resolved case-insensitively", name, existing)
+	}
+	if s.folded == nil {
+		s.folded = make(map[string]string)
+	}
+	if _, ok := s.folded[key]; !ok {
+		s.folded[key] = name
+	}
+	return nil
+}
+
+// unfoldName removes name from the case-insensitive index once it is
+// neither a method nor an alias, pointing the entry at another name that
+// differs only in case if one remains. s must be locked.
+func (s *Server) unfoldName(name string) {
+	key := strings.ToLower(name)
+	if s.folded[key] != name {
+		return
+	}
+	if _, ok := s.methods[name]; ok {
+		return
+	}
+	if _, ok := s.aliases[name]; ok {
+		return
+	}
+	delete(s.folded, key)
+	for other := range s.methods {
+		s.repointFold(key, other)
+	}
+	for other := range s.aliases {
+		s.repointFold(key, other)
+	}
+}
+
+// repointFold points the index entry key at other if other folds to key,
+// preferring the smallest name so the choice is deterministic.
+func (s *Server) repointFold(key, other string) {
+	if strings.ToLower(other) != key {
+		return
+	}
+	if existing, ok := s.folded[key]; !ok || other < existing {
+		s.folded[key] = other
+	}
+}
@@ -0,0 +1,165 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GatherPolicy decides when Gather is done.
+type GatherPolicy int
+
+const (
+	// GatherAll waits for every branch and fails as soon as any fails.
+	GatherAll GatherPolicy = iota
+
+	// GatherFirst is done with the first successful branch.
+	GatherFirst
+
+	// GatherQuorum is done once GatherOptions.Quorum branches succeeded.
+	GatherQuorum
+)
+
+// GatherOptions configure Gather.
+type GatherOptions struct {
+	Policy GatherPolicy
+
+	// Quorum is the number of successful branches GatherQuorum waits for.
+	// Defaults to a majority of the branches; Gather fails without running
+	// any branch if it exceeds their number.
+	Quorum int
+
+	// BranchTimeout bounds branches without a Timeout of their own.
+	BranchTimeout time.Duration
+}
+
+// Branch is one call of a Gather.
+type Branch[T any] struct {
+	// Name identifies the branch in results and errors, e.g. the endpoint.
+	Name string
+
+	// Timeout, if positive, bounds the branch.
+	Timeout time.Duration
+
+	Call func(ctx context.Context) (T, error)
+}
+
+// CallBranch returns a Branch calling method at url, named after url.
+func CallBranch[T any](client *Client, url, method string, params interface{}) Branch[T] {
+	return Branch[T]{
+		Name: url,
+		Call: func(ctx context.Context) (reply T, err error) {
+			err = client.Call(ctx, url, method, params, &reply)
+			return
+		},
+	}
+}
+
+// BranchResult is the outcome of a finished branch.
+type BranchResult[T any] struct {
+	Name  string
+	Value T
+	Err   error
+}
+
+// GatherError is returned by Gather when its policy could not be met.
+type GatherError struct {
+	// Failures lists the failed branches in the order they failed.
+	Failures []BranchFailure
+
+	// Branches is the number of branches gathered.
+	Branches int
+}
+
+// BranchFailure is the error of a failed branch.
+type BranchFailure struct {
+	Name string
+	Err  error
+}
+
+func (e *GatherError) Error() string {
+	if len(e.Failures) == 1 {
+		return fmt.Sprintf("rpc: branch %s failed: %v", e.Failures[0].Name, e.Failures[0].Err)
+	}
+	// Group branches failing with the same message, as they often share a
+	// cause, e.g. an invalid query.
+	var messages []string
+	names := make(map[string][]string)
+	for _, failure := range e.Failures {
+		message := failure.Err.Error()
+		if _, ok := names[message]; !ok {
+			messages = append(messages, message)
+		}
+		names[message] = append(names[message], failure.Name)
+	}
+	parts := make([]string, len(messages))
+	for i, message := range messages {
+		parts[i] = strings.Join(names[message], ", ") + ": " + message
+	}
+	return fmt.Sprintf("rpc: %d of %d branches failed: %s", len(e.Failures), e.Branches, strings.Join(parts, "; "))
+}
+
+// Gather runs branches concurrently until opts.Policy is met and returns
+// the results of the branches finished by then, in the order they
+// finished. Branches still running are canceled and not waited for. If
+// the policy cannot be met, err is a *GatherError.
+func Gather[T any](ctx context.Context, opts GatherOptions, branches ...Branch[T]) (results []BranchResult[T], err error) {
+	if opts.Policy == GatherQuorum && opts.Quorum > len(branches) {
+		return nil, fmt.Errorf("rpc: quorum of %d cannot be met by %d branches", opts.Quorum, len(branches))
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	needed := len(branches)
+	switch opts.Policy {
+	case GatherFirst:
+		needed = 1
+	case GatherQuorum:
+		needed = opts.Quorum
+		if needed <= 0 {
+			needed = len(branches)/2 + 1
+		}
+	}
+
+	done := make(chan BranchResult[T], len(branches))
+	for _, branch := range branches {
+		go func(branch Branch[T]) {
+			ctx, cancel := ctx, context.CancelFunc(func() {})
+			if timeout := branch.Timeout; timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+			} else if timeout = opts.BranchTimeout; timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+			}
+			defer cancel()
+			value, err := branch.Call(ctx)
+			done <- BranchResult[T]{branch.Name, value, err}
+		}(branch)
+	}
+
+	gatherErr := &GatherError{Branches: len(branches)}
+	succeeded := 0
+	if needed <= 0 {
+		return
+	}
+	for range branches {
+		var result BranchResult[T]
+		select {
+		case result = <-done:
+		case <-ctx.Done():
+			return results, ctx.Err()
+		}
+		results = append(results, result)
+		if result.Err != nil {
+			gatherErr.Failures = append(gatherErr.Failures, BranchFailure{result.Name, result.Err})
+			if len(branches)-len(gatherErr.Failures) < needed {
+				return results, gatherErr
+			}
+			continue
+		}
+		if succeeded++; succeeded >= needed {
+			return results, nil
+		}
+	}
+	return results, gatherErr
+}
@@ -0,0 +1,46 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGatherQuorum(t *testing.T) {
+	tests := []struct {
+		name        string
+		quorum      int
+		failures    int // of 3 branches
+		wantErr     bool
+		wantStarted bool
+	}{
+		{"majority", 0, 1, false, true},
+		{"explicit", 3, 0, false, true},
+		{"not met", 2, 2, true, true},
+		{"exceeds branches", 4, 0, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var started int32
+			branches := make([]Branch[int], 3)
+			for i := range branches {
+				fail := i < tt.failures
+				branches[i] = Branch[int]{Call: func(ctx context.Context) (int, error) {
+					atomic.AddInt32(&started, 1)
+					if fail {
+						return 0, errors.New("failed")
+					}
+					return 1, nil
+				}}
+			}
+			_, err := Gather(context.Background(), GatherOptions{Policy: GatherQuorum, Quorum: tt.quorum}, branches...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantStarted && atomic.LoadInt32(&started) != 0 {
+				t.Errorf("%d branches started, want none", started)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package jsonrpc
+
+import "context"
+
+// RegisterFunc is like Server.Register for a handler whose signature is
+// checked at compile time. Unlike Register it accepts unexported argument
+// and reply types, and calls are dispatched without reflection.
+func RegisterFunc[A, R any](s *Server, method string, fn func(context.Context, *A, *R) error, opts ...MethodOption) error {
+	return s.RegisterAdapter(method, FuncAdapter[A, R](fn), opts...)
+}
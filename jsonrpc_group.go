@@ -0,0 +1,118 @@
+package jsonrpc
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Group registers methods under a common name prefix, such as "download.",
+// with middleware, options and error translation shared by all of them.
+// Middleware added to a group applies to its methods, including those
+// registered before, and to the methods of its subgroups.
+type Group struct {
+	sync.Mutex
+	server     *Server
+	parent     *Group
+	prefix     string
+	opts       []MethodOption
+	middleware []Middleware
+	translator *ErrorTranslator
+}
+
+// Group returns a group of methods whose names start with prefix. The
+// options apply to every method registered through the group.
+func (s *Server) Group(prefix string, opts ...MethodOption) *Group {
+	return &Group{server: s, prefix: prefix, opts: opts}
+}
+
+// Group returns a subgroup whose prefix extends the group's.
+func (g *Group) Group(prefix string, opts ...MethodOption) *Group {
+	return &Group{server: g.server, parent: g, prefix: g.prefix + prefix, opts: append(g.allOptions(), opts...)}
+}
+
+// Prefix returns the name prefix of the group.
+func (g *Group) Prefix() string {
+	return g.prefix
+}
+
+// Use appends middleware run for calls of the group's methods, after the
+// server and pattern middleware and the middleware of enclosing groups.
+func (g *Group) Use(middleware ...Middleware) {
+	g.Lock()
+	g.middleware = append(g.middleware, middleware...)
+	g.Unlock()
+}
+
+// TranslateErrors maps the errors returned by the group's methods with t,
+// before the Server's ErrorTranslator sees them.
+func (g *Group) TranslateErrors(t *ErrorTranslator) {
+	g.Lock()
+	g.translator = t
+	g.Unlock()
+}
+
+// Register registers handler as the prefix of the group followed by
+// method, with the group's options before opts.
+func (g *Group) Register(method string, handler interface{}, opts ...MethodOption) error {
+	return g.server.Register(g.prefix+method, handler, g.options(opts)...)
+}
+
+// RegisterService registers the methods of receiver as
+// "prefix" + name + "." + Method.
+func (g *Group) RegisterService(receiver interface{}, name string, opts ...MethodOption) error {
+	return g.server.RegisterService(receiver, g.prefix+name, g.options(opts)...)
+}
+
+// Alias makes calls of prefix+alias go to prefix+target.
+func (g *Group) Alias(alias, target string) error {
+	return g.server.Alias(g.prefix+alias, g.prefix+target)
+}
+
+// Unregister removes prefix+method.
+func (g *Group) Unregister(method string) bool {
+	return g.server.Unregister(g.prefix + method)
+}
+
+func (g *Group) allOptions() []MethodOption {
+	return append([]MethodOption(nil), g.opts...)
+}
+
+// options returns the options of a method registered through the group.
+func (g *Group) options(opts []MethodOption) []MethodOption {
+	all := append(g.allOptions(), WithMiddleware(g.wrap))
+	return append(all, opts...)
+}
+
+// wrap runs the middleware and error translation of the group and its
+// enclosing groups, outermost group first, as they are at call time.
+func (g *Group) wrap(next CallHandler) CallHandler {
+	return func(r *http.Request, method string, args, reply interface{}) error {
+		var groups []*Group
+		for group := g; group != nil; group = group.parent {
+			groups = append(groups, group)
+		}
+		handler := next
+		for _, group := range groups {
+			group.Lock()
+			middleware, translator := group.middleware, group.translator
+			group.Unlock()
+			if translator != nil {
+				handler = translating(translator, handler)
+			}
+			for i := len(middleware) - 1; i >= 0; i-- {
+				handler = middleware[i](handler)
+			}
+		}
+		return handler(r, method, args, reply)
+	}
+}
+
+// translating maps the errors of next with t.
+func translating(t *ErrorTranslator, next CallHandler) CallHandler {
+	return func(r *http.Request, method string, args, reply interface{}) error {
+		if err := next(r, method, args, reply); err != nil {
+			return t.Translate(err)
+		}
+		return nil
+	}
+}
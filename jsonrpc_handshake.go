@@ -0,0 +1,113 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+)
+
+// HandshakeMethod is the method a client calls right after connecting to
+// agree on the capabilities of the connection.
+const HandshakeMethod = "rpc.handshake"
+
+// Capabilities are the features one end of a connection supports, offered
+// in a handshake, or those both ends support, as agreed on.
+type Capabilities struct {
+	// Codecs and Compressions list the encodings in order of preference.
+	// The agreed lists keep the order of the client's offer, so the first
+	// entry is the best common choice.
+	Codecs       []string `json:"codecs,omitempty"`
+	Compressions []string `json:"compressions,omitempty"`
+
+	// MaxMessageSize is the size of the largest message accepted, in
+	// bytes. Zero means unlimited; the agreed size is the smaller one.
+	MaxMessageSize int64 `json:"maxMessageSize,omitempty"`
+
+	// Extensions names the protocol extensions supported, such as
+	// "subscriptions" or "ordered".
+	Extensions []string `json:"extensions,omitempty"`
+}
+
+// intersect returns the capabilities offered by both c, the client, and
+// other, the server.
+func (c *Capabilities) intersect(other *Capabilities) *Capabilities {
+	agreed := &Capabilities{
+		Codecs:         common(c.Codecs, other.Codecs),
+		Compressions:   common(c.Compressions, other.Compressions),
+		MaxMessageSize: c.MaxMessageSize,
+		Extensions:     common(c.Extensions, other.Extensions),
+	}
+	if other.MaxMessageSize > 0 && (agreed.MaxMessageSize <= 0 || other.MaxMessageSize < agreed.MaxMessageSize) {
+		agreed.MaxMessageSize = other.MaxMessageSize
+	}
+	return agreed
+}
+
+// common returns the elements of a also in b, in the order of a.
+func common(a, b []string) (both []string) {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				both = append(both, x)
+				break
+			}
+		}
+	}
+	return
+}
+
+// EnableHandshake registers rpc.handshake, which answers the capabilities
+// offered by a client with those also in c and records them in the
+// session. Applications read them with Session.Capabilities to configure
+// the connection. The handshake is optional: clients that skip it get a
+// session without agreed capabilities.
+func (s *Server) EnableHandshake(c Capabilities) error {
+	return s.Register(HandshakeMethod, func(ctx context.Context, offer *Capabilities) (*Capabilities, error) {
+		session, ok := SessionFromContext(ctx)
+		if !ok {
+			return nil, &Error{Code: E_INVALID_REQ, Message: "rpc: handshake requires a persistent connection"}
+		}
+		agreed := offer.intersect(&c)
+		session.setCapabilities(agreed)
+		return agreed, nil
+	}, WithSummary("Agrees on the capabilities of the connection."))
+}
+
+// Capabilities returns the capabilities agreed on in the session's
+// handshake, or nil if there was none.
+func (session *Session) Capabilities() *Capabilities {
+	session.Lock()
+	defer session.Unlock()
+	return session.capabilities
+}
+
+func (session *Session) setCapabilities(c *Capabilities) {
+	session.Lock()
+	session.capabilities = c
+	session.Unlock()
+}
+
+// Handshake offers c to the remote end and returns the capabilities both
+// ends agreed on, which are also recorded in the peer's Session. A remote
+// end without handshake support answers with an *Error, after which the
+// connection may still be used without agreed capabilities.
+func (p *Peer) Handshake(ctx context.Context, c Capabilities) (agreed *Capabilities, err error) {
+	agreed = new(Capabilities)
+	if err = p.Call(ctx, HandshakeMethod, &c, agreed); err != nil {
+		return nil, err
+	}
+	p.session.setCapabilities(agreed)
+	return
+}
+
+// handshake runs the handshake of a new connection, then makes it
+// available to calls. Connections failing for another reason than a
+// remote end without handshake support are closed and redialed.
+func (c *PersistentClient) handshake(peer *Peer) {
+	_, err := peer.Handshake(c.ctx, *c.Handshake)
+	var rpcErr *Error
+	if err != nil && !errors.As(err, &rpcErr) {
+		peer.Close()
+		return
+	}
+	c.connect(peer)
+}
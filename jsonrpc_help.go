@@ -0,0 +1,123 @@
+package jsonrpc
+
+import (
+	"context"
+	"reflect"
+	"strings"
+)
+
+// MethodNameArgs are the params of system.methodHelp and
+// system.methodSignature. They may be passed by position, as XML-RPC
+// clients do: ["method"].
+type MethodNameArgs struct {
+	Method string `json:"method"`
+}
+
+// EnableHelpMethods registers the XML-RPC style introspection methods
+// system.listMethods, system.methodHelp and system.methodSignature,
+// answered from the documentation and types of the registered methods.
+// Hidden methods are reported as unknown.
+func (s *Server) EnableHelpMethods() (err error) {
+	if err = s.Register("system.listMethods", s.listMethods,
+		WithSummary("Lists the names of the methods.")); err != nil {
+		return
+	}
+	if err = s.Register("system.methodHelp", s.methodHelp,
+		WithSummary("Describes how to call a method.")); err != nil {
+		return
+	}
+	return s.Register("system.methodSignature", s.methodSignature,
+		WithSummary("Lists the types of the result and params of a method."))
+}
+
+func (s *Server) listMethods(ctx context.Context) (names []string, err error) {
+	names = []string{}
+	for _, m := range s.Methods() {
+		if spec, err := s.get(m.Name); err == nil && spec.isVisible(ctx, m.Name) {
+			names = append(names, m.Name)
+		}
+	}
+	return
+}
+
+// helpSpec returns the spec of a method visible in ctx. Visibility is
+// decided by the name the method resolves to, as for calls.
+func (s *Server) helpSpec(ctx context.Context, method string) (spec *methodSpec, err error) {
+	resolved := s.resolve(method)
+	if spec, err = s.get(resolved); err == nil && !spec.isVisible(ctx, resolved) {
+		err = errMethodNotFound(method)
+	}
+	if err != nil {
+		err = &Error{Code: E_BAD_PARAMS, Message: err.Error()}
+	}
+	return
+}
+
+// methodHelp returns the summary and description of a method followed by
+// a line per documented param.
+func (s *Server) methodHelp(ctx context.Context, args *MethodNameArgs) (help string, err error) {
+	var spec *methodSpec
+	if spec, err = s.helpSpec(ctx, args.Method); err != nil {
+		return
+	}
+	var lines []string
+	if spec.doc.Summary != "" {
+		lines = append(lines, spec.doc.Summary)
+	}
+	if spec.doc.Description != "" {
+		lines = append(lines, spec.doc.Description)
+	}
+	if spec.doc.Deprecated {
+		lines = append(lines, "Deprecated.")
+	}
+	for _, param := range spec.doc.Params {
+		line := param.Name
+		if param.Required {
+			line += " (required)"
+		}
+		if param.Description != "" {
+			line += ": " + param.Description
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// methodSignature returns the one signature of a method: the JSON type of
+// its result followed by those of its params in positional order.
+func (s *Server) methodSignature(ctx context.Context, args *MethodNameArgs) (signatures [][]string, err error) {
+	var spec *methodSpec
+	if spec, err = s.helpSpec(ctx, args.Method); err != nil {
+		return
+	}
+	signature := []string{signatureType(spec.replyType)}
+	switch {
+	case spec.noArgs:
+	case isStruct(spec.argsType):
+		t := spec.argsType
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		for _, f := range jsonFields(t) {
+			signature = append(signature, signatureType(f.typ))
+		}
+	default:
+		signature = append(signature, signatureType(spec.argsType))
+	}
+	return [][]string{signature}, nil
+}
+
+// signatureType names the JSON type of t, or "any".
+func signatureType(t reflect.Type) string {
+	if schema := SchemaOf(t); schema.Type != "" {
+		return schema.Type
+	}
+	return "any"
+}
+
+func isStruct(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != typeOfTime
+}
@@ -0,0 +1,57 @@
+package jsonrpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMethodHelpVisibility(t *testing.T) {
+	s := &Server{CaseInsensitiveMethods: true}
+	if err := s.Register("admin.reset", func(ctx context.Context) error { return nil },
+		WithSummary("Resets everything.")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register("item.get", func(ctx context.Context, args *CacheArgs) (int, error) { return args.N, nil },
+		WithSummary("Gets an item.")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Alias("reset", "admin.reset"); err != nil {
+		t.Fatal(err)
+	}
+	session := &Session{server: s}
+	session.Disable("admin.*")
+	ctx := context.WithValue(context.Background(), sessionKey{}, session)
+
+	if names, _ := s.listMethods(ctx); len(names) != 1 || names[0] != "item.get" {
+		t.Errorf("listMethods = %v, want [item.get]", names)
+	}
+
+	tests := []struct {
+		method   string
+		wantHelp string
+		wantErr  bool
+	}{
+		{"item.get", "Gets an item.", false},
+		{"ITEM.GET", "Gets an item.", false},
+		{"admin.reset", "", true},
+		{"ADMIN.reset", "", true},
+		{"reset", "", true},
+		{"Reset", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			help, err := s.methodHelp(ctx, &MethodNameArgs{tt.method})
+			if (err != nil) != tt.wantErr || help != tt.wantHelp {
+				t.Errorf("methodHelp = %q, %v, want %q, error %v", help, err, tt.wantHelp, tt.wantErr)
+			}
+			signatures, err := s.methodSignature(ctx, &MethodNameArgs{tt.method})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("methodSignature error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (len(signatures) != 1 || strings.Join(signatures[0], ",") != "integer,integer") {
+				t.Errorf("methodSignature = %v, want [[integer integer]]", signatures)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package jsonrpc_test
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-webdl/jsonrpc"
+	"github.com/go-webdl/jsonrpc/jsonrpctest"
+)
+
+// message is the method of a message a peer received, if any.
+type message struct {
+	Method string `json:"method"`
+}
+
+// readMessages decodes the messages arriving on conn.
+func readMessages(conn net.Conn) <-chan message {
+	messages := make(chan message, 16)
+	go func() {
+		defer close(messages)
+		decoder := json.NewDecoder(conn)
+		for {
+			var m message
+			if decoder.Decode(&m) != nil {
+				return
+			}
+			messages <- m
+		}
+	}()
+	return messages
+}
+
+func receive(t *testing.T, messages <-chan message) message {
+	t.Helper()
+	select {
+	case m, ok := <-messages:
+		if !ok {
+			t.Fatal("connection closed")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message")
+	}
+	return message{}
+}
+
+func TestSessionIdleUnreadPeer(t *testing.T) {
+	clock := jsonrpctest.NewFakeClock(time.Unix(1000, 0))
+	s := &jsonrpc.Server{SessionIdleTimeout: time.Minute, Clock: clock}
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	done := make(chan struct{})
+	go func() {
+		s.ServeConn(conn)
+		close(done)
+	}()
+
+	// The peer never reads, so the goingAway notification blocks.
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle session was not closed")
+	}
+}
+
+func TestSubscriptionIdle(t *testing.T) {
+	tests := []struct {
+		name     string
+		activity bool // whether the subscriber sends a message halfway
+	}{
+		{"events only", false},
+		{"subscriber active", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := jsonrpctest.NewFakeClock(time.Unix(1000, 0))
+			s := &jsonrpc.Server{Clock: clock}
+			hub := &jsonrpc.SubscriptionHub{IdleTimeout: time.Minute, Clock: clock}
+			if err := s.EnableSubscriptions(hub); err != nil {
+				t.Fatal(err)
+			}
+			conn, peer := net.Pipe()
+			defer peer.Close()
+			go s.ServeConn(conn)
+			messages := readMessages(peer)
+			send := func(raw string) {
+				if _, err := peer.Write([]byte(raw + "\n")); err != nil {
+					t.Fatal(err)
+				}
+				receive(t, messages) // the response
+			}
+
+			send(`{"jsonrpc":"2.0","id":1,"method":"rpc.subscribe","params":{"topic":"t"}}`)
+			clock.BlockUntil(1)
+			if err := hub.Publish("t", 1); err != nil {
+				t.Fatal(err)
+			}
+			if m := receive(t, messages); m.Method != jsonrpc.EventMethod {
+				t.Fatalf("got %q, want an event", m.Method)
+			}
+			clock.Advance(30 * time.Second)
+			if tt.activity {
+				send(`{"jsonrpc":"2.0","id":2,"method":"rpc.unsubscribe","params":{"subscription":"other"}}`)
+			} else {
+				hub.Publish("t", 2)
+				receive(t, messages)
+			}
+			clock.Advance(30 * time.Second)
+
+			if tt.activity {
+				// The timer is rearmed for the rest of the timeout.
+				clock.BlockUntil(1)
+				select {
+				case m := <-messages:
+					t.Fatalf("got %q before the timeout", m.Method)
+				default:
+				}
+				clock.Advance(30 * time.Second)
+			}
+			if m := receive(t, messages); m.Method != jsonrpc.SubscriptionEndedMethod {
+				t.Errorf("got %q, want %q", m.Method, jsonrpc.SubscriptionEndedMethod)
+			}
+		})
+	}
+}
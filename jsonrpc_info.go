@@ -0,0 +1,113 @@
+package jsonrpc
+
+import (
+	"context"
+	"runtime/debug"
+	"sort"
+	"time"
+)
+
+// ServerInfo is the result of system.info.
+type ServerInfo struct {
+	// Version is the version of the service, as passed to EnableInfoMethod.
+	Version string `json:"version,omitempty"`
+
+	Build *BuildInfo `json:"build,omitempty"`
+
+	// Time is the server's clock when answering.
+	Time time.Time `json:"time"`
+
+	// Features lists the protocol features the server supports: "batch",
+	// "multipart", "subscriptions", "jobs" and "webhooks".
+	Features []string `json:"features"`
+
+	// Codecs lists the Content-Types requests may be encoded in.
+	Codecs []string `json:"codecs"`
+
+	// Compressions lists the accepted Content-Encodings.
+	Compressions []string `json:"compressions,omitempty"`
+
+	Limits ServerLimits `json:"limits"`
+}
+
+// BuildInfo describes the binary serving the requests.
+type BuildInfo struct {
+	GoVersion string `json:"goVersion"`
+	Path      string `json:"path,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// ServerLimits are the limits requests must stay within. Zero means
+// unlimited.
+type ServerLimits struct {
+	MaxRequestSize    int64 `json:"maxRequestSize,omitempty"`
+	MaxBatchSize      int   `json:"maxBatchSize"`
+	MaxAttachmentSize int64 `json:"maxAttachmentSize"`
+}
+
+// EnableInfoMethod registers system.info, which reports version as the
+// service version along with the build, features and limits of the
+// server, so clients can detect features instead of guessing from errors.
+func (s *Server) EnableInfoMethod(version string) error {
+	var build *BuildInfo
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		build = &BuildInfo{GoVersion: bi.GoVersion, Path: bi.Main.Path, Version: bi.Main.Version}
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				build.Revision = setting.Value
+			case "vcs.modified":
+				build.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return s.Register("system.info", func(ctx context.Context) (*ServerInfo, error) {
+		return s.info(version, build), nil
+	}, WithSummary("Reports the version, build, features and limits of the server."))
+}
+
+func (s *Server) info(version string, build *BuildInfo) *ServerInfo {
+	info := &ServerInfo{
+		Version:  version,
+		Build:    build,
+		Time:     s.clock().Now(),
+		Features: []string{"batch", "multipart"},
+		Codecs:   []string{"application/json"},
+		Limits: ServerLimits{
+			MaxRequestSize:    s.MaxRequestSize,
+			MaxBatchSize:      s.MaxBatchSize,
+			MaxAttachmentSize: s.MaxAttachmentSize,
+		},
+	}
+	if info.Limits.MaxBatchSize <= 0 {
+		info.Limits.MaxBatchSize = DefaultMaxBatchSize
+	}
+	if info.Limits.MaxAttachmentSize <= 0 {
+		info.Limits.MaxAttachmentSize = DefaultMaxAttachmentSize
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	for feature, method := range map[string]string{
+		"subscriptions": "rpc.subscribe",
+		"jobs":          "job.status",
+		"webhooks":      "webhook.register",
+	} {
+		if s.methods[method] != nil {
+			info.Features = append(info.Features, feature)
+		}
+	}
+	sort.Strings(info.Features[2:])
+	for contentType := range s.codecs {
+		if contentType != "application/json" {
+			info.Codecs = append(info.Codecs, contentType)
+		}
+	}
+	sort.Strings(info.Codecs[1:])
+	for _, c := range s.compressions {
+		info.Compressions = append(info.Compressions, c.coding)
+	}
+	return info
+}
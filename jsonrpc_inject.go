@@ -0,0 +1,97 @@
+package jsonrpc
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Provide adds dependencies for the constructors passed to
+// RegisterConstructor and RegisterServiceConstructor, e.g. database handles
+// and loggers, replacing any of the same type. A constructor parameter of
+// interface type takes the one provided value implementing it. Provide
+// panics if a dependency is nil, since its type cannot be told.
+func (s *Server) Provide(dependencies ...interface{}) {
+	for _, dependency := range dependencies {
+		if dependency == nil {
+			panic("rpc: Provide dependency must not be nil")
+		}
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.dependencies == nil {
+		s.dependencies = make(map[reflect.Type]reflect.Value)
+	}
+	for _, dependency := range dependencies {
+		v := reflect.ValueOf(dependency)
+		s.dependencies[v.Type()] = v
+	}
+}
+
+// dependency returns the provided value for a parameter of type t.
+func (s *Server) dependency(t reflect.Type) (v reflect.Value, err error) {
+	s.Lock()
+	defer s.Unlock()
+	if v, ok := s.dependencies[t]; ok {
+		return v, nil
+	}
+	if t.Kind() == reflect.Interface {
+		for dt, dv := range s.dependencies {
+			if !dt.Implements(t) {
+				continue
+			}
+			if v.IsValid() {
+				return v, fmt.Errorf("rpc: several dependencies implement %s", t)
+			}
+			v = dv
+		}
+		if v.IsValid() {
+			return v, nil
+		}
+	}
+	return v, fmt.Errorf("rpc: no dependency of type %s provided", t)
+}
+
+// construct calls constructor, a function taking dependencies and
+// returning a value and optionally an error, and returns the value.
+func (s *Server) construct(constructor interface{}) (value interface{}, err error) {
+	fn := reflect.ValueOf(constructor)
+	t := fn.Type()
+	if t.Kind() != reflect.Func || t.NumOut() < 1 || t.NumOut() > 2 || t.NumOut() == 2 && t.Out(1) != typeOfError {
+		return nil, fmt.Errorf("rpc: constructor must have signature func(<dependencies>...) (<value>[, error]), got %s", t)
+	}
+	in := make([]reflect.Value, t.NumIn())
+	for i := range in {
+		if in[i], err = s.dependency(t.In(i)); err != nil {
+			return
+		}
+	}
+	out := fn.Call(in)
+	if len(out) == 2 && !out[1].IsNil() {
+		return nil, out[1].Interface().(error)
+	}
+	return out[0].Interface(), nil
+}
+
+// RegisterConstructor registers the handler returned by constructor for
+// method. constructor takes dependencies added with Provide and returns a
+// handler accepted by Register, and optionally an error, e.g.
+//
+//	func(db *sql.DB, log *log.Logger) func(ctx context.Context, args *Args) (*Reply, error)
+func (s *Server) RegisterConstructor(method string, constructor interface{}, opts ...MethodOption) error {
+	handler, err := s.construct(constructor)
+	if err != nil {
+		return err
+	}
+	return s.Register(method, handler, opts...)
+}
+
+// RegisterServiceConstructor registers the receiver returned by
+// constructor as RegisterService does. constructor takes dependencies
+// added with Provide and returns the receiver, and optionally an error.
+func (s *Server) RegisterServiceConstructor(constructor interface{}, name string, opts ...MethodOption) error {
+	receiver, err := s.construct(constructor)
+	if err != nil {
+		return err
+	}
+	return s.RegisterService(receiver, name, opts...)
+}
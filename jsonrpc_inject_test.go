@@ -0,0 +1,31 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProvide(t *testing.T) {
+	tests := []struct {
+		name         string
+		dependencies []interface{}
+		wantPanic    bool
+	}{
+		{"values", []interface{}{new(bytes.Buffer), "name"}, false},
+		{"nil", []interface{}{new(bytes.Buffer), nil}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := new(Server)
+			defer func() {
+				if p := recover(); (p != nil) != tt.wantPanic {
+					t.Errorf("panic = %v, want panic %v", p, tt.wantPanic)
+				}
+				if tt.wantPanic && len(s.dependencies) != 0 {
+					t.Errorf("dependencies = %v, want none", s.dependencies)
+				}
+			}()
+			s.Provide(tt.dependencies...)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package jsonrpc
+
+import (
+	"reflect"
+	"sort"
+)
+
+// MethodInfo describes a registered method.
+type MethodInfo struct {
+	Name string
+
+	// ArgsType and ReplyType are the types params and results are decoded
+	// into and encoded from. Handlers without args or reply have the empty
+	// struct and interface{} types respectively.
+	ArgsType  reflect.Type
+	ReplyType reflect.Type
+
+	Doc      MethodDoc
+	Async    bool // registered WithAsync
+	Durable  bool // registered WithDurableQueue
+	Mutating bool // registered WithMutating
+}
+
+// Methods returns all registered methods sorted by name, regardless of
+// visibility.
+func (s *Server) Methods() []MethodInfo {
+	s.Lock()
+	methods := make([]MethodInfo, 0, len(s.methods))
+	for name, spec := range s.methods {
+		methods = append(methods, spec.info(name))
+	}
+	s.Unlock()
+
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	return methods
+}
+
+func (spec *methodSpec) info(name string) MethodInfo {
+	return MethodInfo{
+		Name:      name,
+		ArgsType:  spec.argsType,
+		ReplyType: spec.replyType,
+		Doc:       spec.doc,
+		Async:     spec.async,
+		Durable:   spec.durable,
+		Mutating:  spec.mutating,
+	}
+}
@@ -0,0 +1,383 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrJobQueueFull = errors.New("rpc: job queue is full")
+
+// JobState is the lifecycle state of an asynchronous job.
+type JobState string
+
+const (
+	JobQueued   JobState = "queued"
+	JobRunning  JobState = "running"
+	JobDone     JobState = "done"
+	JobFailed   JobState = "failed"
+	JobCanceled JobState = "canceled"
+)
+
+// Finished reports whether the job reached a final state.
+func (state JobState) Finished() bool {
+	return state == JobDone || state == JobFailed || state == JobCanceled
+}
+
+// JobTicket is the immediate reply of a method registered WithAsync.
+type JobTicket struct {
+	JobID string `json:"job"`
+}
+
+// JobArgs are the params of the built-in job methods.
+type JobArgs struct {
+	JobID string `json:"job"`
+}
+
+// JobStatus describes an asynchronous job.
+type JobStatus struct {
+	JobID    string      `json:"job"`
+	Method   string      `json:"method"`
+	State    JobState    `json:"state"`
+	Progress interface{} `json:"progress,omitempty"`
+	Created  time.Time   `json:"created"`
+	Started  *time.Time  `json:"started,omitempty"`
+	Finished *time.Time  `json:"finished,omitempty"`
+}
+
+// JobRecord is the full state of a job handed to a JobStore.
+type JobRecord struct {
+	JobStatus
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *Error          `json:"error,omitempty"`
+}
+
+// JobStore persists job records, e.g. to keep results across restarts or
+// share them between replicas. Save is called on every state change.
+type JobStore interface {
+	Save(record *JobRecord) error
+}
+
+// WithAsync makes calls of the method run as jobs once the Server has a
+// JobManager: the call immediately returns a JobTicket, and the result is
+// obtained later with the job.result method. The middleware runs before
+// the ticket is issued, so calls it rejects take no place in the queue.
+// Without a JobManager the method runs synchronously.
+func WithAsync() MethodOption {
+	return func(m *methodSpec) { m.async = true }
+}
+
+// JobManager runs the calls of asynchronous methods in a bounded worker
+// pool. Enable it with Server.EnableJobs.
+type JobManager struct {
+	sync.Mutex
+
+	// Workers is the number of jobs run concurrently. Defaults to 4.
+	Workers int
+
+	// QueueSize is the number of jobs that may wait for a worker before
+	// calls are rejected with ErrJobQueueFull. Defaults to 100.
+	QueueSize int
+
+	// Retention is how long finished jobs are kept. Defaults to an hour.
+	Retention time.Duration
+
+	// Store, if set, persists job records.
+	Store JobStore
+
+	// OnFinish, if set, is called when a job reaches a final state, e.g. to
+	// notify the client that started it.
+	OnFinish func(record *JobRecord)
+
+	jobs  map[string]*job
+	queue chan *job
+	once  sync.Once
+}
+
+type job struct {
+	record   JobRecord
+	progress ProgressRecorder
+	clock    Clock
+	ctx      context.Context
+	cancel   context.CancelFunc
+	run      func(ctx context.Context) (result interface{}, err error)
+}
+
+// EnableJobs makes the Server run methods registered WithAsync as jobs
+// managed by m, and registers the job.status, job.result and job.cancel
+// methods.
+func (s *Server) EnableJobs(m *JobManager) (err error) {
+	if err = s.Register("job.status", m.status); err != nil {
+		return
+	}
+	if err = s.Register("job.result", m.result); err != nil {
+		return
+	}
+	if err = s.Register("job.cancel", m.cancelJob); err != nil {
+		return
+	}
+	s.Lock()
+	s.jobs = m
+	s.Unlock()
+	return
+}
+
+func (s *Server) jobManager() *JobManager {
+	s.Lock()
+	defer s.Unlock()
+	return s.jobs
+}
+
+func (m *JobManager) start() {
+	m.once.Do(func() {
+		workers, queueSize := m.Workers, m.QueueSize
+		if workers <= 0 {
+			workers = 4
+		}
+		if queueSize <= 0 {
+			queueSize = 100
+		}
+		m.queue = make(chan *job, queueSize)
+		for i := 0; i < workers; i++ {
+			go m.work()
+		}
+	})
+}
+
+// submit queues a call of method as a new job once the middleware accepted
+// it. The job runs the handler without the middleware, which would
+// otherwise see the call twice.
+func (m *JobManager) submit(s *Server, r *http.Request, method string, spec *methodSpec, args, reply interface{}) (ticket *JobTicket, err error) {
+	err = s.wrap(method, spec, func(r *http.Request, method string, args, reply interface{}) (err error) {
+		ticket, err = m.enqueue(s, r, method, spec, args, reply)
+		return
+	})(r, method, args, reply)
+	return
+}
+
+// enqueue queues a call of method as a new job.
+func (m *JobManager) enqueue(s *Server, r *http.Request, method string, spec *methodSpec, args, reply interface{}) (ticket *JobTicket, err error) {
+	m.start()
+
+	var id string
+	if id, err = newID(); err != nil {
+		return
+	}
+
+	// Jobs outlive the HTTP request, so they get a context of their own
+	// that keeps its values.
+	ctx, cancel := context.WithCancel(detachedContext{r.Context()})
+	j := &job{
+		record: JobRecord{JobStatus: JobStatus{
+			JobID:   id,
+			Method:  method,
+			State:   JobQueued,
+			Created: s.clock().Now(),
+		}},
+		clock:  s.clock(),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	handler := s.endpoint(spec)
+	j.run = func(ctx context.Context) (interface{}, error) {
+		ctx = WithProgress(ctx, &j.progress)
+		if err := s.run(r.WithContext(ctx), method, spec, handler, args, reply); err != nil {
+			return nil, s.mapError(err)
+		}
+		return reply, nil
+	}
+
+	m.Lock()
+	m.collect()
+	if m.jobs == nil {
+		m.jobs = make(map[string]*job)
+	}
+	m.jobs[id] = j
+	m.Unlock()
+
+	select {
+	case m.queue <- j:
+	default:
+		m.Lock()
+		delete(m.jobs, id)
+		m.Unlock()
+		cancel()
+		return nil, ErrJobQueueFull
+	}
+
+	m.save(j)
+	ticket = &JobTicket{id}
+	return
+}
+
+func (m *JobManager) work() {
+	for j := range m.queue {
+		m.Lock()
+		if j.record.State != JobQueued {
+			// Canceled while waiting.
+			m.Unlock()
+			continue
+		}
+		now := j.clock.Now()
+		j.record.State = JobRunning
+		j.record.Started = &now
+		m.Unlock()
+		m.save(j)
+
+		result, err := j.run(j.ctx)
+
+		var raw json.RawMessage
+		if err == nil {
+			if raw, err = json.Marshal(result); err != nil {
+				err = &Error{Code: E_INTERNAL, Message: err.Error()}
+			}
+		}
+
+		m.Lock()
+		now = j.clock.Now()
+		j.record.Finished = &now
+		switch {
+		case j.ctx.Err() != nil:
+			j.record.State = JobCanceled
+		case err != nil:
+			j.record.State = JobFailed
+			j.record.Error = asError(err)
+		default:
+			j.record.State = JobDone
+			j.record.Result = raw
+		}
+		m.Unlock()
+		j.cancel()
+		m.finish(j)
+	}
+}
+
+// finish persists a finished job and reports it.
+func (m *JobManager) finish(j *job) {
+	m.save(j)
+	if m.OnFinish != nil {
+		m.OnFinish(m.snapshot(j))
+	}
+}
+
+func (m *JobManager) save(j *job) {
+	if m.Store != nil {
+		m.Store.Save(m.snapshot(j))
+	}
+}
+
+// snapshot returns a copy of the job's record including its progress.
+func (m *JobManager) snapshot(j *job) *JobRecord {
+	m.Lock()
+	record := j.record
+	m.Unlock()
+	record.Progress, _ = j.progress.Last()
+	return &record
+}
+
+// collect drops finished jobs past their retention. m must be locked.
+func (m *JobManager) collect() {
+	retention := m.Retention
+	if retention <= 0 {
+		retention = time.Hour
+	}
+	for id, j := range m.jobs {
+		if j.record.Finished != nil && since(j.clock, *j.record.Finished) > retention {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func (m *JobManager) get(id string) (j *job, err error) {
+	m.Lock()
+	m.collect()
+	j = m.jobs[id]
+	m.Unlock()
+	if j == nil {
+		err = &Error{Code: E_BAD_PARAMS, Message: "rpc: unknown job " + id}
+	}
+	return
+}
+
+func (m *JobManager) status(r *http.Request, args *JobArgs, reply *JobStatus) (err error) {
+	var j *job
+	if j, err = m.get(args.JobID); err != nil {
+		return
+	}
+	*reply = m.snapshot(j).JobStatus
+	return
+}
+
+func (m *JobManager) result(r *http.Request, args *JobArgs, reply *json.RawMessage) (err error) {
+	var j *job
+	if j, err = m.get(args.JobID); err != nil {
+		return
+	}
+	record := m.snapshot(j)
+	switch record.State {
+	case JobDone:
+		*reply = record.Result
+	case JobFailed:
+		err = record.Error
+	case JobCanceled:
+		err = &Error{Code: E_SERVER, Message: "rpc: job canceled", Data: &record.JobStatus}
+	default:
+		err = &Error{Code: E_SERVER, Message: "rpc: job not finished", Data: &record.JobStatus}
+	}
+	return
+}
+
+func (m *JobManager) cancelJob(r *http.Request, args *JobArgs, reply *JobStatus) (err error) {
+	var j *job
+	if j, err = m.get(args.JobID); err != nil {
+		return
+	}
+	m.Lock()
+	queued := j.record.State == JobQueued
+	if queued {
+		now := j.clock.Now()
+		j.record.State = JobCanceled
+		j.record.Finished = &now
+	}
+	m.Unlock()
+	j.cancel()
+	if queued {
+		m.finish(j)
+	}
+	*reply = m.snapshot(j).JobStatus
+	return
+}
+
+// detachedContext carries the values of a context but neither its deadline
+// nor its cancellation.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) { return }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+// asError converts err into a JSON-RPC error object.
+func asError(err error) *Error {
+	if jsonErr, ok := errorOf(err); ok {
+		return jsonErr
+	}
+	return &Error{Code: E_SERVER, Message: err.Error()}
+}
+
+// newID returns a random 128 bit identifier in hex.
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
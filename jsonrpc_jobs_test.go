@@ -0,0 +1,120 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type jobUserKey struct{}
+
+func TestJobs(t *testing.T) {
+	start := time.Unix(1000, 0)
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	finished := make(chan *JobRecord, 3)
+	var calls int
+	s := &Server{Clock: &stoppedClock{now: start}}
+	s.UseFor("item.*", func(next CallHandler) CallHandler {
+		return func(r *http.Request, method string, args, reply interface{}) error {
+			if r.Context().Value(jobUserKey{}) == nil {
+				return &Error{Code: E_SERVER, Message: "unauthenticated"}
+			}
+			calls++
+			return next(r, method, args, reply)
+		}
+	})
+	if err := s.Register("item.fetch", func(ctx context.Context, args *CacheArgs, reply *string) error {
+		started <- struct{}{}
+		<-release
+		*reply = ctx.Value(jobUserKey{}).(string)
+		return nil
+	}, WithAsync()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EnableJobs(&JobManager{
+		Workers:   1,
+		QueueSize: 1,
+		OnFinish:  func(record *JobRecord) { finished <- record },
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), jobUserKey{}, "alice")
+	call := func(ctx context.Context, method string, params interface{}) *Response {
+		raw, _ := json.Marshal(params)
+		resp, err := s.Dispatcher().HandleRequest(ctx, &Request{Version: Version, Method: method, Params: raw, ID: json.RawMessage("1")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	submit := func(ctx context.Context, n int) (id string, resp *Response) {
+		resp = call(ctx, "item.fetch", &CacheArgs{N: n})
+		var ticket JobTicket
+		json.Unmarshal(resp.Result, &ticket)
+		return ticket.JobID, resp
+	}
+	status := func(id string) (status JobStatus) {
+		json.Unmarshal(call(ctx, "job.status", &JobArgs{id}).Result, &status)
+		return
+	}
+
+	if id, resp := submit(context.Background(), 1); id != "" || resp.Error == nil || resp.Error.Message != "unauthenticated" {
+		t.Fatalf("unauthenticated call got job %q, error %v", id, resp.Error)
+	}
+
+	// The job outlives the request that started it.
+	requestCtx, cancelRequest := context.WithCancel(ctx)
+	running, _ := submit(requestCtx, 1)
+	<-started
+	cancelRequest()
+	queued, _ := submit(ctx, 2)
+	if _, resp := submit(ctx, 1); resp.Error == nil || resp.Error.Message != ErrJobQueueFull.Error() {
+		t.Fatalf("full queue answered %v, want %v", resp.Error, ErrJobQueueFull)
+	}
+	if calls != 3 {
+		t.Errorf("middleware ran %d times, want 3", calls)
+	}
+
+	tests := []struct {
+		name      string
+		id        string
+		wantState JobState
+	}{
+		{"running", running, JobRunning},
+		{"queued", queued, JobQueued},
+	}
+	for _, tt := range tests {
+		if got := status(tt.id); got.State != tt.wantState || !got.Created.Equal(start) {
+			t.Errorf("%s: status = %+v, want state %s created %s", tt.name, got, tt.wantState, start)
+		}
+	}
+
+	if resp := call(ctx, "job.result", &JobArgs{running}); resp.Error == nil {
+		t.Errorf("result of a running job = %s, want error", resp.Result)
+	}
+	var canceled JobStatus
+	json.Unmarshal(call(ctx, "job.cancel", &JobArgs{queued}).Result, &canceled)
+	if canceled.State != JobCanceled {
+		t.Errorf("canceled job is %s", canceled.State)
+	}
+	if record := <-finished; record.JobID != queued || record.State != JobCanceled {
+		t.Errorf("finished %s in state %s, want %s canceled", record.JobID, record.State, queued)
+	}
+
+	close(release)
+	if record := <-finished; record.JobID != running || record.State != JobDone {
+		t.Errorf("finished %s in state %s, want %s done", record.JobID, record.State, running)
+	}
+	if resp := call(ctx, "job.result", &JobArgs{running}); string(resp.Result) != `"alice"` {
+		t.Errorf("result = %s, %v, want \"alice\"", resp.Result, resp.Error)
+	}
+	if resp := call(ctx, "job.status", &JobArgs{"unknown"}); resp.Error == nil || resp.Error.Code != E_BAD_PARAMS {
+		t.Errorf("status of an unknown job = %v, want code %d", resp.Error, E_BAD_PARAMS)
+	}
+	if calls != 3 {
+		t.Errorf("middleware ran %d times, want 3", calls)
+	}
+}
@@ -0,0 +1,147 @@
+package jsonrpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Defaults applied by ListenAndServe and ListenAndServeTLS.
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultReadTimeout       = 30 * time.Second
+	DefaultWriteTimeout      = 60 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+	DefaultMaxHeaderBytes    = 64 << 10
+	DefaultShutdownTimeout   = 30 * time.Second
+)
+
+// ServeOption customizes the http.Server built by ListenAndServe.
+type ServeOption func(*serveConfig)
+
+type serveConfig struct {
+	rpc             *Server
+	server          *http.Server
+	shutdown        context.Context
+	shutdownTimeout time.Duration
+}
+
+// WithReadTimeout sets the maximum duration for reading an entire request.
+func WithReadTimeout(d time.Duration) ServeOption {
+	return func(c *serveConfig) { c.server.ReadTimeout = d }
+}
+
+// WithReadHeaderTimeout sets the maximum duration for reading request headers.
+func WithReadHeaderTimeout(d time.Duration) ServeOption {
+	return func(c *serveConfig) { c.server.ReadHeaderTimeout = d }
+}
+
+// WithWriteTimeout sets the maximum duration before timing out writes of the
+// response. Raise it for handlers that stream partial results for long.
+func WithWriteTimeout(d time.Duration) ServeOption {
+	return func(c *serveConfig) { c.server.WriteTimeout = d }
+}
+
+// WithIdleTimeout sets how long keep-alive connections may stay idle.
+func WithIdleTimeout(d time.Duration) ServeOption {
+	return func(c *serveConfig) { c.server.IdleTimeout = d }
+}
+
+// WithMaxHeaderBytes limits the size of request headers.
+func WithMaxHeaderBytes(n int) ServeOption {
+	return func(c *serveConfig) { c.server.MaxHeaderBytes = n }
+}
+
+// WithHTTPServer gives direct access to the http.Server before it starts,
+// e.g. to set TLSConfig, ErrorLog or wrap Handler with middleware.
+func WithHTTPServer(fn func(*http.Server)) ServeOption {
+	return func(c *serveConfig) { fn(c.server) }
+}
+
+// WithGracefulShutdown shuts the server down once ctx is done, waiting up to
+// timeout for in-flight calls to finish. Sessions served by ServeConn are
+// drained over the same timeout. ListenAndServe then returns nil.
+func WithGracefulShutdown(ctx context.Context, timeout time.Duration) ServeOption {
+	return func(c *serveConfig) {
+		c.shutdown = ctx
+		c.shutdownTimeout = timeout
+	}
+}
+
+func (s *Server) newServeConfig(addr string, opts []ServeOption) *serveConfig {
+	c := &serveConfig{
+		rpc: s,
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           s,
+			ReadHeaderTimeout: DefaultReadHeaderTimeout,
+			ReadTimeout:       DefaultReadTimeout,
+			WriteTimeout:      DefaultWriteTimeout,
+			IdleTimeout:       DefaultIdleTimeout,
+			MaxHeaderBytes:    DefaultMaxHeaderBytes,
+		},
+		shutdownTimeout: DefaultShutdownTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ListenAndServe serves the Server on addr using an http.Server with
+// timeouts and header limits set, unlike the zero http.Server.
+func (s *Server) ListenAndServe(addr string, opts ...ServeOption) error {
+	c := s.newServeConfig(addr, opts)
+	return c.serve(":http", c.server.Serve)
+}
+
+// ListenAndServeTLS is like ListenAndServe but serves HTTPS.
+func (s *Server) ListenAndServeTLS(addr, certFile, keyFile string, opts ...ServeOption) error {
+	c := s.newServeConfig(addr, opts)
+	return c.serve(":https", func(l net.Listener) error {
+		return c.server.ServeTLS(l, certFile, keyFile)
+	})
+}
+
+func (c *serveConfig) serve(defaultAddr string, serve func(l net.Listener) error) (err error) {
+	addr := c.server.Addr
+	if addr == "" {
+		addr = defaultAddr
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return
+	}
+	if c.rpc.OnStart != nil {
+		c.rpc.OnStart(l.Addr())
+	}
+	if c.rpc.OnShutdown != nil {
+		defer c.rpc.OnShutdown()
+	}
+
+	listen := func() error { return serve(l) }
+	if c.shutdown == nil {
+		return listen()
+	}
+
+	done := make(chan error, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-c.shutdown.Done():
+		case <-stop:
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), c.shutdownTimeout)
+		defer cancel()
+		go c.rpc.Drain(ctx, c.shutdownTimeout, "shutdown")
+		done <- c.server.Shutdown(ctx)
+	}()
+
+	if err = listen(); err != http.ErrServerClosed || c.shutdown.Err() == nil {
+		return
+	}
+	return <-done
+}
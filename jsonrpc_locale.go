@@ -0,0 +1,92 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MessageCatalog provides localized error messages. Codes stay the same in
+// every language; only the message changes.
+type MessageCatalog interface {
+	// Message returns the message for code in lang, a BCP 47 tag such as
+	// "de" or "pt-BR", and whether one exists.
+	Message(code ErrorCode, lang string) (message string, ok bool)
+}
+
+// CatalogMap is a static MessageCatalog keyed by language, then code.
+type CatalogMap map[string]map[ErrorCode]string
+
+func (m CatalogMap) Message(code ErrorCode, lang string) (message string, ok bool) {
+	message, ok = m[lang][code]
+	return
+}
+
+type languageKey struct{}
+
+// WithLanguage returns a copy of ctx requesting error messages in lang. It
+// takes precedence over the Accept-Language header.
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+// languages returns the languages requested for r in order of preference.
+func languages(r *http.Request) []string {
+	if lang, ok := r.Context().Value(languageKey{}).(string); ok && lang != "" {
+		return []string{lang}
+	}
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var prefs []weighted
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		fields := strings.Split(part, ";")
+		lang := strings.TrimSpace(fields[0])
+		if lang == "" || lang == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			prefs = append(prefs, weighted{lang, q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+	langs := make([]string, len(prefs))
+	for i, pref := range prefs {
+		langs[i] = pref.lang
+	}
+	return langs
+}
+
+// localize returns err with its message taken from catalog in the language
+// preferred by r, falling back from a regional tag to its base language.
+func localize(catalog MessageCatalog, r *http.Request, err error) error {
+	jsonErr, ok := errorOf(err)
+	if !ok {
+		jsonErr = &Error{Code: E_SERVER, Message: err.Error()}
+	}
+	for _, lang := range languages(r) {
+		for {
+			if message, ok := catalog.Message(jsonErr.Code, lang); ok {
+				return &Error{Code: jsonErr.Code, Message: message, Data: jsonErr.Data}
+			}
+			i := strings.LastIndex(lang, "-")
+			if i == -1 {
+				break
+			}
+			lang = lang[:i]
+		}
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package jsonrpc
+
+import "sync/atomic"
+
+// E_MAINTENANCE is the code of the error answering calls of mutating
+// methods while the Server is in maintenance mode.
+const E_MAINTENANCE ErrorCode = -32001
+
+// WithMutating marks the method as changing state, so that it is rejected
+// while the Server is in maintenance mode.
+func WithMutating() MethodOption {
+	return func(m *methodSpec) { m.mutating = true }
+}
+
+// SetMaintenance turns maintenance mode on or off. In maintenance mode,
+// calls of methods registered WithMutating fail with an E_MAINTENANCE
+// error, while all other methods are served as usual, e.g. to migrate the
+// backing store without a full downtime. This covers calls in batches and
+// through the Dispatcher, and async jobs that start running during
+// maintenance. The JSON codec answers with status 200 like for any other
+// error; codecs answering errors with HTTP statuses use 503.
+func (s *Server) SetMaintenance(on bool) {
+	var flag int32
+	if on {
+		flag = 1
+	}
+	atomic.StoreInt32(&s.maintenance, flag)
+}
+
+// Maintenance reports whether the Server is in maintenance mode.
+func (s *Server) Maintenance() bool {
+	return atomic.LoadInt32(&s.maintenance) != 0
+}
+
+// checkMaintenance fails calls of spec if it is mutating and the Server is
+// in maintenance mode.
+func (s *Server) checkMaintenance(spec *methodSpec) error {
+	if spec.mutating && s.Maintenance() {
+		return &Error{Code: E_MAINTENANCE, Message: "rpc: method unavailable during maintenance"}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type MaintenanceArgs struct {
+	N int `json:"n"`
+}
+
+func TestMaintenance(t *testing.T) {
+	s := new(Server)
+	handler := func(r *http.Request, args *MaintenanceArgs, reply *int) error {
+		*reply = args.N
+		return nil
+	}
+	if err := s.Register("item.get", handler); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register("item.put", handler, WithMutating()); err != nil {
+		t.Fatal(err)
+	}
+	s.SetMaintenance(true)
+
+	tests := []struct {
+		name      string
+		raw       string
+		wantCodes []ErrorCode // per response, 0 for success
+	}{
+		{"read", `{"jsonrpc":"2.0","id":1,"method":"item.get","params":{"n":1}}`, []ErrorCode{0}},
+		{"mutating", `{"jsonrpc":"2.0","id":1,"method":"item.put","params":{"n":1}}`, []ErrorCode{E_MAINTENANCE}},
+		{"batch", `[{"jsonrpc":"2.0","id":1,"method":"item.get","params":{"n":1}},{"jsonrpc":"2.0","id":2,"method":"item.put","params":{"n":1}}]`,
+			[]ErrorCode{0, E_MAINTENANCE}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := s.Dispatcher().Handle(context.Background(), []byte(tt.raw))
+			var responses []Response
+			if strings.HasPrefix(tt.raw, "[") {
+				if err := json.Unmarshal(raw, &responses); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				responses = make([]Response, 1)
+				if err := json.Unmarshal(raw, &responses[0]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(responses) != len(tt.wantCodes) {
+				t.Fatalf("got %d responses, want %d", len(responses), len(tt.wantCodes))
+			}
+			for i, resp := range responses {
+				var code ErrorCode
+				if resp.Error != nil {
+					code = resp.Error.Code
+				}
+				if code != tt.wantCodes[i] {
+					t.Errorf("response %d: code %d, want %d", i, code, tt.wantCodes[i])
+				}
+			}
+		})
+	}
+
+	spec, _ := s.get("item.put")
+	if first, second := s.checkMaintenance(spec), s.checkMaintenance(spec); first == second {
+		t.Error("checkMaintenance returned a shared error")
+	}
+}
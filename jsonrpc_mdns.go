@@ -0,0 +1,331 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// DNS record types and classes used by MDNSRegistry.
+const (
+	dnsTypeA   = 1
+	dnsTypePTR = 12
+	dnsTypeTXT = 16
+	dnsTypeSRV = 33
+	dnsTypeANY = 255
+
+	dnsClassIN    = 1
+	dnsCacheFlush = 0x8000
+)
+
+// MDNSRegistry advertises servers on the local network with multicast DNS
+// service discovery (RFC 6762, RFC 6763). An Advertisement for service
+// "downloads" is published as the DNS-SD service "_downloads._tcp.local."
+// with the path and scheme of the endpoint and the method names in TXT
+// records; method names are split over the keys "m0", "m1" and so on.
+type MDNSRegistry struct {
+	sync.Mutex
+
+	// Interface, if set, restricts the registry to one network interface.
+	Interface *net.Interface
+
+	conn    *net.UDPConn
+	records map[string][]dnsRecord // by instance
+}
+
+type dnsRecord struct {
+	name  string
+	rtype uint16
+	class uint16
+	ttl   uint32
+	data  []byte
+}
+
+// Register announces ad and answers queries for it until it is
+// deregistered.
+func (m *MDNSRegistry) Register(ctx context.Context, ad *Advertisement) (err error) {
+	var records []dnsRecord
+	if records, err = mdnsRecords(ad); err != nil {
+		return
+	}
+
+	m.Lock()
+	if m.conn == nil {
+		if m.conn, err = net.ListenMulticastUDP("udp4", m.Interface, mdnsGroup); err != nil {
+			m.Unlock()
+			return
+		}
+		go m.serve(m.conn)
+	}
+	if m.records == nil {
+		m.records = make(map[string][]dnsRecord)
+	}
+	m.records[ad.Instance] = records
+	conn := m.conn
+	m.Unlock()
+
+	_, err = conn.WriteToUDP(dnsResponse(records), mdnsGroup)
+	return
+}
+
+// Deregister withdraws ad by announcing its records with a zero TTL.
+func (m *MDNSRegistry) Deregister(ctx context.Context, ad *Advertisement) (err error) {
+	m.Lock()
+	records, ok := m.records[ad.Instance]
+	delete(m.records, ad.Instance)
+	conn, last := m.conn, len(m.records) == 0
+	if last {
+		m.conn = nil
+	}
+	m.Unlock()
+	if !ok {
+		return
+	}
+
+	goodbye := make([]dnsRecord, len(records))
+	for i, record := range records {
+		record.ttl = 0
+		goodbye[i] = record
+	}
+	_, err = conn.WriteToUDP(dnsResponse(goodbye), mdnsGroup)
+	if last {
+		conn.Close()
+	}
+	return
+}
+
+// serve answers queries for the registered advertisements until conn is
+// closed.
+func (m *MDNSRegistry) serve(conn *net.UDPConn) {
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		questions, err := dnsQuestions(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		m.Lock()
+		var answers []dnsRecord
+		for _, records := range m.records {
+			if dnsMatches(records, questions) {
+				answers = append(answers, records...)
+			}
+		}
+		m.Unlock()
+		if len(answers) != 0 {
+			conn.WriteToUDP(dnsResponse(answers), mdnsGroup)
+		}
+	}
+}
+
+type dnsQuestion struct {
+	name  string
+	qtype uint16
+}
+
+func dnsMatches(records []dnsRecord, questions []dnsQuestion) bool {
+	for _, q := range questions {
+		for _, r := range records {
+			if strings.EqualFold(q.name, r.name) && (q.qtype == r.rtype || q.qtype == dnsTypeANY) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mdnsRecords returns the DNS-SD records advertising ad.
+func mdnsRecords(ad *Advertisement) (records []dnsRecord, err error) {
+	var u *url.URL
+	if u, err = url.Parse(ad.Endpoint); err != nil {
+		return
+	}
+	port := 80
+	if u.Scheme == "https" {
+		port = 443
+	}
+	if p := u.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return
+		}
+	}
+	var hostname string
+	if hostname, err = os.Hostname(); err != nil {
+		return
+	}
+	hostname = strings.Split(hostname, ".")[0]
+	if ad.Instance == "" {
+		ad.Instance = hostname
+	}
+
+	service := ad.Service
+	if !strings.HasPrefix(service, "_") {
+		service = "_" + service + "._tcp"
+	}
+	service += ".local."
+	instance := ad.Instance + "." + service
+	host := hostname + ".local."
+	ttl := uint32(ad.TTL)
+
+	txt := []string{"scheme=" + u.Scheme, "path=" + u.Path}
+	keys := make([]string, 0, len(ad.Metadata))
+	for key := range ad.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		txt = append(txt, key+"="+ad.Metadata[key])
+	}
+	var chunks []string
+	for i, method := range ad.Methods {
+		if i == 0 || len(chunks[len(chunks)-1])+1+len(method) > 240 {
+			chunks = append(chunks, method)
+		} else {
+			chunks[len(chunks)-1] += "," + method
+		}
+	}
+	for i, chunk := range chunks {
+		txt = append(txt, "m"+strconv.Itoa(i)+"="+chunk)
+	}
+
+	srv := make([]byte, 6)
+	binary.BigEndian.PutUint16(srv[4:], uint16(port))
+	records = []dnsRecord{
+		{"_services._dns-sd._udp.local.", dnsTypePTR, dnsClassIN, ttl, dnsName(service)},
+		{service, dnsTypePTR, dnsClassIN, ttl, dnsName(instance)},
+		{instance, dnsTypeSRV, dnsClassIN | dnsCacheFlush, ttl, append(srv, dnsName(host)...)},
+		{instance, dnsTypeTXT, dnsClassIN | dnsCacheFlush, ttl, dnsText(txt)},
+	}
+	for _, ip := range endpointIPs(u.Hostname()) {
+		records = append(records, dnsRecord{host, dnsTypeA, dnsClassIN | dnsCacheFlush, ttl, ip})
+	}
+	return
+}
+
+// endpointIPs returns the IPv4 addresses to advertise for an endpoint
+// host: the host itself if it is a specific address, else those of the
+// network interfaces.
+func endpointIPs(host string) (ips []net.IP) {
+	if ip := net.ParseIP(host).To4(); ip != nil && !ip.IsUnspecified() {
+		return []net.IP{ip}
+	}
+	addrs, _ := net.InterfaceAddrs()
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
+			if ip := ipNet.IP.To4(); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return
+}
+
+func dnsName(name string) (b []byte) {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) > 63 {
+			label = label[:63]
+		}
+		b = append(append(b, byte(len(label))), label...)
+	}
+	return append(b, 0)
+}
+
+func dnsText(strs []string) (b []byte) {
+	for _, s := range strs {
+		if len(s) > 255 {
+			s = s[:255]
+		}
+		b = append(append(b, byte(len(s))), s...)
+	}
+	return
+}
+
+// dnsResponse encodes an authoritative multicast DNS response.
+func dnsResponse(records []dnsRecord) []byte {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[2:], 0x8400)
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(records)))
+	for _, r := range records {
+		msg = append(msg, dnsName(r.name)...)
+		var fixed [10]byte
+		binary.BigEndian.PutUint16(fixed[0:], r.rtype)
+		binary.BigEndian.PutUint16(fixed[2:], r.class)
+		binary.BigEndian.PutUint32(fixed[4:], r.ttl)
+		binary.BigEndian.PutUint16(fixed[8:], uint16(len(r.data)))
+		msg = append(append(msg, fixed[:]...), r.data...)
+	}
+	return msg
+}
+
+var errDNSMessage = errors.New("rpc: malformed DNS message")
+
+// dnsQuestions parses the questions of a DNS query.
+func dnsQuestions(msg []byte) (questions []dnsQuestion, err error) {
+	if len(msg) < 12 {
+		return nil, errDNSMessage
+	}
+	if binary.BigEndian.Uint16(msg[2:])&0x8000 != 0 {
+		return // a response
+	}
+	off := 12
+	for i := binary.BigEndian.Uint16(msg[4:]); i > 0; i-- {
+		var name string
+		if name, off, err = dnsReadName(msg, off); err != nil {
+			return
+		}
+		if off+4 > len(msg) {
+			return nil, errDNSMessage
+		}
+		questions = append(questions, dnsQuestion{name, binary.BigEndian.Uint16(msg[off:])})
+		off += 4
+	}
+	return
+}
+
+// dnsReadName reads a possibly compressed name at off, returning it with
+// a trailing dot and the offset after it.
+func dnsReadName(msg []byte, off int) (name string, next int, err error) {
+	var labels []string
+	next = -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errDNSMessage
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if next == -1 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case n&0xC0 == 0xC0:
+			if off+1 >= len(msg) || jumps > 16 {
+				return "", 0, errDNSMessage
+			}
+			if next == -1 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+			jumps++
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errDNSMessage
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
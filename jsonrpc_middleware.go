@@ -0,0 +1,127 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+)
+
+// CallHandler serves one call. args and reply are pointers to the method's
+// argument and reply values.
+type CallHandler func(r *http.Request, method string, args, reply interface{}) error
+
+// Middleware wraps a CallHandler, e.g. to authenticate, cache or measure
+// calls.
+type Middleware func(next CallHandler) CallHandler
+
+type patternMiddleware struct {
+	pattern    string
+	middleware []Middleware
+}
+
+// Use appends middleware run for every call, before any method specific
+// middleware.
+func (s *Server) Use(middleware ...Middleware) {
+	s.Lock()
+	s.middleware = append(s.middleware, middleware...)
+	s.publish()
+	s.Unlock()
+}
+
+// UseFor appends middleware run for calls of methods matching pattern, a
+// path.Match pattern such as "admin.*". It runs after the global middleware
+// and before the middleware attached with WithMiddleware. With
+// CaseInsensitiveMethods set, the pattern matches names ignoring case.
+func (s *Server) UseFor(pattern string, middleware ...Middleware) {
+	s.Lock()
+	s.patternMiddleware = append(s.patternMiddleware, patternMiddleware{pattern, middleware})
+	s.publish()
+	s.Unlock()
+}
+
+// WithMiddleware attaches middleware to a single method. It runs after the
+// global and pattern middleware.
+func WithMiddleware(middleware ...Middleware) MethodOption {
+	return func(m *methodSpec) { m.middleware = append(m.middleware, middleware...) }
+}
+
+// chain returns the handler of method wrapped in all middleware that
+// applies to it, outermost first.
+func (s *Server) chain(method string, spec *methodSpec) CallHandler {
+	return s.wrap(method, spec, s.endpoint(spec))
+}
+
+// endpoint returns the handler of a method without its middleware.
+func (s *Server) endpoint(spec *methodSpec) CallHandler {
+	handler := spec.call
+	if spec.cacheTTL > 0 {
+		handler = s.cached(spec.cacheTTL, handler)
+	}
+	return handler
+}
+
+// wrap wraps handler in the middleware that applies to method.
+func (s *Server) wrap(method string, spec *methodSpec, handler CallHandler) CallHandler {
+	reg := s.routing()
+	middleware := append([]Middleware(nil), reg.middleware...)
+	for _, pm := range reg.patternMiddleware {
+		if matchName(pm.pattern, method, s.CaseInsensitiveMethods) {
+			middleware = append(middleware, pm.middleware...)
+		}
+	}
+	middleware = append(middleware, spec.middleware...)
+
+	// Quotas run inside the middleware, so that the tenant can be told from
+	// what authentication middleware stored in the request.
+	if s.Tenants != nil {
+		handler = s.Tenants.wrap(s.clock(), handler)
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// call invokes the registered handler function.
+func (m *methodSpec) call(r *http.Request, method string, args, reply interface{}) error {
+	if m.pattern {
+		r = withMethod(r, method)
+	}
+	if m.adapter != nil {
+		return m.adapter.Call(r, args, reply)
+	}
+	first := reflect.ValueOf(r)
+	if m.context {
+		first = reflect.ValueOf(handlerContext(r))
+	}
+	in := []reflect.Value{first}
+	if !m.noArgs {
+		in = append(in, reflect.ValueOf(args))
+	}
+	if !m.noReply {
+		in = append(in, reflect.ValueOf(reply))
+	}
+	errValue := m.method.Call(in)
+	if m.returnsReply {
+		reflect.ValueOf(reply).Elem().Set(errValue[0])
+		errValue = errValue[1:]
+	}
+	if errInter := errValue[0].Interface(); errInter != nil {
+		return errInter.(error)
+	}
+	return nil
+}
+
+type requestKey struct{}
+
+// handlerContext returns the context passed to handlers that take one.
+func handlerContext(r *http.Request) context.Context {
+	return context.WithValue(r.Context(), requestKey{}, r)
+}
+
+// RequestFromContext returns the request being served, for handlers that
+// take a context.Context in place of the *http.Request.
+func RequestFromContext(ctx context.Context) *http.Request {
+	r, _ := ctx.Value(requestKey{}).(*http.Request)
+	return r
+}
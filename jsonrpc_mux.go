@@ -0,0 +1,143 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxMuxRequestSize is the default of ServerMux.MaxRequestSize.
+const DefaultMaxMuxRequestSize = 8 << 20
+
+// ServerMux exposes independently owned Servers behind one endpoint. It
+// routes requests by URL path first, then by the prefix of the method
+// called, e.g. "downloads." to the Server of the downloads team. Batches
+// are routed if all their calls go to the same Server; multipart requests
+// are only routed by path.
+type ServerMux struct {
+	sync.Mutex
+
+	// MaxRequestSize bounds the bodies read to route them by method.
+	// Larger ones are answered with status 413. Defaults to
+	// DefaultMaxMuxRequestSize.
+	MaxRequestSize int64
+
+	paths    map[string]*Server
+	prefixes []muxPrefix // longest first
+}
+
+type muxPrefix struct {
+	prefix string
+	server *Server
+}
+
+// HandlePath routes the requests to path to server.
+func (mux *ServerMux) HandlePath(path string, server *Server) {
+	mux.Lock()
+	defer mux.Unlock()
+	if mux.paths == nil {
+		mux.paths = make(map[string]*Server)
+	}
+	mux.paths[path] = server
+}
+
+// HandlePrefix routes the calls of methods starting with prefix to server.
+// The longest matching prefix wins; an empty prefix matches all methods.
+func (mux *ServerMux) HandlePrefix(prefix string, server *Server) {
+	mux.Lock()
+	defer mux.Unlock()
+	// Replace rather than modify the slice, which requests being routed
+	// may still read.
+	prefixes := []muxPrefix{{prefix, server}}
+	for _, p := range mux.prefixes {
+		if p.prefix != prefix {
+			prefixes = append(prefixes, p)
+		}
+	}
+	sort.SliceStable(prefixes, func(i, j int) bool {
+		return len(prefixes[i].prefix) > len(prefixes[j].prefix)
+	})
+	mux.prefixes = prefixes
+}
+
+func (mux *ServerMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mux.Lock()
+	server, ok := mux.paths[r.URL.Path]
+	prefixes := mux.prefixes
+	mux.Unlock()
+	if ok {
+		server.ServeHTTP(w, r)
+		return
+	}
+	if len(prefixes) == 0 || r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+	if _, ok := isMultipartRelated(r.Header); ok {
+		WriteError(w, http.StatusUnsupportedMediaType, "rpc: multipart requests are only routed by path")
+		return
+	}
+
+	// Peek at the methods, leaving the body to the Server.
+	limit := mux.MaxRequestSize
+	if limit <= 0 {
+		limit = DefaultMaxMuxRequestSize
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	r.Body.Close()
+	if err != nil {
+		status := http.StatusBadRequest
+		if isTooLarge(err) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		WriteError(w, status, "rpc: "+err.Error())
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if server, err = route(prefixes, body); err == nil {
+		server.ServeHTTP(w, r)
+		return
+	}
+	codecReq := NewCodec().NewRequest(r)
+	codecReq.WriteError(w, http.StatusBadRequest, err)
+}
+
+// route returns the Server of the calls in body, a call or a batch.
+func route(prefixes []muxPrefix, body []byte) (server *Server, err error) {
+	type call struct {
+		Method string `json:"method"`
+	}
+	var calls []call
+	if trimmed := bytes.TrimLeft(body, " \t\r\n"); len(trimmed) != 0 && trimmed[0] == '[' {
+		if err = json.Unmarshal(trimmed, &calls); err == nil && len(calls) == 0 {
+			err = &Error{Code: E_INVALID_REQ, Message: "rpc: empty batch"}
+		}
+	} else {
+		calls = make([]call, 1)
+		err = json.Unmarshal(body, &calls[0])
+	}
+	if err != nil {
+		return
+	}
+	for _, c := range calls {
+		var matched *Server
+		for _, p := range prefixes {
+			if strings.HasPrefix(c.Method, p.prefix) {
+				matched = p.server
+				break
+			}
+		}
+		switch {
+		case matched == nil:
+			return nil, errMethodNotFound(c.Method)
+		case server != nil && matched != server:
+			return nil, &Error{Code: E_INVALID_REQ, Message: "rpc: batch calls methods of different servers"}
+		}
+		server = matched
+	}
+	return
+}
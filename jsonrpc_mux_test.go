@@ -0,0 +1,64 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerMux(t *testing.T) {
+	newServer := func(method string) *Server {
+		s := new(Server)
+		if err := s.Register(method, func(r *http.Request, args *CacheArgs, reply *int) error {
+			*reply = args.N
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	mux := &ServerMux{MaxRequestSize: 1024}
+	mux.HandlePrefix("a.", newServer("a.get"))
+	mux.HandlePrefix("b.", newServer("b.get"))
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantCode    ErrorCode
+	}{
+		{"call", "application/json", `{"jsonrpc":"2.0","id":1,"method":"a.get","params":{"n":1}}`, http.StatusOK, 0},
+		{"batch", "application/json", `[{"jsonrpc":"2.0","id":1,"method":"b.get","params":{"n":1}},{"jsonrpc":"2.0","id":2,"method":"b.get","params":{"n":2}}]`, http.StatusOK, 0},
+		{"mixed batch", "application/json", `[{"jsonrpc":"2.0","id":1,"method":"a.get"},{"jsonrpc":"2.0","id":2,"method":"b.get"}]`, http.StatusOK, E_INVALID_REQ},
+		{"empty batch", "application/json", ` []`, http.StatusOK, E_INVALID_REQ},
+		{"unknown", "application/json", `{"jsonrpc":"2.0","id":1,"method":"c.get"}`, http.StatusOK, E_NO_METHOD},
+		{"too large", "application/json", `{"jsonrpc":"2.0","id":1,"method":"a.get","params":{"s":"` + strings.Repeat("x", 2048) + `"}}`, http.StatusRequestEntityTooLarge, 0},
+		{"multipart", "multipart/related; boundary=x", "--x--", http.StatusUnsupportedMediaType, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var resp Response
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			var code ErrorCode
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			if code != tt.wantCode {
+				t.Errorf("code = %d, want %d: %s", code, tt.wantCode, w.Body)
+			}
+		})
+	}
+}
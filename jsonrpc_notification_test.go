@@ -0,0 +1,53 @@
+package jsonrpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNotification(t *testing.T) {
+	handler := func(r *http.Request, args *CacheArgs, reply *int) error {
+		*reply = args.N
+		return nil
+	}
+	tests := []struct {
+		name   string
+		opts   []MethodOption
+		header map[string]string
+	}{
+		{"plain", nil, nil},
+		{"etag", []MethodOption{WithETag()}, map[string]string{"If-None-Match": "*"}},
+		{"delta", []MethodOption{WithDelta(0)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := new(Server)
+			if err := s.Register("item.get", handler, tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest("POST", "/", strings.NewReader(`{"jsonrpc":"2.0","method":"item.get","params":{"n":1}}`))
+			r.Header.Set("Content-Type", "application/json")
+			for key, value := range tt.header {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			if w.Code != http.StatusNoContent {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusNoContent)
+			}
+			for _, header := range []string{"ETag", ResultHashHeader} {
+				if v := w.Header().Get(header); v != "" {
+					t.Errorf("%s = %q, want none", header, v)
+				}
+			}
+			if w.Body.Len() != 0 {
+				t.Errorf("body = %q, want none", w.Body)
+			}
+			if spec, _ := s.get("item.get"); spec.delta != nil && len(spec.delta.results) != 0 {
+				t.Errorf("delta history holds %d results, want none", len(spec.delta.results))
+			}
+		})
+	}
+}
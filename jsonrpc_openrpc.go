@@ -0,0 +1,317 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OpenRPCVersion is the version of the OpenRPC specification of generated
+// documents.
+const OpenRPCVersion = "1.2.6"
+
+// OpenRPCDocument describes a Server's methods following the OpenRPC
+// specification.
+type OpenRPCDocument struct {
+	OpenRPC    string             `json:"openrpc"`
+	Info       OpenRPCInfo        `json:"info"`
+	Methods    []*OpenRPCMethod   `json:"methods"`
+	Components *OpenRPCComponents `json:"components,omitempty"`
+}
+
+// OpenRPCInfo is the metadata of an OpenRPCDocument.
+type OpenRPCInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenRPCMethod describes one method.
+type OpenRPCMethod struct {
+	Name           string               `json:"name"`
+	Summary        string               `json:"summary,omitempty"`
+	Description    string               `json:"description,omitempty"`
+	Tags           []OpenRPCTag         `json:"tags,omitempty"`
+	ParamStructure string               `json:"paramStructure,omitempty"`
+	Params         []*ContentDescriptor `json:"params"`
+	Result         *ContentDescriptor   `json:"result"`
+	Examples       []OpenRPCExample     `json:"examples,omitempty"`
+	Deprecated     bool                 `json:"deprecated,omitempty"`
+}
+
+// OpenRPCTag groups methods.
+type OpenRPCTag struct {
+	Name string `json:"name"`
+}
+
+// OpenRPCExample is an example pairing of params and result.
+type OpenRPCExample struct {
+	Name   string                `json:"name"`
+	Params []OpenRPCExampleValue `json:"params"`
+	Result OpenRPCExampleValue   `json:"result"`
+}
+
+// OpenRPCExampleValue is a named example value.
+type OpenRPCExampleValue struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// OpenRPCComponents holds definitions shared by methods.
+type OpenRPCComponents struct {
+	Errors map[string]*OpenRPCError `json:"errors,omitempty"`
+}
+
+// OpenRPCError describes an application error code.
+type OpenRPCError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// ContentDescriptor describes a param or result.
+type ContentDescriptor struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Schema is the subset of JSON Schema generated for Go types.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+}
+
+var (
+	typeOfTime       = reflect.TypeOf(time.Time{})
+	typeOfRawMessage = reflect.TypeOf(json.RawMessage{})
+)
+
+// SchemaOf returns the JSON Schema of values of t as encoding/json
+// represents them. Recursive types are cut off with an empty schema.
+func SchemaOf(t reflect.Type) *Schema {
+	return schemaOf(t, make(map[reflect.Type]bool))
+}
+
+func schemaOf(t reflect.Type, seen map[reflect.Type]bool) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case typeOfTime:
+		return &Schema{Type: "string", Format: "date-time"}
+	case typeOfRawMessage:
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: schemaOf(t.Elem(), seen)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return &Schema{}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		for _, f := range jsonFields(t) {
+			schema.Properties[f.name] = schemaOf(f.typ, seen)
+			if f.required {
+				schema.Required = append(schema.Required, f.name)
+			}
+		}
+		return schema
+	}
+	return &Schema{}
+}
+
+// jsonField is a struct field as encoding/json sees it.
+type jsonField struct {
+	name     string
+	index    []int
+	typ      reflect.Type
+	required bool
+}
+
+// jsonFields lists the fields encoding/json marshals for struct type t in
+// declaration order, flattening embedded structs. A field is required when
+// tagged `jsonrpc:"required"`; encoding/json accepts any field missing.
+func jsonFields(t reflect.Type) (fields []jsonField) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := tag
+		if i := strings.Index(tag, ","); i != -1 {
+			name = tag[:i]
+		}
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for _, inner := range jsonFields(ft) {
+					inner.index = append([]int{i}, inner.index...)
+					fields = append(fields, inner)
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{
+			name:     name,
+			index:    []int{i},
+			typ:      f.Type,
+			required: hasTagOption(f.Tag.Get("jsonrpc"), "required"),
+		})
+	}
+	return
+}
+
+// hasTagOption reports whether the options of a jsonrpc tag include
+// option.
+func hasTagOption(tag, option string) bool {
+	for _, opt := range tagOptions(tag) {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// tagOptions splits a jsonrpc tag into its comma separated options. A
+// default runs until the next option, so JSON defaults may contain
+// commas, as in `jsonrpc:"default=[1,2],required"`.
+func tagOptions(tag string) (options []string) {
+	for _, part := range strings.Split(tag, ",") {
+		opt := strings.TrimSpace(part)
+		last := len(options) - 1
+		if last >= 0 && strings.HasPrefix(options[last], "default=") && opt != "required" && !strings.HasPrefix(opt, "default=") {
+			options[last] += "," + part
+			continue
+		}
+		options = append(options, opt)
+	}
+	return
+}
+
+// OpenRPC generates the OpenRPC document of the registered methods.
+func (s *Server) OpenRPC(info OpenRPCInfo) *OpenRPCDocument {
+	doc := &OpenRPCDocument{OpenRPC: OpenRPCVersion, Info: info}
+
+	s.Lock()
+	names := make([]string, 0, len(s.methods))
+	for name := range s.methods {
+		names = append(names, name)
+	}
+	specs := make(map[string]*methodSpec, len(s.methods))
+	for name, spec := range s.methods {
+		specs[name] = spec
+	}
+	s.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		doc.Methods = append(doc.Methods, specs[name].openRPC(name))
+	}
+
+	if s.Errors != nil {
+		if defs := s.Errors.Errors(); len(defs) != 0 {
+			doc.Components = &OpenRPCComponents{Errors: make(map[string]*OpenRPCError)}
+			for _, def := range defs {
+				doc.Components.Errors[def.Name] = &OpenRPCError{Code: def.Code, Message: def.Description}
+			}
+		}
+	}
+	return doc
+}
+
+func (spec *methodSpec) openRPC(name string) *OpenRPCMethod {
+	m := &OpenRPCMethod{
+		Name:           name,
+		Summary:        spec.doc.Summary,
+		Description:    spec.doc.Description,
+		ParamStructure: spec.paramStructure.String(),
+		Params:         []*ContentDescriptor{},
+		Result:         &ContentDescriptor{Name: "result", Schema: SchemaOf(spec.replyType)},
+		Deprecated:     spec.doc.Deprecated,
+	}
+	for _, tag := range spec.doc.Tags {
+		m.Tags = append(m.Tags, OpenRPCTag{tag})
+	}
+
+	descriptions := make(map[string]ParamDoc)
+	for _, p := range spec.doc.Params {
+		descriptions[p.Name] = p
+	}
+	defaults := make(map[string]interface{})
+	for _, d := range spec.defaults {
+		defaults[d.name] = d.value.Interface()
+	}
+	argsType := spec.argsType
+	for argsType.Kind() == reflect.Ptr {
+		argsType = argsType.Elem()
+	}
+	if argsType.Kind() == reflect.Struct {
+		for _, f := range jsonFields(argsType) {
+			p := &ContentDescriptor{Name: f.name, Required: f.required, Schema: SchemaOf(f.typ)}
+			if value, ok := defaults[f.name]; ok {
+				p.Required, p.Schema.Default = false, value
+			}
+			if d, ok := descriptions[f.name]; ok {
+				p.Description = d.Description
+				p.Required = p.Required || d.Required
+			}
+			m.Params = append(m.Params, p)
+		}
+	} else {
+		m.Params = append(m.Params, &ContentDescriptor{Name: "params", Required: true, Schema: SchemaOf(argsType)})
+	}
+
+	for _, example := range spec.doc.Examples {
+		e := OpenRPCExample{Name: example.Name, Result: OpenRPCExampleValue{Name: "result", Value: example.Result}}
+		var params map[string]interface{}
+		if raw, err := json.Marshal(example.Params); err == nil && json.Unmarshal(raw, &params) == nil {
+			keys := make([]string, 0, len(params))
+			for key := range params {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				e.Params = append(e.Params, OpenRPCExampleValue{Name: key, Value: params[key]})
+			}
+		} else {
+			e.Params = append(e.Params, OpenRPCExampleValue{Name: "params", Value: example.Params})
+		}
+		m.Examples = append(m.Examples, e)
+	}
+	return m
+}
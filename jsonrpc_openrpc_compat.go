@@ -0,0 +1,149 @@
+package jsonrpc
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Change is a difference between two OpenRPC documents.
+type Change struct {
+	Method string `json:"method"`
+
+	// Breaking changes make existing clients fail or misbehave.
+	Breaking bool `json:"breaking"`
+
+	Message string `json:"message"`
+}
+
+func (c Change) String() string {
+	kind := "compatible"
+	if c.Breaking {
+		kind = "BREAKING"
+	}
+	return fmt.Sprintf("%s: %s: %s", kind, c.Method, c.Message)
+}
+
+// CompareOpenRPC reports the wire-level changes from old to new, such as
+// removed methods, tightened params and changed types, so releases can be
+// gated on compatibility with existing clients.
+func CompareOpenRPC(old, new *OpenRPCDocument) (changes []Change) {
+	newMethods := make(map[string]*OpenRPCMethod)
+	for _, m := range new.Methods {
+		newMethods[m.Name] = m
+	}
+	oldMethods := make(map[string]*OpenRPCMethod)
+	for _, m := range old.Methods {
+		oldMethods[m.Name] = m
+		if n, ok := newMethods[m.Name]; ok {
+			changes = append(changes, compareMethods(m, n)...)
+		} else {
+			changes = append(changes, Change{m.Name, true, "method removed"})
+		}
+	}
+	for _, m := range new.Methods {
+		if _, ok := oldMethods[m.Name]; !ok {
+			changes = append(changes, Change{m.Name, false, "method added"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Method != changes[j].Method {
+			return changes[i].Method < changes[j].Method
+		}
+		return changes[i].Message < changes[j].Message
+	})
+	return
+}
+
+// HasBreaking reports whether any of changes is breaking.
+func HasBreaking(changes []Change) bool {
+	for _, c := range changes {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+func compareMethods(old, new *OpenRPCMethod) (changes []Change) {
+	add := func(breaking bool, format string, args ...interface{}) {
+		changes = append(changes, Change{old.Name, breaking, fmt.Sprintf(format, args...)})
+	}
+
+	newParams := make(map[string]*ContentDescriptor)
+	for _, p := range new.Params {
+		newParams[p.Name] = p
+	}
+	oldParams := make(map[string]*ContentDescriptor)
+	for _, p := range old.Params {
+		oldParams[p.Name] = p
+		n, ok := newParams[p.Name]
+		if !ok {
+			add(true, "param %q removed", p.Name)
+			continue
+		}
+		if n.Required && !p.Required {
+			add(true, "param %q became required", p.Name)
+		}
+		compareSchemas(add, "param "+quote(p.Name), p.Schema, n.Schema, true)
+	}
+	for _, p := range new.Params {
+		if _, ok := oldParams[p.Name]; !ok {
+			add(p.Required, "param %q added", p.Name)
+		}
+	}
+
+	if old.Result != nil && new.Result != nil {
+		compareSchemas(add, "result", old.Result.Schema, new.Result.Schema, false)
+	}
+	return
+}
+
+// compareSchemas reports the changes between two schemas at path. For
+// input schemas new required properties break clients; for output schemas
+// removed properties do.
+func compareSchemas(add func(bool, string, ...interface{}), path string, old, new *Schema, input bool) {
+	if old == nil || new == nil {
+		return
+	}
+	if old.Type != new.Type {
+		if old.Type != "" && new.Type != "" {
+			add(true, "%s changed type from %s to %s", path, old.Type, new.Type)
+		}
+		return
+	}
+	if old.Format != new.Format {
+		add(true, "%s changed format from %q to %q", path, old.Format, new.Format)
+	}
+	compareSchemas(add, path+"[]", old.Items, new.Items, input)
+	compareSchemas(add, path+"{}", old.AdditionalProperties, new.AdditionalProperties, input)
+
+	oldRequired, newRequired := stringSet(old.Required), stringSet(new.Required)
+	for name, o := range old.Properties {
+		n, ok := new.Properties[name]
+		if !ok {
+			add(!input, "%s.%s removed", path, name)
+			continue
+		}
+		if input && newRequired[name] && !oldRequired[name] {
+			add(true, "%s.%s became required", path, name)
+		}
+		compareSchemas(add, path+"."+name, o, n, input)
+	}
+	for name := range new.Properties {
+		if _, ok := old.Properties[name]; !ok {
+			add(input && newRequired[name], "%s.%s added", path, name)
+		}
+	}
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+func quote(s string) string {
+	return fmt.Sprintf("%q", s)
+}
@@ -0,0 +1,48 @@
+package jsonrpc
+
+import (
+	"context"
+	"testing"
+)
+
+type CompatArgsV1 struct {
+	Name string `json:"name" jsonrpc:"required"`
+}
+
+type CompatArgsOptional struct {
+	Name  string `json:"name" jsonrpc:"required"`
+	Limit int    `json:"limit"`
+}
+
+type CompatArgsRequired struct {
+	Name  string `json:"name" jsonrpc:"required"`
+	Limit int    `json:"limit" jsonrpc:"required"`
+}
+
+func TestCompareOpenRPCRequired(t *testing.T) {
+	docOf := func(handler interface{}) *OpenRPCDocument {
+		s := new(Server)
+		if err := s.Register("item.list", handler); err != nil {
+			t.Fatal(err)
+		}
+		return s.OpenRPC(OpenRPCInfo{Title: "test", Version: "1"})
+	}
+	v1 := docOf(func(ctx context.Context, args *CompatArgsV1) error { return nil })
+	tests := []struct {
+		name         string
+		next         *OpenRPCDocument
+		wantBreaking bool
+	}{
+		{"unchanged", v1, false},
+		{"untagged param added", docOf(func(ctx context.Context, args *CompatArgsOptional) error { return nil }), false},
+		{"required param added", docOf(func(ctx context.Context, args *CompatArgsRequired) error { return nil }), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := CompareOpenRPC(v1, tt.next)
+			if got := HasBreaking(changes); got != tt.wantBreaking {
+				t.Errorf("breaking = %v, want %v: %v", got, tt.wantBreaking, changes)
+			}
+		})
+	}
+}
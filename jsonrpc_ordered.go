@@ -0,0 +1,53 @@
+package jsonrpc
+
+import "encoding/json"
+
+// WithOrdered makes the calls of the method arriving over one session run
+// one at a time, in arrival order, for methods whose calls depend on the
+// state left by the previous ones. Ordered calls still run concurrently
+// with the other calls of the session and with those of other sessions.
+func WithOrdered() MethodOption {
+	return func(m *methodSpec) { m.ordered = true }
+}
+
+// SetOrdered makes all further calls of the session run one at a time, in
+// arrival order, e.g. for clients whose calls have implicit dependencies.
+// Calls made with WithOrdered methods are ordered with them.
+func (session *Session) SetOrdered(ordered bool) {
+	session.Lock()
+	session.orderAll = ordered
+	session.Unlock()
+}
+
+// Ordered reports whether all calls of the session run in arrival order.
+func (session *Session) Ordered() bool {
+	session.Lock()
+	defer session.Unlock()
+	return session.orderAll
+}
+
+// isOrdered reports whether a message is a request that must wait for the
+// ordered requests that arrived before it. Responses, which ordered calls
+// may be waiting for, never are.
+func (session *Session) isOrdered(raw json.RawMessage) bool {
+	if isResponse(raw) {
+		return false
+	}
+	if session.Ordered() {
+		return true
+	}
+	return session.server.isOrderedCall(raw)
+}
+
+// isOrderedCall reports whether a request calls a method registered
+// WithOrdered.
+func (s *Server) isOrderedCall(raw json.RawMessage) bool {
+	var probe struct {
+		Method string `json:"method"`
+	}
+	if json.Unmarshal(raw, &probe) != nil {
+		return false
+	}
+	spec, err := s.get(s.resolve(probe.Method))
+	return err == nil && spec.ordered
+}
@@ -0,0 +1,72 @@
+package jsonrpc
+
+import "context"
+
+// Pages iterates over the pages of a paginated listing, calling for each
+// page lazily:
+//
+//	pages := jsonrpc.Paginate(ctx,
+//		func(ctx context.Context, cursor string) (reply ListReply, err error) {
+//			err = client.Call(ctx, url, "download.list", ListArgs{Cursor: cursor}, &reply)
+//			return
+//		},
+//		func(reply ListReply) string { return reply.NextCursor })
+//	for pages.Next() {
+//		for _, item := range pages.Page().Items { ... }
+//	}
+//	if err := pages.Err(); err != nil { ... }
+type Pages[T any] struct {
+	ctx         context.Context
+	call        func(ctx context.Context, cursor string) (T, error)
+	extractNext func(page T) string
+	cursor      string
+	page        T
+	done        bool
+	err         error
+}
+
+// Paginate returns an iterator over pages. call fetches the page at
+// cursor, the empty cursor being the first page, and extractNext returns
+// the cursor of the page after it, or the empty string after the last page.
+// Offset based APIs can carry the offset in the cursor with strconv.
+func Paginate[T any](ctx context.Context, call func(ctx context.Context, cursor string) (T, error), extractNext func(page T) string) *Pages[T] {
+	return &Pages[T]{ctx: ctx, call: call, extractNext: extractNext}
+}
+
+// Next fetches the next page, reporting whether there was one.
+func (p *Pages[T]) Next() bool {
+	if p.done {
+		return false
+	}
+	if p.err = p.ctx.Err(); p.err != nil {
+		p.done = true
+		return false
+	}
+	page, err := p.call(p.ctx, p.cursor)
+	if err != nil {
+		p.err, p.done = err, true
+		return false
+	}
+	p.page = page
+	p.cursor = p.extractNext(page)
+	p.done = p.cursor == ""
+	return true
+}
+
+// Page returns the page fetched by the last call to Next.
+func (p *Pages[T]) Page() T {
+	return p.page
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (p *Pages[T]) Err() error {
+	return p.err
+}
+
+// All fetches all remaining pages.
+func (p *Pages[T]) All() (pages []T, err error) {
+	for p.Next() {
+		pages = append(pages, p.Page())
+	}
+	return pages, p.Err()
+}
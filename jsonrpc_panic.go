@@ -0,0 +1,81 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+)
+
+// PanicData is the error data of a recovered panic in debug mode.
+type PanicData struct {
+	Panic     string `json:"panic"`
+	Goroutine int64  `json:"goroutine"`
+	Stack     string `json:"stack"`
+}
+
+// panicError logs and reports a recovered panic with its stack and
+// converts it into an internal error, carrying the stack in its data if
+// the Server is in debug mode.
+func (s *Server) panicError(r *http.Request, method string, args, p interface{}) *Error {
+	goroutine, stack := trimStack(debug.Stack())
+	log.Printf("rpc: panic in %s (goroutine %d): %v\n%s", method, goroutine, p, stack)
+	s.report(&ErrorReport{
+		Method:    method,
+		Params:    snapshot(args),
+		Request:   r,
+		Err:       fmt.Errorf("panic: %v", p),
+		Panic:     p,
+		Goroutine: goroutine,
+		Stack:     stack,
+	})
+
+	err := &Error{
+		Code:    E_INTERNAL,
+		Message: "rpc: internal error",
+	}
+	if s.Debug {
+		err.Message = fmt.Sprintf("rpc: panic: %v", p)
+		err.Data = &PanicData{
+			Panic:     fmt.Sprint(p),
+			Goroutine: goroutine,
+			Stack:     string(stack),
+		}
+	}
+	return err
+}
+
+// trimStack extracts the goroutine id from a debug.Stack trace and keeps
+// only the frames between the panic and the reflective handler call.
+func trimStack(stack []byte) (goroutine int64, trimmed []byte) {
+	lines := bytes.Split(bytes.TrimSpace(stack), []byte("\n"))
+	if len(lines) == 0 {
+		return
+	}
+
+	// goroutine 42 [running]:
+	if fields := bytes.Fields(lines[0]); len(fields) > 1 {
+		goroutine, _ = strconv.ParseInt(string(fields[1]), 10, 64)
+	}
+	lines = lines[1:]
+
+	// Frames come in pairs of function and file lines. Skip everything up
+	// to the call to panic, and stop at the reflection machinery.
+	start, end := 0, len(lines)
+	for i := 0; i+1 < len(lines); i += 2 {
+		if bytes.HasPrefix(lines[i], []byte("panic(")) {
+			start = i + 2
+		}
+		if start != 0 && i >= start && bytes.HasPrefix(lines[i], []byte("reflect.Value.call(")) {
+			end = i
+			break
+		}
+	}
+	if start > end {
+		start = 0
+	}
+	trimmed = bytes.Join(lines[start:end], []byte("\n"))
+	return
+}
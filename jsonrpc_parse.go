@@ -0,0 +1,135 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ParseMode controls how strictly a Client checks responses.
+type ParseMode int
+
+const (
+	// ParseDefault decodes well-formed responses without checking them
+	// against the specification.
+	ParseDefault ParseMode = iota
+
+	// ParseStrict rejects responses that violate the specification: a
+	// jsonrpc member other than "2.0", a missing id, or not exactly one of
+	// result and error.
+	ParseStrict
+
+	// ParseLenient tolerates common server quirks: errors given as a
+	// string, codes given as strings, and null or missing results alongside
+	// errors and vice versa.
+	ParseLenient
+)
+
+// ErrInvalidResponse is wrapped by the errors of responses rejected in
+// ParseStrict mode.
+var ErrInvalidResponse = errors.New("rpc: invalid response")
+
+// rawResponse keeps members raw to tell missing members (nil) from null.
+type rawResponse struct {
+	Version *string         `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   json.RawMessage `json:"error"`
+}
+
+func isNull(raw json.RawMessage) bool {
+	return raw == nil || string(raw) == "null"
+}
+
+// decodeReply decodes a response according to mode.
+func decodeReply(r io.Reader, reply interface{}, mode ParseMode) (err error) {
+	var response rawResponse
+	if err = json.NewDecoder(r).Decode(&response); err != nil {
+		return
+	}
+	if mode == ParseStrict {
+		if err = response.validate(); err != nil {
+			return
+		}
+	}
+	if !isNull(response.Error) {
+		return decodeError(response.Error, mode)
+	}
+	if reply == nil {
+		return
+	}
+	if isNull(response.Result) {
+		return ErrNullResult
+	}
+	return json.Unmarshal(response.Result, reply)
+}
+
+// validate checks the response against the specification.
+func (response *rawResponse) validate() error {
+	switch {
+	case response.Version == nil || *response.Version != Version:
+		return fmt.Errorf("%w: jsonrpc member must be %q", ErrInvalidResponse, Version)
+	case response.ID == nil:
+		return fmt.Errorf("%w: missing id", ErrInvalidResponse)
+	case response.Result != nil && response.Error != nil:
+		return fmt.Errorf("%w: both result and error present", ErrInvalidResponse)
+	case response.Result == nil && response.Error == nil:
+		return fmt.Errorf("%w: neither result nor error present", ErrInvalidResponse)
+	case response.Error != nil && isNull(response.Error):
+		return fmt.Errorf("%w: error is null", ErrInvalidResponse)
+	}
+	return nil
+}
+
+// decodeError decodes a non-null error member.
+func decodeError(raw json.RawMessage, mode ParseMode) error {
+	if mode == ParseStrict {
+		var probe struct {
+			Code    *json.Number `json:"code"`
+			Message *string      `json:"message"`
+		}
+		if err := json.Unmarshal(raw, &probe); err != nil || probe.Code == nil || probe.Message == nil {
+			return fmt.Errorf("%w: error must have a code and a message", ErrInvalidResponse)
+		}
+	}
+	if mode != ParseLenient {
+		jsonErr := new(Error)
+		if err := json.Unmarshal(raw, jsonErr); err != nil {
+			return err
+		}
+		return jsonErr
+	}
+
+	var message string
+	if json.Unmarshal(raw, &message) == nil {
+		return &Error{Code: E_SERVER, Message: message}
+	}
+	var object struct {
+		Code    json.RawMessage `json:"code"`
+		Message interface{}     `json:"message"`
+		Data    interface{}     `json:"data"`
+	}
+	if json.Unmarshal(raw, &object) != nil {
+		return &Error{Code: E_SERVER, Message: string(raw)}
+	}
+	jsonErr := &Error{Code: E_SERVER, Data: object.Data}
+	var code string
+	if json.Unmarshal(object.Code, &code) != nil {
+		code = string(object.Code)
+	}
+	if n, err := strconv.ParseFloat(code, 64); err == nil {
+		jsonErr.Code = ErrorCode(n)
+	}
+	switch m := object.Message.(type) {
+	case string:
+		jsonErr.Message = m
+	case nil:
+		jsonErr.Message = string(raw)
+	default:
+		b, _ := json.Marshal(m)
+		jsonErr.Message = string(b)
+	}
+	return jsonErr
+}
@@ -0,0 +1,104 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// patternMethod is a handler registered for a pattern of method names.
+type patternMethod struct {
+	pattern string
+	spec    *methodSpec
+}
+
+// RegisterPattern adds a handler for the methods matching pattern, a
+// path.Match pattern such as "download.*", for methods whose names are
+// dynamic, e.g. per resource. The handler has a signature accepted by
+// Register and gets the called method name with MethodFromContext.
+// Registered method names take precedence over patterns, which are tried
+// in the order registered, before any MethodProvider. With
+// CaseInsensitiveMethods set, patterns match names ignoring case.
+func (s *Server) RegisterPattern(pattern string, handler interface{}, opts ...MethodOption) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("rpc: invalid method pattern %q: %v", pattern, err)
+	}
+	spec, err := newMethodSpec(handler)
+	if err != nil {
+		return err
+	}
+	spec.pattern = true
+	if err = spec.configure(opts); err != nil {
+		return err
+	}
+
+	s.Lock()
+	for _, pm := range s.patterns {
+		if pm.pattern == pattern {
+			s.Unlock()
+			return fmt.Errorf("rpc: method pattern already defined: %s", pattern)
+		}
+		if s.CaseInsensitiveMethods && strings.EqualFold(pm.pattern, pattern) {
+			s.Unlock()
+			return fmt.Errorf("rpc: method pattern %s conflicts with %s when resolved case-insensitively", pattern, pm.pattern)
+		}
+	}
+	s.patterns = append(s.patterns, patternMethod{pattern, spec})
+	s.publish()
+	s.Unlock()
+
+	if s.OnRegister != nil {
+		s.OnRegister(spec.info(pattern))
+	}
+	return nil
+}
+
+// unregisterPattern removes the handler registered for pattern. s must be
+// locked.
+func (s *Server) unregisterPattern(pattern string) bool {
+	for i, pm := range s.patterns {
+		if pm.pattern == pattern {
+			// Copy, as published snapshots share the slice.
+			s.patterns = append(cloneSlice(s.patterns[:i]), s.patterns[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the handler of the first pattern method matches, ignoring
+// case if fold is set.
+func (reg *registry) match(method string, fold bool) *methodSpec {
+	for _, pm := range reg.patterns {
+		if matchName(pm.pattern, method, fold) {
+			return pm.spec
+		}
+	}
+	return nil
+}
+
+// matchName reports whether the method name matches pattern, ignoring
+// case if fold is set.
+func matchName(pattern, name string, fold bool) bool {
+	if fold {
+		pattern, name = strings.ToLower(pattern), strings.ToLower(name)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+type methodKey struct{}
+
+// MethodFromContext returns the name of the method called, for handlers
+// registered with RegisterPattern.
+func MethodFromContext(ctx context.Context) string {
+	method, _ := ctx.Value(methodKey{}).(string)
+	return method
+}
+
+// withMethod adds the called method to the context of r.
+func withMethod(r *http.Request, method string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), methodKey{}, method))
+}
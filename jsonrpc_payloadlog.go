@@ -0,0 +1,193 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PayloadLogger records full request and response bodies for a sample of
+// calls, giving enough detail to debug rare failures without logging every
+// payload. Set it as Server.PayloadLogger.
+type PayloadLogger struct {
+	// SampleRate is the fraction of calls logged, from 0 to 1.
+	SampleRate float64
+
+	// Methods lists methods whose calls are always logged.
+	Methods []string
+
+	// RedactFields lists object member names, matched case-insensitively at
+	// any depth, whose values are replaced before logging.
+	RedactFields []string
+
+	// Log receives each sampled entry. Defaults to the standard logger.
+	Log func(entry *PayloadLogEntry)
+}
+
+// PayloadLogEntry is one logged call.
+type PayloadLogEntry struct {
+	Method   string
+	Request  []byte
+	Response []byte
+	Status   int
+	Duration time.Duration
+}
+
+const redacted = "[REDACTED]"
+
+// sampled reports whether a call of method should be logged.
+func (l *PayloadLogger) sampled(method string) bool {
+	for _, m := range l.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return l.SampleRate > 0 && rand.Float64() < l.SampleRate
+}
+
+// redact masks the configured fields of a JSON body. Bodies that are not
+// JSON are returned as they are.
+func (l *PayloadLogger) redact(body []byte) []byte {
+	if len(l.RedactFields) == 0 {
+		return body
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	out, err := json.Marshal(redactValue(v, l.RedactFields))
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// redactValue masks the members of a decoded JSON value named in fields.
+func redactValue(v interface{}, fields []string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = redactValue(value, fields)
+			for _, field := range fields {
+				if strings.EqualFold(key, field) {
+					v[key] = redacted
+				}
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redactValue(value, fields)
+		}
+	}
+	return v
+}
+
+// payloadLogWriter captures the request and response bodies of one call.
+type payloadLogWriter struct {
+	http.ResponseWriter
+	logger   *PayloadLogger
+	method   string
+	request  bytes.Buffer
+	response bytes.Buffer
+	status   int
+	start    time.Time
+}
+
+// begin starts capturing a call if it is sampled, returning nil otherwise,
+// so that the bodies of calls not logged are never buffered. The method is
+// peeked from the start of the body; calls whose method does not appear
+// there are only logged by SampleRate.
+func (l *PayloadLogger) begin(w http.ResponseWriter, r *http.Request) *payloadLogWriter {
+	br := bufio.NewReaderSize(r.Body, peekSize)
+	body := struct {
+		io.Reader
+		io.Closer
+	}{br, r.Body}
+	defer func() { r.Body = body }()
+
+	method := ""
+	if len(l.Methods) != 0 {
+		peeked, _ := br.Peek(peekSize)
+		method = peekMethod(peeked)
+	}
+	if !l.sampled(method) {
+		return nil
+	}
+	pw := &payloadLogWriter{ResponseWriter: w, logger: l, method: method, status: http.StatusOK, start: time.Now()}
+	body.Reader = io.TeeReader(br, &pw.request)
+	return pw
+}
+
+// peekSize is how much of a body is searched for the method.
+const peekSize = 4096
+
+// peekMethod returns the method of the request starting with peeked, or ""
+// if it is not found there.
+func peekMethod(peeked []byte) string {
+	decoder := json.NewDecoder(bytes.NewReader(peeked))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return ""
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if key == "method" {
+			var method string
+			decoder.Decode(&method)
+			return method
+		}
+		var skipped json.RawMessage
+		if decoder.Decode(&skipped) != nil {
+			return ""
+		}
+	}
+	return ""
+}
+
+func (w *payloadLogWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *payloadLogWriter) Write(b []byte) (int, error) {
+	w.response.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *payloadLogWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// finish logs the sampled call.
+func (w *payloadLogWriter) finish() {
+	if w.method == "" {
+		var probe struct {
+			Method string `json:"method"`
+		}
+		json.Unmarshal(w.request.Bytes(), &probe)
+		w.method = probe.Method
+	}
+	entry := &PayloadLogEntry{
+		Method:   w.method,
+		Request:  w.logger.redact(w.request.Bytes()),
+		Response: w.logger.redact(w.response.Bytes()),
+		Status:   w.status,
+		Duration: time.Since(w.start),
+	}
+	if w.logger.Log != nil {
+		w.logger.Log(entry)
+		return
+	}
+	log.Printf("rpc: %s status=%d duration=%s request=%s response=%s",
+		entry.Method, entry.Status, entry.Duration, entry.Request, bytes.TrimSpace(entry.Response))
+}
@@ -0,0 +1,152 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Peer runs both JSON-RPC roles over one connection, as LSP-like and
+// agent/controller protocols do: requests arriving from the remote end are
+// dispatched to a Server, while Call and Notify send requests the other
+// way. Messages are framed as in ServeConn.
+type Peer struct {
+	sync.Mutex
+	server  *Server
+	session *Session
+	nextID  uint64
+	pending map[string]chan json.RawMessage
+	closed  bool
+
+	streams     map[string]*eventQueue
+	subscribing int // Subscribe calls awaiting their subscription id
+	early       map[string][]json.RawMessage
+}
+
+// NewPeer returns a Peer serving the methods of server over conn. server
+// may be nil for a peer that only makes calls. Run Serve to start reading
+// from the connection.
+func NewPeer(conn io.ReadWriteCloser, server *Server) *Peer {
+	if server == nil {
+		server = &Server{}
+	}
+	return &Peer{
+		server:  server,
+		session: server.openSession(conn),
+		pending: make(map[string]chan json.RawMessage),
+	}
+}
+
+// Session returns the session shared by the calls the remote end makes.
+func (p *Peer) Session() *Session {
+	return p.session
+}
+
+// Serve reads and handles messages until the connection is closed, then
+// fails the calls still waiting for a response with ErrSessionClosed.
+func (p *Peer) Serve() {
+	dispatcher := p.server.Dispatcher()
+	p.session.serve(p.routeEvent, func(ctx context.Context, raw json.RawMessage) {
+		if !isResponse(raw) {
+			if resp := dispatcher.Handle(ctx, raw); len(resp) != 0 {
+				p.session.write(resp)
+			}
+			return
+		}
+		var probe struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if json.Unmarshal(raw, &probe) != nil {
+			return
+		}
+		p.Lock()
+		ch, ok := p.pending[string(probe.ID)]
+		delete(p.pending, string(probe.ID))
+		p.Unlock()
+		if ok {
+			ch <- raw
+		}
+	})
+
+	p.Lock()
+	p.closed = true
+	for id, ch := range p.pending {
+		close(ch)
+		delete(p.pending, id)
+	}
+	for id, q := range p.streams {
+		close(q.ended)
+		delete(p.streams, id)
+	}
+	p.Unlock()
+	p.server.closeSession(p.session)
+}
+
+// isResponse reports whether a message is a response rather than a
+// request, notification or batch.
+func isResponse(raw json.RawMessage) bool {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '{' {
+		return false
+	}
+	var probe struct {
+		Method *string `json:"method"`
+	}
+	return json.Unmarshal(raw, &probe) == nil && probe.Method == nil
+}
+
+// Call calls method on the remote end and waits for its response.
+func (p *Peer) Call(ctx context.Context, method string, params, reply interface{}) (err error) {
+	p.Lock()
+	if p.closed {
+		p.Unlock()
+		return ErrSessionClosed
+	}
+	p.nextID++
+	id := strconv.FormatUint(p.nextID, 10)
+	ch := make(chan json.RawMessage, 1)
+	p.pending[id] = ch
+	p.Unlock()
+
+	defer func() {
+		p.Lock()
+		delete(p.pending, id)
+		p.Unlock()
+	}()
+
+	var body []byte
+	if body, err = EncodeCall(json.RawMessage(id), method, params); err != nil {
+		return
+	}
+	if err = p.session.write(body); err != nil {
+		return
+	}
+
+	select {
+	case raw, ok := <-ch:
+		if !ok {
+			return ErrSessionClosed
+		}
+		return DecodeReply(bytes.NewReader(raw), reply)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Notify sends a notification to the remote end.
+func (p *Peer) Notify(method string, params interface{}) error {
+	return p.session.Notify(method, params)
+}
+
+// Close closes the connection.
+func (p *Peer) Close() error {
+	return p.session.Close()
+}
+
+// Done is closed once the connection has ended.
+func (p *Peer) Done() <-chan struct{} {
+	return p.session.Done()
+}
@@ -0,0 +1,139 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// newPeers returns two peers connected to each other, serving a and b.
+func newPeers(t *testing.T, a, b *Server) (peerA, peerB *Peer) {
+	connA, connB := net.Pipe()
+	peerA, peerB = NewPeer(connA, a), NewPeer(connB, b)
+	go peerA.Serve()
+	go peerB.Serve()
+	t.Cleanup(func() {
+		peerA.Close()
+		peerB.Close()
+	})
+	return
+}
+
+func TestPeer(t *testing.T) {
+	a, b := new(Server), new(Server)
+	notes := make(chan int, 1)
+	var peerA, peerB *Peer
+	if err := a.Register("a.double", func(ctx context.Context, args *CacheArgs) (int, error) {
+		return 2 * args.N, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// b.quadruple calls back into a while a's call is pending.
+	if err := b.Register("b.quadruple", func(ctx context.Context, args *CacheArgs) (n int, err error) {
+		err = peerB.Call(ctx, "a.double", args, &n)
+		return 2 * n, err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Register("b.note", func(ctx context.Context, args *CacheArgs) error {
+		notes <- args.N
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	peerA, peerB = newPeers(t, a, b)
+
+	tests := []struct {
+		name     string
+		peer     *Peer
+		method   string
+		n        int
+		want     int
+		wantCode ErrorCode
+	}{
+		{"a calls b", peerA, "b.quadruple", 3, 12, 0},
+		{"b calls a", peerB, "a.double", 3, 6, 0},
+		{"unknown method", peerA, "a.double", 3, 0, E_NO_METHOD},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reply int
+			err := tt.peer.Call(context.Background(), tt.method, &CacheArgs{N: tt.n}, &reply)
+			var rpcErr *Error
+			switch {
+			case tt.wantCode != 0:
+				if !errors.As(err, &rpcErr) || rpcErr.Code != tt.wantCode {
+					t.Errorf("err = %v, want code %d", err, tt.wantCode)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case reply != tt.want:
+				t.Errorf("reply = %d, want %d", reply, tt.want)
+			}
+		})
+	}
+
+	if err := peerA.Notify("b.note", &CacheArgs{N: 5}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case n := <-notes:
+		if n != 5 {
+			t.Errorf("notified %d, want 5", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification was not handled")
+	}
+}
+
+func TestPeerClose(t *testing.T) {
+	b := new(Server)
+	started := make(chan struct{})
+	if err := b.Register("b.block", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	peerA, peerB := newPeers(t, nil, b)
+
+	errs := make(chan error, 1)
+	go func() { errs <- peerA.Call(context.Background(), "b.block", nil, nil) }()
+	<-started
+	peerB.Close()
+	select {
+	case err := <-errs:
+		if err != ErrSessionClosed {
+			t.Errorf("pending call = %v, want ErrSessionClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending call did not fail")
+	}
+	<-peerA.Done()
+	if err := peerA.Call(context.Background(), "b.block", nil, nil); err != ErrSessionClosed {
+		t.Errorf("call after close = %v, want ErrSessionClosed", err)
+	}
+}
+
+func TestIsResponse(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{`{"jsonrpc":"2.0","id":1,"result":1}`, true},
+		{` {"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"x"}}`, true},
+		{`{"jsonrpc":"2.0","id":1,"method":"m"}`, false},
+		{`{"jsonrpc":"2.0","method":"m"}`, false},
+		{`[{"jsonrpc":"2.0","id":1,"result":1}]`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := isResponse(json.RawMessage(tt.raw)); got != tt.want {
+			t.Errorf("isResponse(%s) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotConnected       = errors.New("rpc: not connected")
+	ErrReconnectQueueFull = errors.New("rpc: too many calls waiting for reconnection")
+	ErrClientClosed       = errors.New("rpc: client closed")
+)
+
+// PersistentClient makes calls over a long-lived connection, as a Peer,
+// and redials whenever the connection is lost. While it is reconnecting,
+// calls either fail with ErrNotConnected or, with QueueSize set, wait for
+// the new connection, smoothing over brief server restarts.
+type PersistentClient struct {
+	sync.Mutex
+
+	// Dial opens the connection.
+	Dial func(ctx context.Context) (io.ReadWriteCloser, error)
+
+	// Server, if set, serves the calls the remote end makes.
+	Server *Server
+
+	// Backoff is the delay before redialing after a failed attempt. It
+	// doubles with every further failure up to MaxBackoff. Default to 100ms
+	// and 10s.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// MinUptime is how long a connection must stay up to reset the
+	// backoff. Connections lost sooner, as to a server that accepts and
+	// drops them, count as failed attempts. Defaults to 5s.
+	MinUptime time.Duration
+
+	// QueueSize is the number of calls that may wait for a connection at
+	// once. Zero makes calls fail while disconnected.
+	QueueSize int
+
+	// QueueTimeout, if positive, bounds how long a call waits for a
+	// connection before failing with ErrNotConnected.
+	QueueTimeout time.Duration
+
+	// Clock times the redials and QueueTimeout. Defaults to SystemClock.
+	Clock Clock
+
+	// Handshake, if set, is offered in a handshake on every new connection
+	// before calls are made over it. The agreed capabilities are in the
+	// Session of the connection's Peer.
+	Handshake *Capabilities
+
+	once    sync.Once
+	ctx     context.Context
+	cancel  context.CancelFunc
+	peer    *Peer
+	waiters []chan struct{}
+}
+
+func (c *PersistentClient) start() {
+	c.once.Do(func() {
+		c.ctx, c.cancel = context.WithCancel(context.Background())
+		go c.run()
+	})
+}
+
+// run keeps the connection up until the client is closed.
+func (c *PersistentClient) run() {
+	policy := &RetryPolicy{Backoff: c.Backoff, MaxBackoff: c.MaxBackoff}
+	if policy.Backoff <= 0 {
+		policy.Backoff = 100 * time.Millisecond
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = 10 * time.Second
+	}
+	minUptime := c.MinUptime
+	if minUptime <= 0 {
+		minUptime = 5 * time.Second
+	}
+	clock := clockOr(c.Clock)
+	failures := 0
+	for c.ctx.Err() == nil {
+		conn, err := c.Dial(c.ctx)
+		if err == nil {
+			up := clock.Now()
+			peer := NewPeer(conn, c.Server)
+			if c.Handshake != nil {
+				go c.handshake(peer)
+			} else {
+				c.connect(peer)
+			}
+			peer.Serve()
+
+			c.Lock()
+			c.peer = nil
+			c.Unlock()
+			if since(clock, up) >= minUptime {
+				failures = 0
+				continue
+			}
+		}
+		failures++
+		wait(clock, policy.backoff(failures), c.ctx.Done())
+	}
+}
+
+// connect makes peer the connection calls are made over.
+func (c *PersistentClient) connect(peer *Peer) {
+	c.Lock()
+	select {
+	case <-peer.Done():
+		// Lost during the handshake.
+		c.Unlock()
+		return
+	default:
+	}
+	c.peer = peer
+	waiters := c.waiters
+	c.waiters = nil
+	c.Unlock()
+	// Release waiting calls in the order they were made.
+	for _, w := range waiters {
+		close(w)
+	}
+	if c.ctx.Err() != nil {
+		peer.Close()
+	}
+}
+
+// Connect waits until the client is connected. Calls made before the first
+// connection are otherwise subject to QueueSize like calls made while
+// reconnecting.
+func (c *PersistentClient) Connect(ctx context.Context) (err error) {
+	_, err = c.connected(ctx, true)
+	return
+}
+
+// connected returns the current peer, waiting for one if the queue allows.
+func (c *PersistentClient) connected(ctx context.Context, force bool) (*Peer, error) {
+	c.start()
+
+	var timeout <-chan time.Time
+	if c.QueueTimeout > 0 {
+		timer := clockOr(c.Clock).NewTimer(c.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+
+	for {
+		c.Lock()
+		if c.ctx.Err() != nil {
+			c.Unlock()
+			return nil, ErrClientClosed
+		}
+		if c.peer != nil {
+			peer := c.peer
+			c.Unlock()
+			return peer, nil
+		}
+		if !force && len(c.waiters) >= c.QueueSize {
+			c.Unlock()
+			if c.QueueSize == 0 {
+				return nil, ErrNotConnected
+			}
+			return nil, ErrReconnectQueueFull
+		}
+		w := make(chan struct{})
+		c.waiters = append(c.waiters, w)
+		c.Unlock()
+
+		select {
+		case <-w:
+			continue
+		case <-ctx.Done():
+			c.removeWaiter(w)
+			return nil, ctx.Err()
+		case <-timeout:
+			c.removeWaiter(w)
+			return nil, ErrNotConnected
+		case <-c.ctx.Done():
+			return nil, ErrClientClosed
+		}
+	}
+}
+
+func (c *PersistentClient) removeWaiter(w chan struct{}) {
+	c.Lock()
+	defer c.Unlock()
+	for i, waiter := range c.waiters {
+		if waiter == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Call calls method on the remote end. Calls interrupted by a lost
+// connection fail with ErrSessionClosed and are not repeated.
+func (c *PersistentClient) Call(ctx context.Context, method string, params, reply interface{}) error {
+	peer, err := c.connected(ctx, false)
+	if err != nil {
+		return err
+	}
+	return peer.Call(ctx, method, params, reply)
+}
+
+// Notify sends a notification to the remote end.
+func (c *PersistentClient) Notify(ctx context.Context, method string, params interface{}) error {
+	peer, err := c.connected(ctx, false)
+	if err != nil {
+		return err
+	}
+	return peer.Notify(method, params)
+}
+
+// Close closes the connection and stops reconnecting.
+func (c *PersistentClient) Close() error {
+	c.start()
+	c.cancel()
+	c.Lock()
+	peer := c.peer
+	c.Unlock()
+	if peer != nil {
+		return peer.Close()
+	}
+	return nil
+}
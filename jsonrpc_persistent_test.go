@@ -0,0 +1,64 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-webdl/jsonrpc"
+	"github.com/go-webdl/jsonrpc/jsonrpctest"
+)
+
+func TestPersistentClientBackoff(t *testing.T) {
+	tests := []struct {
+		name        string
+		uptime      time.Duration // before the server drops the connection
+		wantBackoff bool
+	}{
+		{"dropped at once", 0, true},
+		{"dropped early", 30 * time.Second, true},
+		{"stable", time.Minute, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := jsonrpctest.NewFakeClock(time.Unix(1000, 0))
+			dials := make(chan net.Conn, 4)
+			c := &jsonrpc.PersistentClient{
+				Dial: func(ctx context.Context) (io.ReadWriteCloser, error) {
+					conn, remote := net.Pipe()
+					dials <- remote
+					return conn, nil
+				},
+				Backoff:    time.Second,
+				MaxBackoff: time.Second,
+				MinUptime:  time.Minute,
+				Clock:      clock,
+			}
+			defer c.Close()
+			if err := c.Connect(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			remote := <-dials
+			clock.Advance(tt.uptime)
+			remote.Close()
+
+			if tt.wantBackoff {
+				clock.BlockUntil(1)
+				select {
+				case <-dials:
+					t.Fatal("redialed without backing off")
+				default:
+				}
+				clock.Advance(time.Second)
+			}
+			select {
+			case remote = <-dials:
+				remote.Close()
+			case <-time.After(5 * time.Second):
+				t.Fatal("did not redial")
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// ParamStructure restricts how a method's params may be given.
+type ParamStructure int
+
+const (
+	// ParamsEither accepts params by name, as an object, and by
+	// position, as an array decoded into struct fields in order.
+	ParamsEither ParamStructure = iota
+
+	// ParamsByName accepts params given as an object only.
+	ParamsByName
+
+	// ParamsByPosition accepts params given as an array only.
+	ParamsByPosition
+)
+
+// String returns the OpenRPC name of the structure.
+func (p ParamStructure) String() string {
+	switch p {
+	case ParamsByName:
+		return "by-name"
+	case ParamsByPosition:
+		return "by-position"
+	}
+	return "either"
+}
+
+// WithParamStructure restricts the params of the method to the given
+// structure. Methods accept either structure by default.
+func WithParamStructure(structure ParamStructure) MethodOption {
+	return func(m *methodSpec) { m.paramStructure = structure }
+}
+
+// checkParams rejects params given in a structure the method does not
+// accept. Missing params are always accepted.
+func (m *methodSpec) checkParams(params *json.RawMessage) error {
+	if m.paramStructure == ParamsEither || params == nil || isNull(*params) {
+		return nil
+	}
+	if isArray(*params) != (m.paramStructure == ParamsByPosition) {
+		return &Error{
+			Code:    E_BAD_PARAMS,
+			Message: "rpc: params must be given " + m.paramStructure.String(),
+		}
+	}
+	return nil
+}
+
+// decodePositional decodes by-position params into the fields of the
+// struct args points to, in declaration order as encoding/json sees them.
+// Trailing fields without a param keep their zero value.
+func decodePositional(params json.RawMessage, args interface{}) error {
+	v := reflect.ValueOf(args)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("rpc: cannot decode params array into %T", args)
+	}
+	v = v.Elem()
+
+	var elems []json.RawMessage
+	if err := json.Unmarshal(params, &elems); err != nil {
+		return err
+	}
+	fields := jsonFields(v.Type())
+	if len(elems) > len(fields) {
+		return fmt.Errorf("rpc: too many params: got %d, want at most %d", len(elems), len(fields))
+	}
+	for i, elem := range elems {
+		field := fieldByIndex(v, fields[i].index)
+		if err := json.Unmarshal(elem, field.Addr().Interface()); err != nil {
+			return fmt.Errorf("rpc: param %d (%s): %w", i, fields[i].name, err)
+		}
+	}
+	return nil
+}
+
+// arrayLen returns the number of elements of the JSON array raw, or -1 if
+// raw is not an array.
+func arrayLen(raw json.RawMessage) int {
+	var elems []json.RawMessage
+	if json.Unmarshal(raw, &elems) != nil {
+		return -1
+	}
+	return len(elems)
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex but allocates nil
+// embedded struct pointers on the way.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// isArray reports whether raw encodes a JSON array.
+func isArray(raw json.RawMessage) bool {
+	return firstByte(raw) == '['
+}
+
+// isObject reports whether raw encodes a JSON object.
+func isObject(raw json.RawMessage) bool {
+	return firstByte(raw) == '{'
+}
+
+// firstByte returns the first byte of raw that is not whitespace.
+func firstByte(raw json.RawMessage) byte {
+	for _, b := range raw {
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+type PositionalArgs struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+type PositionalAddArgs struct {
+	Options map[string]string `json:"options"`
+	URI     string            `json:"uri"`
+}
+
+func TestPositionalParams(t *testing.T) {
+	s := new(Server)
+	if err := s.Register("add", func(r *http.Request, args *PositionalArgs, reply *PositionalArgs) error {
+		*reply = *args
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register("addUri", func(r *http.Request, args *PositionalAddArgs, reply *PositionalAddArgs) error {
+		*reply = *args
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		method     string
+		params     string
+		wantResult string
+		wantCode   ErrorCode
+	}{
+		{"by name", "add", `{"a":1,"b":2}`, `{"a":1,"b":2}`, 0},
+		{"scalars", "add", `[3,4]`, `{"a":3,"b":4}`, 0},
+		{"single struct", "add", `[{"a":1,"b":2}]`, `{"a":1,"b":2}`, 0},
+		{"null first", "add", `[null,7]`, `{"a":0,"b":7}`, 0},
+		{"object first", "addUri", `[{"dir":"x"},"uri"]`, `{"options":{"dir":"x"},"uri":"uri"}`, 0},
+		{"too few", "add", `[3]`, `{"a":3,"b":0}`, 0},
+		{"too many", "add", `[1,2,3]`, ``, E_BAD_PARAMS},
+		{"ill-typed", "add", `["x",2]`, ``, E_BAD_PARAMS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.Dispatcher().HandleRequest(context.Background(), &Request{
+				Version: Version,
+				Method:  tt.method,
+				Params:  json.RawMessage(tt.params),
+				ID:      json.RawMessage("1"),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantCode != 0 {
+				if resp.Error == nil || resp.Error.Code != tt.wantCode {
+					t.Errorf("error = %v, want code %d", resp.Error, tt.wantCode)
+				}
+				return
+			}
+			if resp.Error != nil {
+				t.Fatal(resp.Error)
+			}
+			if string(resp.Result) != tt.wantResult {
+				t.Errorf("result = %s, want %s", resp.Result, tt.wantResult)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// ProgressMethod is the method of the notifications carrying the progress
+// of a call served over a session or with partial results. Their params
+// are ProgressParams.
+const ProgressMethod = "rpc.progress"
+
+// ProgressParams are the params of a ProgressMethod notification.
+type ProgressParams struct {
+	ID       *json.RawMessage `json:"id"`
+	Progress interface{}      `json:"progress"`
+}
+
+// ProgressReporter receives progress updates emitted by long-running handlers.
+type ProgressReporter interface {
+	Report(value interface{})
+}
+
+// ProgressFunc adapts an ordinary function to the ProgressReporter interface.
+type ProgressFunc func(value interface{})
+
+func (f ProgressFunc) Report(value interface{}) {
+	f(value)
+}
+
+type progressKey struct{}
+
+// WithProgress returns a copy of ctx in which Progress reports to reporter.
+func WithProgress(ctx context.Context, reporter ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressKey{}, reporter)
+}
+
+// Progress returns the ProgressReporter attached to ctx.
+//
+// Calls served over a session, as by ServeConn, or with partial results
+// report with ProgressMethod notifications; jobs keep the last update for
+// job.status. If the transport serving the call does not support progress,
+// the returned reporter discards every update, so handlers may report
+// unconditionally.
+func Progress(ctx context.Context) ProgressReporter {
+	if reporter, ok := ctx.Value(progressKey{}).(ProgressReporter); ok {
+		return reporter
+	}
+	return discardProgress{}
+}
+
+type discardProgress struct{}
+
+// hasProgress reports whether a reporter is attached to ctx.
+func hasProgress(ctx context.Context) bool {
+	_, ok := ctx.Value(progressKey{}).(ProgressReporter)
+	return ok
+}
+
+// sessionProgress notifies the peer of a session of the progress of the
+// call with the given id.
+type sessionProgress struct {
+	session *Session
+	id      *json.RawMessage
+}
+
+func (p sessionProgress) Report(value interface{}) {
+	p.session.Notify(ProgressMethod, &ProgressParams{ID: p.id, Progress: value})
+}
+
+func (discardProgress) Report(value interface{}) {}
+
+// ProgressRecorder is a ProgressReporter that keeps the most recent update so
+// it can be polled later, e.g. by a job status method.
+type ProgressRecorder struct {
+	sync.Mutex
+	value    interface{}
+	reported bool
+}
+
+func (p *ProgressRecorder) Report(value interface{}) {
+	p.Lock()
+	p.value = value
+	p.reported = true
+	p.Unlock()
+}
+
+// Last returns the most recent update and whether any update was reported.
+func (p *ProgressRecorder) Last() (value interface{}, ok bool) {
+	p.Lock()
+	value, ok = p.value, p.reported
+	p.Unlock()
+	return
+}
@@ -0,0 +1,115 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func newProgressServer(t *testing.T) *Server {
+	s := new(Server)
+	if err := s.Register("item.fetch", func(ctx context.Context, args *CacheArgs, reply *int) error {
+		for i := 1; i <= args.N; i++ {
+			Progress(ctx).Report(i * 100 / args.N)
+		}
+		*reply = args.N
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestProgressOverSession(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	go newProgressServer(t).ServeConn(conn)
+
+	tests := []struct {
+		name         string
+		request      string
+		wantProgress []string
+		wantResponse bool
+	}{
+		{"call", `{"jsonrpc":"2.0","id":"a","method":"item.fetch","params":{"n":2}}`, []string{"50", "100"}, true},
+		{"notification", `{"jsonrpc":"2.0","method":"item.fetch","params":{"n":2}}`, nil, false},
+	}
+	decoder := json.NewDecoder(peer)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := peer.Write([]byte(tt.request + "\n")); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.wantResponse {
+				// Follow up with a call to tell the notification produced
+				// no messages.
+				peer.Write([]byte(`{"jsonrpc":"2.0","id":"b","method":"item.fetch","params":{"n":0}}` + "\n"))
+			}
+			var progress []string
+			for {
+				var m struct {
+					Method string          `json:"method"`
+					Params ProgressParams  `json:"params"`
+					ID     json.RawMessage `json:"id"`
+				}
+				if err := decoder.Decode(&m); err != nil {
+					t.Fatal(err)
+				}
+				if m.Method != ProgressMethod {
+					if tt.wantResponse && string(m.ID) != `"a"` || !tt.wantResponse && string(m.ID) != `"b"` {
+						t.Errorf("response id = %s", m.ID)
+					}
+					break
+				}
+				if string(*m.Params.ID) != `"a"` {
+					t.Errorf("progress of id %s, want \"a\"", *m.Params.ID)
+				}
+				raw, _ := json.Marshal(m.Params.Progress)
+				progress = append(progress, string(raw))
+			}
+			if len(progress) != len(tt.wantProgress) {
+				t.Fatalf("progress = %v, want %v", progress, tt.wantProgress)
+			}
+			for i := range progress {
+				if progress[i] != tt.wantProgress[i] {
+					t.Errorf("progress = %v, want %v", progress, tt.wantProgress)
+				}
+			}
+		})
+	}
+}
+
+func TestProgressWithPartialResults(t *testing.T) {
+	ts := httptest.NewServer(newProgressServer(t))
+	defer ts.Close()
+	client := new(Client)
+
+	tests := []struct {
+		name         string
+		n            int
+		wantProgress int
+		wantErr      bool
+	}{
+		{"reported", 4, 100, false},
+		{"none", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := client.Stream(context.Background(), ts.URL, "item.fetch", &CacheArgs{N: tt.n})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+			var reply int
+			if err = stream.Result(&reply); err != nil || reply != tt.n {
+				t.Fatalf("result = %d, %v, want %d", reply, err, tt.n)
+			}
+			var progress int
+			if err = stream.Progress(&progress); (err != nil) != tt.wantErr || progress != tt.wantProgress {
+				t.Errorf("progress = %d, %v, want %d", progress, err, tt.wantProgress)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package jsonrpc
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MethodProvider resolves the handlers of methods on demand, for servers
+// exposing more methods than can be registered up front.
+type MethodProvider interface {
+	// Method returns the handler of the method name, relative to the
+	// prefix the provider was registered with. The handler has one of the
+	// signatures accepted by Register, or is an Adapter.
+	Method(name string) (handler interface{}, ok bool)
+}
+
+// MethodProviderFunc is a MethodProvider function.
+type MethodProviderFunc func(name string) (handler interface{}, ok bool)
+
+func (f MethodProviderFunc) Method(name string) (interface{}, bool) {
+	return f(name)
+}
+
+// provider is a MethodProvider registered for a prefix.
+type provider struct {
+	sync.Mutex
+	prefix   string
+	provider MethodProvider
+	opts     []MethodOption
+
+	// specs holds the configured specs by handler signature, so options
+	// and defaults are applied once per signature.
+	specs map[specKey]*methodSpec
+}
+
+type specKey struct {
+	handler, args, reply reflect.Type
+}
+
+// RegisterProvider resolves methods starting with prefix that are not
+// registered with p. Resolved methods take the options, which are applied
+// once for all methods with the same handler signature. The longest
+// matching prefix wins. Methods of providers are not listed by Methods.
+func (s *Server) RegisterProvider(prefix string, p MethodProvider, opts ...MethodOption) error {
+	s.Lock()
+	defer s.Unlock()
+	for _, other := range s.providers {
+		if other.prefix == prefix {
+			return fmt.Errorf("rpc: provider already registered for %q", prefix)
+		}
+	}
+	// Sort a copy, as published snapshots share the slice.
+	providers := append(cloneSlice(s.providers), &provider{prefix: prefix, provider: p, opts: opts})
+	sort.SliceStable(providers, func(i, j int) bool {
+		return len(providers[i].prefix) > len(providers[j].prefix)
+	})
+	s.providers = providers
+	s.publish()
+	return nil
+}
+
+// provide resolves method with the providers of reg.
+func (reg *registry) provide(method string) (spec *methodSpec, err error) {
+	for _, p := range reg.providers {
+		if strings.HasPrefix(method, p.prefix) {
+			handler, ok := p.provider.Method(method[len(p.prefix):])
+			if !ok {
+				break
+			}
+			if spec, err = p.spec(handler); err != nil {
+				err = fmt.Errorf("rpc: provider of %q: %v", method, err)
+			}
+			return
+		}
+	}
+	return nil, errMethodNotFound(method)
+}
+
+// spec returns the spec of a handler returned by the provider.
+func (p *provider) spec(handler interface{}) (spec *methodSpec, err error) {
+	adapter, isAdapter := handler.(Adapter)
+	if !isAdapter {
+		if spec, err = newMethodSpec(handler); err != nil {
+			return
+		}
+	} else {
+		spec = &methodSpec{
+			argsType:  reflect.TypeOf(adapter.NewArgs()).Elem(),
+			replyType: reflect.TypeOf(adapter.NewReply()).Elem(),
+		}
+	}
+	key := specKey{reflect.TypeOf(handler), spec.argsType, spec.replyType}
+
+	p.Lock()
+	defer p.Unlock()
+	template := p.specs[key]
+	if template == nil {
+		if err = spec.configure(p.opts); err != nil {
+			return nil, err
+		}
+		if p.specs == nil {
+			p.specs = make(map[specKey]*methodSpec)
+		}
+		template = spec
+		p.specs[key] = template
+	}
+
+	resolved := *template
+	resolved.method, resolved.adapter = spec.method, adapter
+	return &resolved, nil
+}
@@ -0,0 +1,280 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// QueueEntry is a request accepted into a RequestQueue.
+type QueueEntry struct {
+	Seq     uint64          `json:"seq"`
+	Request json.RawMessage `json:"request,omitempty"`
+
+	// Header holds the request headers named by Server.QueuedHeaders.
+	Header http.Header `json:"header,omitempty"`
+}
+
+// QueueReceipt is the reply to a call accepted into the request queue.
+type QueueReceipt struct {
+	Seq uint64 `json:"queued"`
+}
+
+// RequestQueue durably stores accepted requests until they are processed.
+type RequestQueue interface {
+	// Append stores an encoded request and its headers, returning once it
+	// is durable.
+	Append(request []byte, header http.Header) (seq uint64, err error)
+	// Ack marks a request as processed.
+	Ack(seq uint64) error
+	// Pending returns the requests not acknowledged yet, oldest first.
+	Pending() ([]QueueEntry, error)
+}
+
+// WithDurableQueue makes calls of the method go through the Server's
+// RequestQueue: once its middleware accepted the call, e.g. authenticated
+// it, the request is persisted with the headers named by QueuedHeaders
+// before the call is acknowledged with a QueueReceipt, and processed in the
+// background afterwards, through the middleware again. Use it for
+// notification-style mutations that must survive a crash; requests still
+// pending on restart are processed again by ReplayQueue, so handlers must
+// tolerate being run more than once.
+func WithDurableQueue() MethodOption {
+	return func(m *methodSpec) { m.durable = true }
+}
+
+type queuedKey struct{}
+
+// defaultQueuedHeaders are the headers persisted with queued requests if
+// Server.QueuedHeaders is nil.
+var defaultQueuedHeaders = []string{"Authorization"}
+
+var defaultQueueRetry = &RetryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: time.Minute}
+
+// enqueue persists the request with the headers of r to keep and
+// schedules it for processing.
+func (s *Server) enqueue(r *http.Request, req *serverRequest) (receipt *QueueReceipt, err error) {
+	var raw []byte
+	if raw, err = json.Marshal(&Request{
+		Version: Version,
+		Method:  req.Method,
+		Params:  rawOrNil(req.Params),
+	}); err != nil {
+		return
+	}
+	names := s.QueuedHeaders
+	if names == nil {
+		names = defaultQueuedHeaders
+	}
+	entry := QueueEntry{Request: raw}
+	for _, name := range names {
+		if values := r.Header.Values(name); len(values) != 0 {
+			if entry.Header == nil {
+				entry.Header = make(http.Header)
+			}
+			entry.Header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	if entry.Seq, err = s.RequestQueue.Append(raw, entry.Header); err != nil {
+		return
+	}
+	go s.processQueued(context.Background(), entry)
+	return &QueueReceipt{entry.Seq}, nil
+}
+
+// processQueued runs a queued request, retrying it according to
+// QueueRetry, and acknowledges it once it succeeded or was handed to
+// OnQueueFailure. A request failing otherwise stays pending.
+func (s *Server) processQueued(ctx context.Context, entry QueueEntry) {
+	ctx = context.WithValue(ctx, queuedKey{}, true)
+	policy := s.QueueRetry
+	if policy == nil {
+		policy = defaultQueueRetry
+	}
+	var err *Error
+	for attempt := 1; ; attempt++ {
+		if err = s.runQueued(ctx, entry); err == nil {
+			break
+		}
+		if attempt >= policy.MaxAttempts || !wait(s.clock(), policy.backoff(attempt), ctx.Done()) {
+			break
+		}
+	}
+	if err != nil {
+		log.Printf("rpc: queued request %d failed: %s", entry.Seq, err.Message)
+		if s.OnQueueFailure == nil {
+			return
+		}
+		s.OnQueueFailure(entry, err)
+	}
+	if errAck := s.RequestQueue.Ack(entry.Seq); errAck != nil {
+		log.Printf("rpc: failed to acknowledge queued request %d: %v", entry.Seq, errAck)
+	}
+}
+
+// runQueued runs a queued request once with its headers, returning the
+// error it was answered with.
+func (s *Server) runQueued(ctx context.Context, entry QueueEntry) *Error {
+	var resp Response
+	if err := json.Unmarshal(s.Dispatcher().handle(ctx, entry.Request, entry.Header), &resp); err != nil {
+		return &Error{Code: E_INTERNAL, Message: "rpc: queued request was not answered"}
+	}
+	return resp.Error
+}
+
+// ReplayQueue processes the requests left pending in the RequestQueue, e.g.
+// by a crash, in the order they were accepted. Call it on startup before
+// serving new requests.
+func (s *Server) ReplayQueue(ctx context.Context) (err error) {
+	var entries []QueueEntry
+	if entries, err = s.RequestQueue.Pending(); err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		s.processQueued(ctx, entry)
+	}
+	return
+}
+
+func rawOrNil(raw *json.RawMessage) json.RawMessage {
+	if raw == nil {
+		return nil
+	}
+	return *raw
+}
+
+// FileQueue is a RequestQueue backed by an append-only log file, which is
+// compacted every time it is opened.
+type FileQueue struct {
+	sync.Mutex
+	file    *os.File
+	next    uint64
+	pending map[uint64]QueueEntry
+}
+
+// fileQueueRecord is one line of the log: an appended request or an ack.
+type fileQueueRecord struct {
+	Seq     uint64          `json:"seq"`
+	Request json.RawMessage `json:"request,omitempty"`
+	Header  http.Header     `json:"header,omitempty"`
+	Ack     bool            `json:"ack,omitempty"`
+}
+
+// OpenFileQueue opens or creates the queue log at path.
+func OpenFileQueue(path string) (q *FileQueue, err error) {
+	q = &FileQueue{pending: make(map[uint64]QueueEntry), next: 1}
+
+	var file *os.File
+	if file, err = os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0o600); err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		var record fileQueueRecord
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			// A torn write at the end of the log.
+			continue
+		}
+		if record.Ack {
+			delete(q.pending, record.Seq)
+		} else {
+			q.pending[record.Seq] = QueueEntry{Seq: record.Seq, Request: record.Request, Header: record.Header}
+		}
+		if record.Seq >= q.next {
+			q.next = record.Seq + 1
+		}
+	}
+	err = scanner.Err()
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// Rewrite the log with only the pending requests.
+	tmp := path + ".tmp"
+	if q.file, err = os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600); err != nil {
+		return nil, err
+	}
+	entries, _ := q.Pending()
+	for _, entry := range entries {
+		if err = q.write(&fileQueueRecord{Seq: entry.Seq, Request: entry.Request, Header: entry.Header}); err != nil {
+			q.file.Close()
+			return nil, err
+		}
+	}
+	if err = q.file.Sync(); err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		q.file.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *FileQueue) write(record *fileQueueRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = q.file.Write(append(line, '\n'))
+	return err
+}
+
+func (q *FileQueue) Append(request []byte, header http.Header) (seq uint64, err error) {
+	q.Lock()
+	defer q.Unlock()
+	seq = q.next
+	if err = q.write(&fileQueueRecord{Seq: seq, Request: request, Header: header}); err != nil {
+		return
+	}
+	// The record may be durable even if syncing fails, so its seq must not
+	// be reused.
+	q.next++
+	if err = q.file.Sync(); err != nil {
+		return
+	}
+	q.pending[seq] = QueueEntry{Seq: seq, Request: append(json.RawMessage(nil), request...), Header: header.Clone()}
+	return
+}
+
+func (q *FileQueue) Ack(seq uint64) (err error) {
+	q.Lock()
+	defer q.Unlock()
+	if _, ok := q.pending[seq]; !ok {
+		return
+	}
+	if err = q.write(&fileQueueRecord{Seq: seq, Ack: true}); err != nil {
+		return
+	}
+	delete(q.pending, seq)
+	return
+}
+
+func (q *FileQueue) Pending() ([]QueueEntry, error) {
+	q.Lock()
+	defer q.Unlock()
+	entries := make([]QueueEntry, 0, len(q.pending))
+	for _, entry := range q.pending {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries, nil
+}
+
+// Close closes the log file.
+func (q *FileQueue) Close() error {
+	q.Lock()
+	defer q.Unlock()
+	return q.file.Close()
+}
@@ -0,0 +1,219 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memQueue is a RequestQueue recording its calls.
+type memQueue struct {
+	sync.Mutex
+	entries []QueueEntry
+	acked   map[uint64]bool
+	ack     chan uint64
+}
+
+func (q *memQueue) Append(request []byte, header http.Header) (seq uint64, err error) {
+	q.Lock()
+	defer q.Unlock()
+	seq = uint64(len(q.entries) + 1)
+	q.entries = append(q.entries, QueueEntry{Seq: seq, Request: request, Header: header})
+	return
+}
+
+func (q *memQueue) Ack(seq uint64) error {
+	q.Lock()
+	if q.acked == nil {
+		q.acked = make(map[uint64]bool)
+	}
+	q.acked[seq] = true
+	q.Unlock()
+	if q.ack != nil {
+		q.ack <- seq
+	}
+	return nil
+}
+
+func (q *memQueue) Pending() ([]QueueEntry, error) {
+	q.Lock()
+	defer q.Unlock()
+	var entries []QueueEntry
+	for _, entry := range q.entries {
+		if !q.acked[entry.Seq] {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+type QueueArgs struct {
+	N int `json:"n"`
+}
+
+func requireAuth(next CallHandler) CallHandler {
+	return func(r *http.Request, method string, args, reply interface{}) error {
+		if r.Header.Get("Authorization") != "Bearer ok" {
+			return &Error{Code: E_SERVER, Message: "unauthorized"}
+		}
+		return next(r, method, args, reply)
+	}
+}
+
+func TestDurableQueueAccept(t *testing.T) {
+	tests := []struct {
+		name       string
+		auth       string
+		wantQueued bool
+	}{
+		{"authorized", "Bearer ok", true},
+		{"unauthorized", "Bearer bad", false},
+		{"anonymous", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := &memQueue{ack: make(chan uint64, 1)}
+			s := &Server{RequestQueue: queue}
+			s.Use(requireAuth)
+			handled := make(chan string, 1)
+			if err := s.Register("job.put", func(r *http.Request, args *QueueArgs) error {
+				handled <- r.Header.Get("Authorization")
+				return nil
+			}, WithDurableQueue()); err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest("POST", "/", strings.NewReader(`{"jsonrpc":"2.0","method":"job.put","params":{"n":1},"id":1}`))
+			r.Header.Set("Content-Type", "application/json")
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+
+			var resp Response
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding %q: %v", w.Body.String(), err)
+			}
+			if queued := resp.Error == nil; queued != tt.wantQueued {
+				t.Fatalf("queued = %v, want %v (response %s)", queued, tt.wantQueued, w.Body.String())
+			}
+			pending, _ := queue.Pending()
+			if !tt.wantQueued {
+				if len(queue.entries) != 0 {
+					t.Fatalf("rejected call was queued: %v", pending)
+				}
+				return
+			}
+			if got := queue.entries[0].Header.Get("Authorization"); got != tt.auth {
+				t.Errorf("queued Authorization = %q, want %q", got, tt.auth)
+			}
+			select {
+			case got := <-handled:
+				if got != tt.auth {
+					t.Errorf("handler saw Authorization %q, want %q", got, tt.auth)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("queued call was not processed")
+			}
+			<-queue.ack
+		})
+	}
+}
+
+func TestReplayQueue(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int
+		maxAttempts int
+		deadLetter  bool
+		wantCalls   int
+		wantAcked   bool
+		wantDead    bool
+	}{
+		{"succeeds", 0, 3, false, 1, true, false},
+		{"succeeds on retry", 2, 3, false, 3, true, false},
+		{"keeps failing", 5, 3, false, 3, false, false},
+		{"dead letter", 5, 2, true, 2, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := &memQueue{}
+			var dead []QueueEntry
+			s := &Server{
+				RequestQueue: queue,
+				QueueRetry:   &RetryPolicy{MaxAttempts: tt.maxAttempts},
+			}
+			if tt.deadLetter {
+				s.OnQueueFailure = func(entry QueueEntry, err *Error) { dead = append(dead, entry) }
+			}
+			calls := 0
+			if err := s.Register("job.put", func(r *http.Request, args *QueueArgs) error {
+				if calls++; calls <= tt.failures {
+					return errors.New("transient")
+				}
+				return nil
+			}, WithDurableQueue()); err != nil {
+				t.Fatal(err)
+			}
+			queue.Append([]byte(`{"jsonrpc":"2.0","method":"job.put","params":{"n":1},"id":null}`), nil)
+
+			if err := s.ReplayQueue(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if acked := queue.acked[1]; acked != tt.wantAcked {
+				t.Errorf("acked = %v, want %v", acked, tt.wantAcked)
+			}
+			if gotDead := len(dead) == 1; gotDead != tt.wantDead {
+				t.Errorf("dead-lettered = %v, want %v", gotDead, tt.wantDead)
+			}
+		})
+	}
+}
+
+func TestFileQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.log")
+	q, err := OpenFileQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{"Authorization": {"Bearer ok"}}
+	for i := 0; i < 3; i++ {
+		if _, err = q.Append([]byte(`{}`), header); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = q.Ack(2); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	if q, err = OpenFileQueue(path); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	pending, _ := q.Pending()
+	var seqs []uint64
+	for _, entry := range pending {
+		seqs = append(seqs, entry.Seq)
+		if got := entry.Header.Get("Authorization"); got != "Bearer ok" {
+			t.Errorf("entry %d: Authorization = %q, want %q", entry.Seq, got, "Bearer ok")
+		}
+	}
+	if len(seqs) != 2 || seqs[0] != 1 || seqs[1] != 3 {
+		t.Errorf("pending = %v, want [1 3]", seqs)
+	}
+	if seq, _ := q.Append([]byte(`{}`), nil); seq != 4 {
+		t.Errorf("next seq = %d, want 4", seq)
+	}
+}
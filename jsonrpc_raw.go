@@ -0,0 +1,35 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// RawHandler handles a call given its undecoded params, which are nil if
+// the call has none, and returns the encoded result.
+type RawHandler func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error)
+
+func (h RawHandler) NewArgs() interface{} {
+	return new(json.RawMessage)
+}
+
+func (h RawHandler) NewReply() interface{} {
+	return new(json.RawMessage)
+}
+
+func (h RawHandler) Call(r *http.Request, args, reply interface{}) error {
+	result, err := h(handlerContext(r), *args.(*json.RawMessage))
+	if err != nil {
+		return err
+	}
+	*reply.(*json.RawMessage) = result
+	return nil
+}
+
+// RegisterRaw adds a handler that does its own decoding and encoding, or
+// forwards payloads untouched, dispatched without reflection. Middleware
+// sees args and reply of type *json.RawMessage.
+func (s *Server) RegisterRaw(method string, handler RawHandler, opts ...MethodOption) error {
+	return s.RegisterAdapter(method, handler, opts...)
+}
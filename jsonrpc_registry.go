@@ -0,0 +1,87 @@
+package jsonrpc
+
+import "strings"
+
+// registry is a snapshot of the state calls are routed with. It is
+// replaced as a whole whenever the state changes, so calls read it
+// without locking the Server.
+type registry struct {
+	methods           map[string]*methodSpec
+	aliases           map[string]string
+	folded            map[string]string
+	middleware        []Middleware
+	patternMiddleware []patternMiddleware
+	patterns          []patternMethod
+	providers         []*provider
+	versions          map[string][]int
+	blobs             bool // whether any method streams params
+}
+
+var emptyRegistry = &registry{}
+
+// routing returns the current snapshot of the routing state.
+func (s *Server) routing() *registry {
+	if reg, ok := s.registry.Load().(*registry); ok {
+		return reg
+	}
+	return emptyRegistry
+}
+
+// publish replaces the snapshot of the routing state after a change. s
+// must be locked.
+func (s *Server) publish() {
+	methods := cloneMap(s.methods)
+	s.registry.Store(&registry{
+		methods:           methods,
+		aliases:           cloneMap(s.aliases),
+		folded:            cloneMap(s.folded),
+		middleware:        s.middleware[:len(s.middleware):len(s.middleware)],
+		patternMiddleware: s.patternMiddleware[:len(s.patternMiddleware):len(s.patternMiddleware)],
+		patterns:          s.patterns[:len(s.patterns):len(s.patterns)],
+		providers:         s.providers[:len(s.providers):len(s.providers)],
+		versions:          versionsOf(methods),
+		blobs:             streamsParams(methods),
+	})
+}
+
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	clone := make(map[K]V, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
+}
+
+func cloneSlice[T any](s []T) []T {
+	return append([]T(nil), s...)
+}
+
+// maxAliasHops bounds the aliases resolve follows, so a chain that
+// loops through case folding cannot hang a call.
+const maxAliasHops = 16
+
+// resolve returns the method an alias or, with fold set, a differently
+// cased name stands for, or method itself.
+func (reg *registry) resolve(method string, fold bool) string {
+	for hops := 0; hops < maxAliasHops; hops++ {
+		target, ok := reg.next(method, fold)
+		if !ok {
+			break
+		}
+		method = target
+	}
+	return method
+}
+
+// next returns the name method stands for one hop away, if it is not a
+// method itself.
+func (reg *registry) next(method string, fold bool) (target string, ok bool) {
+	if _, ok = reg.methods[method]; ok {
+		return "", false
+	}
+	target, ok = reg.aliases[method]
+	if !ok && fold {
+		target, ok = reg.folded[strings.ToLower(method)]
+	}
+	return target, ok && target != method
+}
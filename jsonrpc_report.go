@@ -0,0 +1,46 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+var errEncode = errors.New("rpc: result could not be encoded")
+
+// ErrorReport describes a handler panic or an internal error, that is an
+// error answered with code E_INTERNAL, for Server.ErrorReporter.
+type ErrorReport struct {
+	Method string
+
+	// Params is the JSON encoding of the method's args when the report was
+	// made, or nil if they cannot be encoded.
+	Params json.RawMessage
+
+	// Request is the request being served. Its body has been consumed.
+	Request *http.Request
+
+	// Err is the error returned by the handler, or describes the panic.
+	Err error
+
+	// Panic is the value the handler panicked with, and Goroutine and Stack
+	// locate the panic. They are zero for errors.
+	Panic     interface{}
+	Goroutine int64
+	Stack     []byte
+}
+
+func (s *Server) report(report *ErrorReport) {
+	if s.ErrorReporter != nil {
+		s.ErrorReporter(report)
+	}
+}
+
+// snapshot encodes args for a report.
+func snapshot(args interface{}) json.RawMessage {
+	b, err := json.Marshal(args)
+	if err != nil {
+		return nil
+	}
+	return b
+}
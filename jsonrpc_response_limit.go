@@ -0,0 +1,51 @@
+package jsonrpc
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ErrResponseTooLarge is returned when a response body exceeds
+// Client.MaxResponseSize.
+var ErrResponseTooLarge = errors.New("rpc: response body too large")
+
+// limitResponse caps the bytes read from the body of resp at
+// MaxResponseSize, after decompression.
+func (client *Client) limitResponse(resp *http.Response) {
+	if client.MaxResponseSize <= 0 {
+		return
+	}
+	if resp.ContentLength > client.MaxResponseSize {
+		resp.Body = &limitedBody{ReadCloser: resp.Body, err: ErrResponseTooLarge}
+		return
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: client.MaxResponseSize}
+}
+
+// limitedBody fails reads with ErrResponseTooLarge once more than the
+// allowed bytes have been read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	err       error
+}
+
+func (b *limitedBody) Read(p []byte) (n int, err error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	// Read one byte past the limit to tell a body of exactly the limit from
+	// a larger one.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err = b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.err = ErrResponseTooLarge
+		err = b.err
+	}
+	b.remaining -= int64(n)
+	return
+}
@@ -0,0 +1,115 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path"
+	"time"
+)
+
+// RetryPolicy controls automatic retries of failed calls.
+//
+// Calls of idempotent methods are retried on any transport error and on
+// responses with status 429, 502, 503 or 504. Calls of other methods are
+// retried only when the request provably never left the client, i.e. when
+// resolving or dialing the server failed, so mutations are never duplicated.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Values below 2 disable retries.
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry. It doubles with every
+	// further retry up to MaxBackoff, with random jitter of up to 50%.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Idempotent lists the methods that are safe to repeat, as exact names
+	// or path.Match patterns such as "download.get*".
+	Idempotent []string
+
+	// Rand is the source of the jitter. Defaults to the global source of
+	// math/rand.
+	Rand Rand
+}
+
+// IsIdempotent reports whether method matches one of the idempotent patterns.
+func (p *RetryPolicy) IsIdempotent(method string) bool {
+	for _, pattern := range p.Idempotent {
+		if ok, _ := path.Match(pattern, method); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// retryable reports whether an attempt that ended with resp or err may be
+// repeated for method.
+func (p *RetryPolicy) retryable(method string, resp *http.Response, err error) bool {
+	if err != nil {
+		if isPreTransmission(err) {
+			return true
+		}
+		return p.IsIdempotent(method) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return p.IsIdempotent(method)
+	}
+	return false
+}
+
+// backoff returns the delay before the given retry, counted from 1.
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(randOr(p.Rand).Int63n(int64(d/2)+1))
+}
+
+// isPreTransmission reports whether err happened before any byte of the
+// request could have reached the server.
+func isPreTransmission(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// send posts body, retrying according to policy.
+func (client *Client) send(ctx context.Context, url, method string, body []byte, header http.Header, policy *RetryPolicy) (resp *http.Response, err error) {
+	for attempt := 1; ; attempt++ {
+		if client.Accounting != nil {
+			if err = client.Accounting.reserve(url, method, len(body)); err != nil {
+				return
+			}
+		}
+		stats := client.stats()
+		start := time.Now()
+		resp, err = client.post(stats.trace(ctx, url), url, body, header)
+		stats.record(url, start, resp, err)
+		if client.Accounting != nil {
+			client.Accounting.record(url, method, start, resp, err)
+		}
+		if policy == nil || attempt >= policy.MaxAttempts || !policy.retryable(method, resp, err) {
+			return
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		if !wait(clockOr(client.Clock), policy.backoff(attempt), ctx.Done()) {
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -1,24 +1,32 @@
 package jsonrpc
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unicode"
 	"unicode/utf8"
 )
 
 var (
 	ErrHandlerNotExported = errors.New("method handler is not exported")
-	ErrHandlerSignature   = errors.New("method handler must has signature func(*http.Request, <*Args>, <*Reply>) error")
+	ErrHandlerSignature   = errors.New("method handler must has signature func(*http.Request or context.Context[, <*Args>][, <*Reply>]) error or (<Reply>, error)")
 )
 
 var (
 	// Precompute the reflect.Type of error and http.Request
 	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
 	typeOfRequest = reflect.TypeOf((*http.Request)(nil)).Elem()
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeOfNoArgs  = reflect.TypeOf(struct{}{})
+	typeOfNoReply = reflect.TypeOf((*interface{})(nil)).Elem()
 	nilErrorValue = reflect.Zero(reflect.TypeOf((*error)(nil)).Elem())
 )
 
@@ -26,16 +34,273 @@ var (
 
 type Server struct {
 	sync.Mutex
-	methods map[string]*methodSpec
+
+	// Path, if set, is the only URL path served; requests for any other
+	// path are passed to NotFoundHandler. Useful when the Server is mounted
+	// at a prefix.
+	Path string
+
+	// NonPostHandler serves requests whose HTTP method is not POST, e.g. to
+	// answer OPTIONS or serve a playground page on GET. By default they are
+	// rejected with 405 Method Not Allowed.
+	NonPostHandler http.Handler
+
+	// NotFoundHandler serves requests for paths other than Path. Defaults to
+	// http.NotFoundHandler.
+	NotFoundHandler http.Handler
+
+	// TimeoutHeader names the header from which the caller's deadline is
+	// applied to the handler's context. Defaults to DefaultTimeoutHeader.
+	TimeoutHeader string
+
+	// PayloadLogger, if set, logs the bodies of a sample of calls.
+	PayloadLogger *PayloadLogger
+
+	// RecoverPanics makes the Server recover from panicking handlers,
+	// logging the panic with its stack and answering with an internal error.
+	RecoverPanics bool
+
+	// Debug includes the panic value, goroutine id and stack trace of
+	// recovered panics in the error data. Never enable it in production.
+	Debug bool
+
+	// ErrorTranslator, if set, maps errors returned by handlers to
+	// JSON-RPC errors.
+	ErrorTranslator *ErrorTranslator
+
+	// Messages, if set, localizes error messages according to the request's
+	// Accept-Language header or the language set with WithLanguage.
+	Messages MessageCatalog
+
+	// MaxRequestSize, if positive, limits the size of request bodies.
+	MaxRequestSize int64
+
+	// MaxBlobSize limits the decoded size of each []byte or io.Reader
+	// field of args structs, which are streamed out of requests as base64
+	// instead of being held in memory while the request is decoded.
+	// io.Reader params are spooled to temporary files, removed once the
+	// call is answered. Defaults to DefaultMaxBlobSize.
+	MaxBlobSize int64
+
+	// VerifyChecksums rejects requests whose body does not match their
+	// Content-MD5 or Digest header before decoding them, to catch bodies
+	// corrupted by hops without TLS. Requests without either header are
+	// served as usual.
+	VerifyChecksums bool
+
+	// StrictRequests rejects JSON requests that violate the JSON-RPC 2.0
+	// specification with Invalid Request: those without "jsonrpc": "2.0",
+	// with members other than jsonrpc, method, params and id, or with
+	// params that are neither an object nor an array. It applies to every
+	// request decoded by a Codec, whichever Content-Type it was registered
+	// for, including the calls of batches; other codecs check requests
+	// themselves.
+	StrictRequests bool
+
+	// Clock times cache expiry, tenant rate limits, session idle timeouts,
+	// drains and advertisement refreshes. Defaults to SystemClock.
+	Clock Clock
+
+	// MaxBatchSize limits the number of calls in a batch request. Defaults
+	// to DefaultMaxBatchSize.
+	MaxBatchSize int
+
+	// MaxAttachmentSize limits the total size of a multipart request.
+	// Defaults to DefaultMaxAttachmentSize.
+	MaxAttachmentSize int64
+
+	// RequestQueue, if set, persists the calls of methods registered
+	// WithDurableQueue before they are acknowledged.
+	RequestQueue RequestQueue
+
+	// QueuedHeaders names the request headers persisted with queued calls
+	// and restored when they are processed, e.g. for the middleware to
+	// authenticate them again. Defaults to Authorization.
+	QueuedHeaders []string
+
+	// QueueRetry controls the attempts at processing a queued call. Only
+	// MaxAttempts, Backoff, MaxBackoff and Rand apply. Defaults to 3
+	// attempts with a backoff from one second up to a minute.
+	QueueRetry *RetryPolicy
+
+	// OnQueueFailure, if set, is handed the queued calls that failed every
+	// attempt, e.g. to move them to a dead-letter queue; they are
+	// acknowledged once it returns. Without it they stay pending and are
+	// processed again by ReplayQueue.
+	OnQueueFailure func(entry QueueEntry, err *Error)
+
+	// ResponseCache stores the results of methods registered WithCache.
+	// Defaults to an in-memory cache; use a FileCache to keep the results
+	// across restarts. It is read on the first cached call.
+	ResponseCache ResponseCache
+
+	// Tenants, if set, limits the calls of each tenant, so that one
+	// tenant's burst cannot starve the others. It runs after all
+	// middleware, so Tenant sees the request as authenticated by it.
+	Tenants *TenantQuotas
+
+	// OnSessionOpen and OnSessionClose, if set, are called when a session
+	// served by ServeConn starts and ends.
+	OnSessionOpen  func(*Session)
+	OnSessionClose func(*Session)
+
+	// SessionIdleTimeout, if positive, ends sessions that receive no
+	// message for that long, e.g. of abandoned browser tabs. They are sent
+	// an rpc.goingAway notification with the reason "idle" first.
+	SessionIdleTimeout time.Duration
+
+	// OnRegister and OnUnregister, if set, are called after a method is
+	// registered, including replaced, and unregistered, e.g. to regenerate
+	// documentation or advertise the methods to a gateway.
+	OnRegister   func(info MethodInfo)
+	OnUnregister func(method string)
+
+	// OnStart and OnShutdown, if set, are called when ListenAndServe or
+	// ListenAndServeTLS starts listening on addr and once it stopped
+	// serving.
+	OnStart    func(addr net.Addr)
+	OnShutdown func()
+
+	// CaseInsensitiveMethods resolves method names ignoring case, for
+	// clients that send "Aria2.AddUri" for "aria2.addUri", and matches
+	// method, middleware and visibility patterns likewise. Exact matches
+	// take precedence. Set it before registering methods, so registering
+	// names or patterns that differ only in case fails.
+	CaseInsensitiveMethods bool
+
+	// ErrorReporter, if set, receives handler panics and internal errors,
+	// e.g. to forward them to an error tracking service.
+	ErrorReporter func(report *ErrorReport)
+
+	// Errors, if set, is the registry of error codes handlers may return.
+	// Handler errors with any other code are replaced by an internal error.
+	Errors *ErrorRegistry
+
+	methods           map[string]*methodSpec
+	codecs            map[string]ServerCodec
+	compressions      []*compression
+	dictionaries      []*compression
+	middleware        []Middleware
+	patternMiddleware []patternMiddleware
+	jobs              *JobManager
+	sessions          map[string]*Session
+	aliases           map[string]string
+	folded            map[string]string
+	patterns          []patternMethod
+	providers         []*provider
+	dependencies      map[reflect.Type]reflect.Value
+	draining          bool
+	maintenance       int32        // accessed atomically
+	registry          atomic.Value // *registry
+	stats             serverStats
+	cacheOnce         sync.Once
+	cache             ResponseCache
+}
+
+// ServerCodec creates a ServerCodecRequest to process each request.
+type ServerCodec interface {
+	NewRequest(*http.Request) ServerCodecRequest
+}
+
+// ServerCodecRequest decodes a request and encodes its response in one
+// particular wire format.
+type ServerCodecRequest interface {
+	// Method returns the name of the method to be invoked.
+	Method() (string, error)
+	// ReadRequest decodes the method params into args.
+	ReadRequest(args interface{}) error
+	// WriteResponse encodes the reply and writes it to the ResponseWriter.
+	WriteResponse(w http.ResponseWriter, reply interface{})
+	// WriteError encodes the error and writes it to the ResponseWriter.
+	WriteError(w http.ResponseWriter, status int, err error)
+}
+
+// RegisterCodec adds a codec for the given Content-Type, so one Server can
+// serve clients speaking different encodings. Requests whose Content-Type has
+// no registered codec are decoded as JSON.
+func (s *Server) RegisterCodec(codec ServerCodec, contentType string) {
+	s.Lock()
+	defer s.Unlock()
+	if s.codecs == nil {
+		s.codecs = make(map[string]ServerCodec)
+	}
+	s.codecs[strings.ToLower(contentType)] = codec
+}
+
+// codec returns the codec registered for the request's Content-Type.
+func (s *Server) codec(r *http.Request) ServerCodec {
+	contentType := r.Header.Get("Content-Type")
+	if i := strings.Index(contentType, ";"); i != -1 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	s.Lock()
+	codec := s.codecs[contentType]
+	s.Unlock()
+	if codec == nil {
+		return jsonCodec{NewCodec()}
+	}
+	return codec
 }
 
 type methodSpec struct {
-	method    reflect.Value // receiver method
-	argsType  reflect.Type  // type of the request argument
-	replyType reflect.Type  // type of the response argument
+	method       reflect.Value // receiver method
+	argsType     reflect.Type  // type of the request argument
+	replyType    reflect.Type  // type of the response argument
+	doc          MethodDoc     // documentation metadata
+	middleware   []Middleware  // method specific middleware
+	async        bool          // whether calls run as jobs
+	durable      bool          // whether calls go through the request queue
+	visible      func(context.Context) bool
+	context      bool // whether the handler takes a context.Context
+	noArgs       bool // whether the handler takes no args
+	noReply      bool // whether the handler takes no reply
+	returnsReply bool // whether the handler returns its result
+	replace      bool // whether the registration may replace another
+
+	delta *deltaHistory // set by WithDelta
+	etag  bool          // set by WithETag
+
+	cacheTTL time.Duration // set by WithCache
+	mutating bool          // set by WithMutating
+	pattern  bool          // registered with RegisterPattern
+	ordered  bool          // set by WithOrdered
+
+	paramStructure ParamStructure // set by WithParamStructure
+	defaults       []fieldDefault // declared by args struct tags
+
+	argBlobs   []blobField // streamed fields of the args
+	replyBlobs []blobField // streamed fields of the reply
+
+	// adapter, if set, is called in place of method without reflection.
+	adapter Adapter
 }
 
-func (s *Server) Register(method string, handler interface{}) (err error) {
+// Register adds a handler for method. The options may attach metadata such
+// as documentation to the method.
+//
+// The handler has the signature func(*http.Request, *Args, *Reply) error,
+// or func(context.Context, *Args, *Reply) error for handlers that do not
+// depend on HTTP; the request remains reachable with RequestFromContext.
+// Handlers without params or result may leave out the reply, or both args
+// and reply: func(*http.Request, *Args) error or func(*http.Request) error.
+// Methods with a reply but no params are registered as
+// func(*http.Request, *Reply) error together with WithReplyOnly. Calls of
+// methods without a reply are answered with a null result.
+//
+// Handlers without a reply argument may instead return their result, as
+// in func(context.Context, *Args) (*Reply, error) or
+// func(context.Context, *Args) (interface{}, error).
+func (s *Server) Register(method string, handler interface{}, opts ...MethodOption) error {
+	spec, err := newMethodSpec(handler)
+	if err != nil {
+		return err
+	}
+	return s.add(method, spec, opts)
+}
+
+// newMethodSpec checks the signature of a handler passed to Register.
+func newMethodSpec(handler interface{}) (spec *methodSpec, err error) {
 	vMethod := reflect.ValueOf(handler)
 	tMethod := vMethod.Type()
 
@@ -44,131 +309,454 @@ func (s *Server) Register(method string, handler interface{}) (err error) {
 		return
 	}
 
-	// Handler needs three inputs: *http.Request, *args, *reply.
-	if tMethod.NumIn() != 3 {
+	// Handler needs one to three inputs: *http.Request, *args, *reply.
+	if tMethod.NumIn() < 1 || tMethod.NumIn() > 3 {
 		err = ErrHandlerSignature
 		return
 	}
 
-	// First argument must be a pointer and must be http.Request.
+	// First argument must be a pointer to http.Request or a context.Context.
 	reqType := tMethod.In(0)
-	if reqType.Kind() != reflect.Ptr || reqType.Elem() != typeOfRequest {
+	withContext := reqType == typeOfContext
+	if !withContext && (reqType.Kind() != reflect.Ptr || reqType.Elem() != typeOfRequest) {
 		err = ErrHandlerSignature
 		return
 	}
 
-	// Second argument must be a pointer and must be exported.
-	args := tMethod.In(1)
-	if args.Kind() != reflect.Ptr || !isExportedOrBuiltin(args) {
-		err = ErrHandlerSignature
-		return
+	// The remaining arguments must be pointers and must be exported.
+	for i := 1; i < tMethod.NumIn(); i++ {
+		if t := tMethod.In(i); t.Kind() != reflect.Ptr || !isExportedOrBuiltin(t) {
+			err = ErrHandlerSignature
+			return
+		}
 	}
 
-	// Third argument must be a pointer and must be exported.
-	reply := tMethod.In(2)
-	if reply.Kind() != reflect.Ptr || !isExportedOrBuiltin(reply) {
+	// Method needs one out: error, or two: result and error for handlers
+	// that take no reply.
+	returnsReply := tMethod.NumOut() == 2 && tMethod.NumIn() < 3
+	if tMethod.NumOut() != 1 && !returnsReply {
 		err = ErrHandlerSignature
 		return
 	}
-
-	// Method needs one out: error.
-	if tMethod.NumOut() != 1 {
+	if returnType := tMethod.Out(tMethod.NumOut() - 1); returnType != typeOfError {
 		err = ErrHandlerSignature
 		return
 	}
-	if returnType := tMethod.Out(0); returnType != typeOfError {
-		err = ErrHandlerSignature
-		return
+
+	spec = &methodSpec{
+		method:    vMethod,
+		argsType:  typeOfNoArgs,
+		replyType: typeOfNoReply,
+		context:   withContext,
+		noArgs:    tMethod.NumIn() < 2,
+		noReply:   tMethod.NumIn() < 3,
+	}
+	if !spec.noArgs {
+		spec.argsType = tMethod.In(1).Elem()
+	}
+	if !spec.noReply {
+		spec.replyType = tMethod.In(2).Elem()
+	}
+	if returnsReply {
+		spec.replyType = tMethod.Out(0)
+		spec.returnsReply = true
 	}
+	spec.argBlobs = blobFieldsOf(spec.argsType)
+	spec.replyBlobs = blobFieldsOf(spec.replyType)
+	return
+}
 
-	// Add to the map.
+// add applies the options to spec and adds it to the registry.
+func (s *Server) add(method string, spec *methodSpec, opts []MethodOption) error {
+	if err := spec.configure(opts); err != nil {
+		return err
+	}
+	if err := s.store(method, spec); err != nil {
+		return err
+	}
+	if s.OnRegister != nil {
+		s.OnRegister(spec.info(method))
+	}
+	return nil
+}
+
+// configure applies the options to spec and parses its defaults.
+func (spec *methodSpec) configure(opts []MethodOption) (err error) {
+	for _, opt := range opts {
+		opt(spec)
+	}
+	spec.defaults, err = defaultsOf(spec.argsType)
+	return
+}
+
+func (s *Server) store(method string, spec *methodSpec) error {
+	return s.storeAll([]string{method}, []*methodSpec{spec})
+}
+
+// storeAll adds the methods to the registry together: if one of them
+// cannot be added, none is.
+func (s *Server) storeAll(methods []string, specs []*methodSpec) (err error) {
 	s.Lock()
 	defer s.Unlock()
 	if s.methods == nil {
 		s.methods = make(map[string]*methodSpec)
-	} else if _, ok := s.methods[method]; ok {
-		return fmt.Errorf("rpc: method already defined: %s", method)
 	}
-	s.methods[method] = &methodSpec{
-		method:    vMethod,
-		argsType:  args.Elem(),
-		replyType: reply.Elem(),
+	var replaced []*methodSpec
+	for i, method := range methods {
+		previous, ok := s.methods[method]
+		if ok && !specs[i].replace {
+			err = fmt.Errorf("rpc: method already defined: %s", method)
+			break
+		}
+		if err = s.foldName(method); err != nil {
+			break
+		}
+		replaced = append(replaced, previous)
+		s.methods[method] = specs[i]
 	}
+	if err != nil {
+		// Undo in reverse, in case a method was given twice.
+		for i := len(replaced) - 1; i >= 0; i-- {
+			if replaced[i] != nil {
+				s.methods[methods[i]] = replaced[i]
+			} else {
+				delete(s.methods, methods[i])
+				s.unfoldName(methods[i])
+			}
+		}
+		return
+	}
+	s.publish()
 	return
 }
 
-// get returns a registered method given the method's name.
-func (s *Server) get(method string) (methodSpec *methodSpec, err error) {
+// Unregister removes method, or a pattern registered with RegisterPattern,
+// reporting whether it was registered. Calls already in progress complete.
+func (s *Server) Unregister(method string) bool {
 	s.Lock()
-	methodSpec = s.methods[method]
+	_, ok := s.methods[method]
+	delete(s.methods, method)
+	s.unfoldName(method)
+	if !ok {
+		ok = s.unregisterPattern(method)
+	}
+	s.publish()
 	s.Unlock()
-	if methodSpec == nil {
-		err = fmt.Errorf("rpc: can't find method %q", method)
+	if ok && s.OnUnregister != nil {
+		s.OnUnregister(method)
+	}
+	return ok
+}
+
+// get returns a registered method given the method's name.
+func (s *Server) get(method string) (methodSpec *methodSpec, err error) {
+	reg := s.routing()
+	if methodSpec = reg.methods[method]; methodSpec == nil {
+		if methodSpec = reg.match(method, s.CaseInsensitiveMethods); methodSpec == nil {
+			methodSpec, err = reg.provide(method)
+		}
 	}
 	return
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Path != "" && r.URL.Path != s.Path {
+		if s.NotFoundHandler != nil {
+			s.NotFoundHandler.ServeHTTP(w, r)
+		} else {
+			http.NotFound(w, r)
+		}
+		return
+	}
+
 	if r.Method != "POST" {
+		if s.NonPostHandler != nil {
+			s.NonPostHandler.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Allow", "POST")
 		WriteError(w, http.StatusMethodNotAllowed, "rpc: POST method required, received "+r.Method)
 		return
 	}
 
-	codec := NewCodec()
+	if c := s.negotiateCompression(r); c != nil {
+		cw := newCompressResponseWriter(w, c)
+		defer cw.Close()
+		w = cw
+	}
+
+	if s.MaxRequestSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.MaxRequestSize)
+	}
+
+	if s.VerifyChecksums {
+		ok, err := verifyChecksum(r)
+		switch {
+		case isTooLarge(err):
+			WriteError(w, http.StatusRequestEntityTooLarge, "rpc: "+err.Error())
+			return
+		case err != nil:
+			WriteError(w, http.StatusBadRequest, "rpc: "+err.Error())
+			return
+		case !ok:
+			WriteError(w, http.StatusBadRequest, "rpc: request body does not match its checksum")
+			return
+		}
+	}
+
+	r, cancel := s.withRequestTimeout(r)
+	defer cancel()
+	r = withBaggage(r)
+
+	if s.PayloadLogger != nil {
+		if pw := s.PayloadLogger.begin(w, r); pw != nil {
+			defer pw.finish()
+			w = pw
+		}
+	}
+
+	if boundary, ok := isMultipartRelated(r.Header); ok {
+		s.serveMultipart(w, r, boundary)
+		return
+	}
+
+	if isBatch(r) {
+		s.serveBatch(w, r)
+		return
+	}
+	s.serve(w, r)
+}
+
+// serve decodes the request, dispatches it to the registered method and
+// writes the response.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.stats.request()
+
+	// Stream the []byte and io.Reader params out of the request.
+	blobs, errBlobs := s.readBlobs(r)
+	defer blobs.close()
 
 	// Create a new codec request.
-	codecReq := codec.NewRequest(r)
+	codecReq := s.codec(r).NewRequest(r)
+	if jsonReq, ok := codecReq.(*CodecRequest); ok && s.StrictRequests {
+		jsonReq.checkStrict()
+	}
+	if errBlobs != nil {
+		s.stats.fail(StageDecode, errBlobs)
+		s.writeError(w, r, codecReq, http.StatusBadRequest, errBlobs)
+		return
+	}
 
 	// Get service method to be called.
 	method, errMethod := codecReq.Method()
 	if errMethod != nil {
-		codecReq.WriteError(w, http.StatusBadRequest, errMethod)
+		s.stats.fail(methodStage(errMethod), errMethod)
+		s.writeError(w, r, codecReq, http.StatusBadRequest, errMethod)
 		return
 	}
 
+	method = s.routing().version(s.resolve(method), r.Header)
 	methodSpec, errGet := s.get(method)
+	if errGet == nil && !methodSpec.isVisible(r.Context(), method) {
+		errGet = errMethodNotFound(method)
+	}
 	if errGet != nil {
-		codecReq.WriteError(w, http.StatusBadRequest, errGet)
+		s.stats.fail(StageLookup, errGet)
+		s.writeError(w, r, codecReq, http.StatusBadRequest, errGet)
+		return
+	}
+	if errMaintenance := s.checkMaintenance(methodSpec); errMaintenance != nil {
+		s.stats.fail(StageLookup, errMaintenance)
+		s.writeError(w, r, codecReq, http.StatusServiceUnavailable, errMaintenance)
 		return
 	}
 
 	// Decode the args
-	args := reflect.New(methodSpec.argsType)
-	if errRead := codecReq.ReadRequest(args.Interface()); errRead != nil {
-		codecReq.WriteError(w, http.StatusBadRequest, errRead)
+	args := methodSpec.newArgs()
+	var errRead error
+	if jsonReq, ok := codecReq.(*CodecRequest); ok {
+		errRead = methodSpec.checkParams(jsonReq.request.Params)
+	}
+	if errRead == nil {
+		errRead = codecReq.ReadRequest(args)
+	}
+	if errRead == nil {
+		blobs.fill(args)
+	}
+	if errRead != nil {
+		s.stats.fail(StageDecode, errRead)
+		s.writeError(w, r, codecReq, http.StatusBadRequest, errRead)
 		return
 	}
 
-	// Prepare the reply
-	reply := reflect.New(methodSpec.replyType)
+	// Persist durable calls the middleware accepts and acknowledge them
+	// before processing.
+	if jsonReq, ok := codecReq.(*CodecRequest); ok && methodSpec.durable && s.RequestQueue != nil && r.Context().Value(queuedKey{}) == nil {
+		var receipt *QueueReceipt
+		var errAppend error
+		errQueue := s.wrap(method, methodSpec, func(r *http.Request, method string, args, reply interface{}) error {
+			receipt, errAppend = s.enqueue(r, jsonReq.request)
+			return errAppend
+		})(r, method, args, methodSpec.newReply())
+		switch {
+		case errAppend != nil:
+			s.stats.fail(StageHandler, errAppend)
+			s.writeError(w, r, codecReq, http.StatusServiceUnavailable, errAppend)
+		case errQueue != nil:
+			errQueue = s.mapError(errQueue)
+			s.stats.fail(StageHandler, errQueue)
+			s.writeError(w, r, codecReq, http.StatusBadRequest, errQueue)
+		default:
+			codecReq.WriteResponse(w, receipt)
+		}
+		return
+	}
 
-	errValue := methodSpec.method.Call([]reflect.Value{
-		reflect.ValueOf(r),
-		args,
-		reply,
-	})
+	// Prepare the reply
+	reply := methodSpec.newReply()
 
-	// Extract the result to error if needed.
-	var errResult error
-	statusCode := http.StatusOK
-	errInter := errValue[0].Interface()
-	if errInter != nil {
-		statusCode = http.StatusBadRequest
-		errResult = errInter.(error)
+	// Hand long-running methods to the job manager.
+	if jobs := s.jobManager(); jobs != nil && methodSpec.async {
+		ticket, errSubmit := jobs.submit(s, r, method, methodSpec, args, reply)
+		switch {
+		case errors.Is(errSubmit, ErrJobQueueFull):
+			s.stats.fail(StageHandler, errSubmit)
+			s.writeError(w, r, codecReq, http.StatusServiceUnavailable, errSubmit)
+		case errSubmit != nil:
+			errSubmit = s.mapError(errSubmit)
+			s.stats.fail(StageHandler, errSubmit)
+			s.writeError(w, r, codecReq, http.StatusBadRequest, errSubmit)
+		default:
+			codecReq.WriteResponse(w, ticket)
+		}
+		return
 	}
 
 	// Prevents Internet Explorer from MIME-sniffing a response away
 	// from the declared content-type
 	w.Header().Set("x-content-type-options", "nosniff")
 
+	// Let the handler stream partial results if the client asked for them.
+	var partial *partialWriter
+	if jsonReq, ok := codecReq.(*CodecRequest); ok && !jsonReq.notification {
+		partial = newPartialWriter(w, r, jsonReq.request.Id)
+	}
+	if partial != nil {
+		r = r.WithContext(context.WithValue(r.Context(), partialKey{}, PartialWriter(partial)))
+	}
+
+	// Report progress to the caller if the transport can deliver it.
+	if jsonReq, ok := codecReq.(*CodecRequest); ok && !jsonReq.notification && !hasProgress(r.Context()) {
+		if session, ok := SessionFromContext(r.Context()); ok {
+			r = r.WithContext(WithProgress(r.Context(), sessionProgress{session, jsonReq.request.Id}))
+		} else if partial != nil {
+			r = r.WithContext(WithProgress(r.Context(), partial))
+		}
+	}
+
+	errResult := s.invoke(r, method, methodSpec, args, reply)
+
+	if partial != nil {
+		partial.close()
+	}
+
+	// Map the error if needed.
+	statusCode := http.StatusOK
+	if errResult != nil {
+		statusCode = http.StatusBadRequest
+		errResult = s.mapError(errResult)
+	}
+
 	// Encode the response.
 	if errResult == nil {
-		codecReq.WriteResponse(w, reply.Interface())
+		result := reply
+		// Notifications are answered without a body, so they are neither
+		// tagged nor remembered as delta bases.
+		if jsonReq, ok := codecReq.(*CodecRequest); ok && partial == nil && !jsonReq.notification {
+			if methodSpec.etag {
+				var notModified bool
+				if result, notModified = conditional(w, r, result); notModified {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			}
+			if methodSpec.delta != nil {
+				result = methodSpec.delta.encode(w, r, result)
+			}
+		}
+		ew := &encodeWatcher{ResponseWriter: w}
+		if jsonReq, ok := codecReq.(*CodecRequest); ok && methodSpec.replyBlobs != nil && partial == nil && !methodSpec.etag && methodSpec.delta == nil {
+			if err := jsonReq.writeStreamed(ew, result, methodSpec.replyBlobs); err != nil {
+				ew.failed = true
+			}
+		} else {
+			codecReq.WriteResponse(ew, result)
+		}
+		if ew.failed {
+			s.stats.fail(StageEncode, &Error{Code: E_INTERNAL})
+			s.report(&ErrorReport{Method: method, Params: snapshot(args), Request: r, Err: errEncode})
+		}
 	} else {
-		codecReq.WriteError(w, statusCode, errResult)
+		s.stats.fail(StageHandler, errResult)
+		s.writeError(w, r, codecReq, statusCode, errResult)
 	}
 }
 
+// invoke calls the handler through its middleware.
+func (s *Server) invoke(r *http.Request, method string, methodSpec *methodSpec, args, reply interface{}) error {
+	return s.run(r, method, methodSpec, s.chain(method, methodSpec), args, reply)
+}
+
+// run calls handler, recovering from a panic if the Server is configured
+// to. Mutating calls fail in maintenance mode, which may have begun since
+// an async call was accepted.
+func (s *Server) run(r *http.Request, method string, methodSpec *methodSpec, handler CallHandler, args, reply interface{}) (err error) {
+	if err = s.checkMaintenance(methodSpec); err != nil {
+		return
+	}
+	if s.RecoverPanics || s.ErrorReporter != nil {
+		defer func() {
+			if p := recover(); p != nil {
+				err = s.panicError(r, method, args, p)
+				if !s.RecoverPanics {
+					panic(p)
+				}
+			}
+		}()
+	}
+	err = handler(r, method, args, reply)
+	if err != nil && s.ErrorReporter != nil {
+		if jsonErr, ok := errorOf(s.mapError(err)); ok && jsonErr.Code == E_INTERNAL {
+			s.report(&ErrorReport{Method: method, Params: snapshot(args), Request: r, Err: err})
+		}
+	}
+	return
+}
+
+// mapError unwraps the *Error an error returned by a handler wraps, or
+// translates it, and checks its code against the error registry.
+func (s *Server) mapError(err error) error {
+	if jsonErr, ok := errorOf(err); ok {
+		err = jsonErr
+	}
+	if s.ErrorTranslator != nil {
+		err = s.ErrorTranslator.Translate(err)
+	}
+	if s.Errors != nil {
+		err = s.Errors.check(err)
+	}
+	return err
+}
+
+// writeError writes err as the response, localizing its message if the
+// Server has a message catalog.
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, codecReq ServerCodecRequest, status int, err error) {
+	if s.Messages != nil {
+		err = localize(s.Messages, r, err)
+	}
+	codecReq.WriteError(w, status, err)
+}
+
 // isExported returns true of a string is an exported (upper case) name.
 func isExported(name string) bool {
 	rune, _ := utf8.DecodeRuneInString(name)
@@ -190,3 +778,10 @@ func WriteError(w http.ResponseWriter, status int, msg string) {
 	w.WriteHeader(status)
 	fmt.Fprint(w, msg)
 }
+
+// isTooLarge reports whether err is that of a body read past the limit of
+// http.MaxBytesReader. Its error type is newer than the go directive, so
+// match the message it has always had.
+func isTooLarge(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "http: request body too large")
+}
@@ -0,0 +1,106 @@
+package jsonrpc
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Stage is a step of serving a request at which it can fail.
+type Stage string
+
+const (
+	StageParse      Stage = "parse"      // the body is not valid JSON
+	StageValidation Stage = "validation" // the body is not a valid request
+	StageLookup     Stage = "lookup"     // the method is unknown or hidden
+	StageDecode     Stage = "decode"     // the params do not fit the method
+	StageHandler    Stage = "handler"    // the handler returned an error
+	StageEncode     Stage = "encode"     // the result could not be encoded
+)
+
+// ServerStats counts the requests a Server served and the failures per
+// stage, telling bad clients (parse to decode) from server bugs (handler
+// and encode) at a glance.
+type ServerStats struct {
+	Requests int64
+	Failures map[Stage]*StageStats
+}
+
+// StageStats counts the failures at one stage by JSON-RPC error code.
+type StageStats struct {
+	Count int64
+	Codes map[ErrorCode]int64
+}
+
+type serverStats struct {
+	sync.Mutex
+	requests int64
+	failures map[Stage]*StageStats
+}
+
+func (st *serverStats) request() {
+	st.Lock()
+	st.requests++
+	st.Unlock()
+}
+
+func (st *serverStats) fail(stage Stage, err error) {
+	code := E_SERVER
+	if jsonErr, ok := errorOf(err); ok {
+		code = jsonErr.Code
+	}
+	st.Lock()
+	defer st.Unlock()
+	if st.failures == nil {
+		st.failures = make(map[Stage]*StageStats)
+	}
+	stats := st.failures[stage]
+	if stats == nil {
+		stats = &StageStats{Codes: make(map[ErrorCode]int64)}
+		st.failures[stage] = stats
+	}
+	stats.Count++
+	stats.Codes[code]++
+}
+
+// Stats returns the request and failure counts since the Server started
+// or ResetStats was called.
+func (s *Server) Stats() *ServerStats {
+	s.stats.Lock()
+	defer s.stats.Unlock()
+	stats := &ServerStats{Requests: s.stats.requests, Failures: make(map[Stage]*StageStats)}
+	for stage, st := range s.stats.failures {
+		copied := &StageStats{Count: st.Count, Codes: make(map[ErrorCode]int64, len(st.Codes))}
+		for code, n := range st.Codes {
+			copied.Codes[code] = n
+		}
+		stats.Failures[stage] = copied
+	}
+	return stats
+}
+
+// ResetStats sets all counts back to zero.
+func (s *Server) ResetStats() {
+	s.stats.Lock()
+	s.stats.requests, s.stats.failures = 0, nil
+	s.stats.Unlock()
+}
+
+// methodStage classifies an error returned by ServerCodecRequest.Method.
+func methodStage(err error) Stage {
+	if jsonErr, ok := errorOf(err); ok && jsonErr.Code == E_PARSE {
+		return StageParse
+	}
+	return StageValidation
+}
+
+// encodeWatcher notices a codec failing to encode a response, which the
+// JSON codec flags directly and other codecs report with status 500.
+type encodeWatcher struct {
+	http.ResponseWriter
+	failed bool
+}
+
+func (w *encodeWatcher) WriteHeader(status int) {
+	w.failed = status >= http.StatusInternalServerError
+	w.ResponseWriter.WriteHeader(status)
+}
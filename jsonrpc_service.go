@@ -0,0 +1,102 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// BeforeCaller is implemented by services that check or prepare each call
+// of their methods. A non-nil error fails the call without running the
+// handler.
+type BeforeCaller interface {
+	BeforeCall(ctx context.Context, method string, args interface{}) error
+}
+
+// AfterCaller is implemented by services that observe the outcome of each
+// call of their methods that BeforeCall let through. The returned error
+// replaces the call's.
+type AfterCaller interface {
+	AfterCall(ctx context.Context, method string, reply interface{}, err error) error
+}
+
+// RegisterService registers every exported method of receiver that has a
+// handler signature as "name.Method", gorilla/rpc style. If name is empty
+// the type name of receiver is used. Methods with other signatures are
+// skipped; it is an error if none qualifies. Either every method is
+// registered or, on error, none is. The options apply to each registered
+// method. The BeforeCall and AfterCall methods of receivers
+// implementing BeforeCaller or AfterCaller run around each call, after
+// all other middleware.
+func (s *Server) RegisterService(receiver interface{}, name string, opts ...MethodOption) (err error) {
+	rcvr := reflect.ValueOf(receiver)
+	if name == "" {
+		name = reflect.Indirect(rcvr).Type().Name()
+	}
+	if name == "" {
+		return fmt.Errorf("rpc: no service name for type %s", rcvr.Type())
+	}
+	if hooks := serviceHooks(receiver); hooks != nil {
+		opts = append(opts[:len(opts):len(opts)], WithMiddleware(hooks))
+	}
+	var methods []string
+	var specs []*methodSpec
+	rcvrType := rcvr.Type()
+	for i := 0; i < rcvrType.NumMethod(); i++ {
+		m := rcvrType.Method(i)
+		if m.PkgPath != "" || m.Name == "BeforeCall" || m.Name == "AfterCall" {
+			continue
+		}
+		var spec *methodSpec
+		if spec, err = newMethodSpec(rcvr.Method(i).Interface()); err == ErrHandlerSignature {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if err = spec.configure(opts); err != nil {
+			return
+		}
+		methods = append(methods, name+"."+m.Name)
+		specs = append(specs, spec)
+	}
+	if len(methods) == 0 {
+		return fmt.Errorf("rpc: type %s has no exported methods of suitable type", rcvrType)
+	}
+	if err = s.storeAll(methods, specs); err != nil {
+		return
+	}
+	if s.OnRegister != nil {
+		for i, method := range methods {
+			s.OnRegister(specs[i].info(method))
+		}
+	}
+	return
+}
+
+// serviceHooks returns the middleware running the BeforeCall and AfterCall
+// methods of receiver, or nil if it has neither.
+func serviceHooks(receiver interface{}) Middleware {
+	before, hasBefore := receiver.(BeforeCaller)
+	after, hasAfter := receiver.(AfterCaller)
+	if !hasBefore && !hasAfter {
+		return nil
+	}
+	return func(next CallHandler) CallHandler {
+		return func(r *http.Request, method string, args, reply interface{}) (err error) {
+			ctx := handlerContext(r)
+			if hasBefore {
+				if err = before.BeforeCall(ctx, method, args); err != nil {
+					return
+				}
+			}
+			err = next(r, method, args, reply)
+			if hasAfter {
+				err = after.AfterCall(ctx, method, reply, err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type ItemService struct {
+	calls []string
+}
+
+func (svc *ItemService) Get(r *http.Request, args *CacheArgs, reply *int) error {
+	*reply = args.N
+	return nil
+}
+
+func (svc *ItemService) Count(ctx context.Context) (int, error) {
+	return 3, nil
+}
+
+// Helper has no handler signature.
+func (svc *ItemService) Helper(n int) int {
+	return n
+}
+
+func (svc *ItemService) unexported(r *http.Request, args *CacheArgs, reply *int) error {
+	return nil
+}
+
+func (svc *ItemService) BeforeCall(ctx context.Context, method string, args interface{}) error {
+	svc.calls = append(svc.calls, "before "+method)
+	if args, ok := args.(*CacheArgs); ok && args.N < 0 {
+		return &Error{Code: E_BAD_PARAMS, Message: "negative"}
+	}
+	return nil
+}
+
+func (svc *ItemService) AfterCall(ctx context.Context, method string, reply interface{}, err error) error {
+	svc.calls = append(svc.calls, "after "+method)
+	return err
+}
+
+type NoHandlers struct{}
+
+func (NoHandlers) Helper() {}
+
+func TestRegisterService(t *testing.T) {
+	tests := []struct {
+		name        string
+		service     interface{}
+		serviceName string
+		existing    string
+		wantMethods []string
+		wantErr     bool
+	}{
+		{"type name", new(ItemService), "", "", []string{"ItemService.Count", "ItemService.Get"}, false},
+		{"given name", new(ItemService), "items", "", []string{"items.Count", "items.Get"}, false},
+		{"no handlers", NoHandlers{}, "", "", nil, true},
+		{"conflict", new(ItemService), "items", "items.Get", []string{"items.Get"}, true},
+		{"case conflict", new(ItemService), "items", "items.count", []string{"items.count"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{CaseInsensitiveMethods: true}
+			if tt.existing != "" {
+				if err := s.Register(tt.existing, func(ctx context.Context) error { return nil }); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := s.RegisterService(tt.service, tt.serviceName)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			var methods []string
+			for _, m := range s.Methods() {
+				methods = append(methods, m.Name)
+			}
+			sort.Strings(methods)
+			if !reflect.DeepEqual(methods, tt.wantMethods) {
+				t.Errorf("methods = %v, want %v", methods, tt.wantMethods)
+			}
+		})
+	}
+}
+
+func TestServiceHooks(t *testing.T) {
+	svc := new(ItemService)
+	s := new(Server)
+	if err := s.RegisterService(svc, "items"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		n         int
+		wantCode  ErrorCode
+		wantCalls []string
+	}{
+		{1, 0, []string{"before items.Get", "after items.Get"}},
+		{-1, E_BAD_PARAMS, []string{"before items.Get"}},
+	}
+	for _, tt := range tests {
+		svc.calls = nil
+		params, _ := json.Marshal(&CacheArgs{N: tt.n})
+		resp, err := s.Dispatcher().HandleRequest(context.Background(), &Request{Version: Version, Method: "items.Get", Params: params, ID: json.RawMessage("1")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var code ErrorCode
+		if resp.Error != nil {
+			code = resp.Error.Code
+		}
+		if code != tt.wantCode {
+			t.Errorf("n=%d: code = %d, want %d", tt.n, code, tt.wantCode)
+		}
+		if !reflect.DeepEqual(svc.calls, tt.wantCalls) {
+			t.Errorf("n=%d: calls = %v, want %v", tt.n, svc.calls, tt.wantCalls)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// goingAwayTimeout bounds the wait for the goingAway notification to idle
+// sessions before their connection is closed regardless.
+const goingAwayTimeout = 5 * time.Second
+
+var ErrSessionClosed = errors.New("rpc: session closed")
+
+// Session is the state of one connection served by ServeConn, shared by
+// all calls made over it. Handlers reach it with SessionFromContext.
+type Session struct {
+	sync.Mutex
+	active       int64 // unix nanoseconds of the last message, accessed atomically
+	id           string
+	server       *Server
+	conn         io.ReadWriteCloser
+	principal    interface{}
+	values       map[interface{}]interface{}
+	rules        []visibilityRule
+	orderAll     bool
+	capabilities *Capabilities
+	closed       bool
+	writeMu      sync.Mutex
+	done         chan struct{}
+}
+
+type sessionKey struct{}
+
+// notification is a request without an id, which expects no response.
+type notification struct {
+	Version string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// SessionFromContext returns the session of the connection a call arrived on.
+func SessionFromContext(ctx context.Context) (session *Session, ok bool) {
+	session, ok = ctx.Value(sessionKey{}).(*Session)
+	return
+}
+
+// ID returns the unique id of the session.
+func (session *Session) ID() string {
+	return session.id
+}
+
+// Principal returns the authenticated identity of the session, if any.
+func (session *Session) Principal() interface{} {
+	session.Lock()
+	defer session.Unlock()
+	return session.principal
+}
+
+// SetPrincipal records the identity the session authenticated as, e.g.
+// from an auth.login method.
+func (session *Session) SetPrincipal(principal interface{}) {
+	session.Lock()
+	session.principal = principal
+	session.Unlock()
+}
+
+// Get returns a value stored in the session.
+func (session *Session) Get(key interface{}) (value interface{}, ok bool) {
+	session.Lock()
+	value, ok = session.values[key]
+	session.Unlock()
+	return
+}
+
+// Set stores a value in the session.
+func (session *Session) Set(key, value interface{}) {
+	session.Lock()
+	if session.values == nil {
+		session.values = make(map[interface{}]interface{})
+	}
+	session.values[key] = value
+	session.Unlock()
+}
+
+// Delete removes a value from the session.
+func (session *Session) Delete(key interface{}) {
+	session.Lock()
+	delete(session.values, key)
+	session.Unlock()
+}
+
+// Notify sends a notification to the peer of the session.
+func (session *Session) Notify(method string, params interface{}) error {
+	body, err := json.Marshal(&notification{Version: Version, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	return session.write(body)
+}
+
+// Done is closed once the session ends.
+func (session *Session) Done() <-chan struct{} {
+	return session.done
+}
+
+// Close ends the session and closes its connection.
+func (session *Session) Close() error {
+	session.Lock()
+	if session.closed {
+		session.Unlock()
+		return nil
+	}
+	session.closed = true
+	session.Unlock()
+	return session.conn.Close()
+}
+
+// write sends one message followed by a newline.
+func (session *Session) write(message []byte) (err error) {
+	session.writeMu.Lock()
+	defer session.writeMu.Unlock()
+	session.Lock()
+	closed := session.closed
+	session.Unlock()
+	if closed {
+		return ErrSessionClosed
+	}
+	_, err = session.conn.Write(append(message, '\n'))
+	return
+}
+
+// ServeConn serves JSON-RPC over a connection-oriented stream such as a TCP
+// connection, a Unix socket or stdio, with one JSON value per message. Calls
+// are processed concurrently, unless ordered with WithOrdered or
+// Session.SetOrdered, and share a Session. ServeConn blocks until
+// the connection is closed.
+func (s *Server) ServeConn(conn io.ReadWriteCloser) {
+	session := s.openSession(conn)
+	dispatcher := s.Dispatcher()
+	session.serve(nil, func(ctx context.Context, raw json.RawMessage) {
+		if resp := dispatcher.Handle(ctx, raw); len(resp) != 0 {
+			session.write(resp)
+		}
+	})
+	s.closeSession(session)
+}
+
+// openSession registers a new session for conn. Sessions opened while the
+// Server is draining are closed at once.
+func (s *Server) openSession(conn io.ReadWriteCloser) *Session {
+	id, _ := newID()
+	session := &Session{id: id, server: s, conn: conn, done: make(chan struct{})}
+	session.touch()
+
+	s.Lock()
+	if s.sessions == nil {
+		s.sessions = make(map[string]*Session)
+	}
+	s.sessions[id] = session
+	draining := s.draining
+	s.Unlock()
+
+	if s.OnSessionOpen != nil {
+		s.OnSessionOpen(session)
+	}
+	if draining {
+		session.Close()
+	}
+	return session
+}
+
+// serve reads messages from the connection until it fails, passing each to
+// handle concurrently, then closes the session once all handlers return.
+// Messages for which inline, if set, returns true are handled by inline in
+// the reading goroutine instead, in order.
+func (session *Session) serve(inline func(raw json.RawMessage) bool, handle func(ctx context.Context, raw json.RawMessage)) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), sessionKey{}, session))
+	decoder := json.NewDecoder(session.conn)
+	var wg sync.WaitGroup
+	var last chan struct{} // done when the last ordered request is
+	timeout := session.server.SessionIdleTimeout
+	var idle Timer
+	if timeout > 0 {
+		idle = session.server.clock().AfterFunc(timeout, session.reapIdle)
+	}
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			break
+		}
+		session.touch()
+		if idle != nil {
+			idle.Reset(timeout)
+		}
+		if inline != nil && inline(raw) {
+			continue
+		}
+		wg.Add(1)
+		if !session.isOrdered(raw) {
+			go func() {
+				defer wg.Done()
+				handle(ctx, raw)
+			}()
+			continue
+		}
+		// Chain ordered requests, each waiting for the one before.
+		prev, done := last, make(chan struct{})
+		last = done
+		go func() {
+			defer wg.Done()
+			defer close(done)
+			if prev != nil {
+				<-prev
+			}
+			handle(ctx, raw)
+		}()
+	}
+
+	if idle != nil {
+		idle.Stop()
+	}
+	cancel()
+	wg.Wait()
+	session.Close()
+	close(session.done)
+}
+
+// touch records that the peer sent a message.
+func (session *Session) touch() {
+	atomic.StoreInt64(&session.active, session.server.clock().Now().UnixNano())
+}
+
+// lastActive returns when the peer last sent a message.
+func (session *Session) lastActive() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&session.active))
+}
+
+// reapIdle ends a session that exceeded the Server's SessionIdleTimeout.
+// A peer that stopped reading cannot hold it up: the notification is
+// given goingAwayTimeout before the connection is closed under it.
+func (session *Session) reapIdle() {
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		session.Notify(GoingAwayMethod, &GoingAway{Reason: "idle"})
+	}()
+	wait(session.server.clock(), goingAwayTimeout, sent)
+	session.Close()
+}
+
+// closeSession unregisters a session that has ended.
+func (s *Server) closeSession(session *Session) {
+	s.Lock()
+	delete(s.sessions, session.id)
+	s.Unlock()
+
+	if s.OnSessionClose != nil {
+		s.OnSessionClose(session)
+	}
+}
+
+// ServeListener accepts connections on l and serves each with ServeConn.
+func (s *Server) ServeListener(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// Sessions returns the currently open sessions.
+func (s *Server) Sessions() []*Session {
+	s.Lock()
+	defer s.Unlock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
@@ -0,0 +1,212 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// KeyIDHeader names the key whose secret signed the request body.
+	KeyIDHeader = "X-Jsonrpc-Key-Id"
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body.
+	SignatureHeader = "X-Jsonrpc-Signature"
+
+	// DefaultMaxSignedBodySize limits the bodies read to check their
+	// signature, as they are read whole before the sender is known.
+	DefaultMaxSignedBodySize = 10 << 20
+)
+
+var (
+	ErrUnsignedRequest  = errors.New("rpc: request is not signed")
+	ErrUnknownKey       = errors.New("rpc: unknown signing key")
+	ErrInvalidSignature = errors.New("rpc: invalid request signature")
+)
+
+// SecretStore looks up signing secrets by key id.
+type SecretStore interface {
+	Secret(keyID string) ([]byte, error)
+}
+
+// SecretMap is a static SecretStore.
+type SecretMap map[string][]byte
+
+func (m SecretMap) Secret(keyID string) ([]byte, error) {
+	if secret, ok := m[keyID]; ok {
+		return secret, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body under secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature returns a handler that checks the signature headers over
+// the raw request body against the secrets in store, rejecting unsigned or
+// tampered requests with 401 before next decodes anything. Bodies over
+// DefaultMaxSignedBodySize are rejected with 413.
+func VerifySignature(store SecretStore, next http.Handler) http.Handler {
+	return VerifySignatureLimit(store, DefaultMaxSignedBodySize, next)
+}
+
+// VerifySignatureLimit is VerifySignature rejecting bodies over
+// maxBodySize bytes, or DefaultMaxSignedBodySize if not positive.
+func VerifySignatureLimit(store SecretStore, maxBodySize int64, next http.Handler) http.Handler {
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxSignedBodySize
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		r.Body.Close()
+		if err != nil {
+			status := http.StatusBadRequest
+			if isTooLarge(err) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			WriteError(w, status, "rpc: "+err.Error())
+			return
+		}
+		if err = verifySignature(store, r.Header, body); err != nil {
+			WriteError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func verifySignature(store SecretStore, header http.Header, body []byte) (err error) {
+	keyID, signature := header.Get(KeyIDHeader), header.Get(SignatureHeader)
+	if keyID == "" || signature == "" {
+		return ErrUnsignedRequest
+	}
+	var secret []byte
+	if secret, err = store.Secret(keyID); err != nil {
+		return
+	}
+	if !hmac.Equal([]byte(Sign(secret, body)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return
+}
+
+// SigningTransport signs every request body with a shared secret before
+// handing it to Base. Use it as Client.Base to talk to servers protected by
+// VerifySignature.
+type SigningTransport struct {
+	KeyID  string
+	Secret []byte
+	Base   http.RoundTripper
+}
+
+func (t *SigningTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	var body []byte
+	if req.Body != nil {
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return
+		}
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.Header.Set(KeyIDHeader, t.KeyID)
+	req.Header.Set(SignatureHeader, Sign(t.Secret, body))
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// WebhookSignatureHeader carries the timestamped signature of a webhook
+// delivery in the form "t=<unix seconds>,v1=<hex HMAC-SHA256>", where the
+// HMAC covers the timestamp, a dot and the body.
+const WebhookSignatureHeader = "X-Jsonrpc-Webhook-Signature"
+
+// DefaultWebhookTolerance is the maximum age of a webhook delivery accepted
+// by VerifyWebhook when no tolerance is given.
+const DefaultWebhookTolerance = 5 * time.Minute
+
+var ErrWebhookExpired = errors.New("rpc: webhook signature timestamp outside tolerance")
+
+// SignWebhook returns the WebhookSignatureHeader value for body sent at t.
+func SignWebhook(secret, body []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + Sign(secret, webhookPayload(ts, body))
+}
+
+// VerifyWebhook checks a WebhookSignatureHeader value against body,
+// rejecting signatures older or newer than tolerance to prevent replays.
+func VerifyWebhook(secret, body []byte, header string, tolerance time.Duration) error {
+	if tolerance <= 0 {
+		tolerance = DefaultWebhookTolerance
+	}
+	var ts string
+	var signatures []string
+	for _, field := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			ts = kv[1]
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrUnsignedRequest
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return ErrWebhookExpired
+	}
+	expected := []byte(Sign(secret, webhookPayload(ts, body)))
+	for _, signature := range signatures {
+		if hmac.Equal(expected, []byte(signature)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// VerifyWebhookRequest reads the body of a webhook delivery, of at most
+// DefaultMaxSignedBodySize bytes, and verifies its signature, returning the
+// body on success.
+func VerifyWebhookRequest(r *http.Request, secret []byte, tolerance time.Duration) (body []byte, err error) {
+	body, err = ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, DefaultMaxSignedBodySize))
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err = VerifyWebhook(secret, body, r.Header.Get(WebhookSignatureHeader), tolerance); err != nil {
+		body = nil
+	}
+	return
+}
+
+func webhookPayload(ts string, body []byte) []byte {
+	payload := make([]byte, 0, len(ts)+1+len(body))
+	payload = append(payload, ts...)
+	payload = append(payload, '.')
+	return append(payload, body...)
+}
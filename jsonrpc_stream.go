@@ -0,0 +1,243 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// PartialResultsHeader is the request header a client sets to opt in to the
+// partial-result extension. A server honouring it may answer a single call
+// with a sequence of PartialMethod notifications followed by the final
+// response, all written to the same HTTP response body.
+const PartialResultsHeader = "X-Jsonrpc-Partial-Results"
+
+// PartialMethod is the method name of the notifications carrying partial
+// results. Their params hold the id of the originating call and the result.
+const PartialMethod = "rpc.partial"
+
+var ErrStreamClosed = errors.New("jsonrpc: partial result stream closed")
+
+// PartialWriter sends partial results for the call being served.
+type PartialWriter interface {
+	Send(result interface{}) error
+}
+
+type partialKey struct{}
+
+// Partial returns the PartialWriter for the call served under ctx.
+//
+// ok is false when the client did not opt in to partial results or the
+// transport cannot deliver them, in which case the handler should return the
+// whole result in its reply instead.
+func Partial(ctx context.Context) (writer PartialWriter, ok bool) {
+	writer, ok = ctx.Value(partialKey{}).(PartialWriter)
+	return
+}
+
+type partialParams struct {
+	Id     *json.RawMessage `json:"id"`
+	Result interface{}      `json:"result"`
+}
+
+type partialNotification struct {
+	Version string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  partialParams `json:"params"`
+}
+
+// partialWriter streams partial results into an HTTP response.
+type partialWriter struct {
+	sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+	id      *json.RawMessage
+	closed  bool
+}
+
+// newPartialWriter returns a partialWriter if the request opted in to partial
+// results and w supports flushing, or nil otherwise.
+func newPartialWriter(w http.ResponseWriter, r *http.Request, id *json.RawMessage) *partialWriter {
+	if r.Header.Get(PartialResultsHeader) == "" {
+		return nil
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil
+	}
+	return &partialWriter{w: w, flusher: flusher, id: id}
+}
+
+func (p *partialWriter) Send(result interface{}) (err error) {
+	p.Lock()
+	defer p.Unlock()
+	if p.closed {
+		return ErrStreamClosed
+	}
+	p.w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err = json.NewEncoder(p.w).Encode(&partialNotification{
+		Version: Version,
+		Method:  PartialMethod,
+		Params:  partialParams{Id: p.id, Result: result},
+	}); err != nil {
+		return
+	}
+	p.flusher.Flush()
+	return
+}
+
+// Report sends a progress notification among the partial results.
+func (p *partialWriter) Report(value interface{}) {
+	p.Lock()
+	defer p.Unlock()
+	if p.closed {
+		return
+	}
+	p.w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if json.NewEncoder(p.w).Encode(&notification{
+		Version: Version,
+		Method:  ProgressMethod,
+		Params:  &ProgressParams{ID: p.id, Progress: value},
+	}) == nil {
+		p.flusher.Flush()
+	}
+}
+
+// close prevents further sends once the final response is about to be written.
+func (p *partialWriter) close() {
+	p.Lock()
+	p.closed = true
+	p.Unlock()
+}
+
+// streamMessage is either a partial result notification or the final response.
+type streamMessage struct {
+	clientResponse
+	Method string `json:"method"`
+	Params *struct {
+		Result   *json.RawMessage `json:"result"`
+		Progress *json.RawMessage `json:"progress"`
+	} `json:"params"`
+}
+
+// Stream iterates over the partial results of a call made with Client.Stream.
+type Stream struct {
+	body      io.ReadCloser
+	decoder   *json.Decoder
+	idSession IDSession
+	partial   *json.RawMessage
+	progress  *json.RawMessage
+	response  *clientResponse
+	err       error
+}
+
+// Stream calls method like Call but opts in to partial results. The caller
+// must iterate with Next, then obtain the final result with Result and
+// release the stream with Close.
+func (client *Client) Stream(ctx context.Context, url, method string, params interface{}) (stream *Stream, err error) {
+	client.init()
+
+	var idSession IDSession
+	if idSession, err = client.IDStore.New(); err != nil {
+		return
+	}
+
+	var body []byte
+	if body, err = client.encodeCall(idSession.ID(), url, method, params); err != nil {
+		idSession.Close()
+		return
+	}
+
+	header := http.Header{}
+	header.Set(PartialResultsHeader, "true")
+
+	var resp *http.Response
+	if resp, err = client.send(ctx, url, method, body, header, client.callOptions(method).retryPolicy(client)); err != nil {
+		idSession.Close()
+		return
+	}
+
+	stream = &Stream{
+		body:      resp.Body,
+		decoder:   json.NewDecoder(resp.Body),
+		idSession: idSession,
+	}
+	return
+}
+
+// Next advances to the next partial result, reporting false once the final
+// response has been received or an error occurred. Progress notifications
+// are skipped, keeping the last for Progress.
+func (s *Stream) Next() bool {
+	if s.err != nil || s.response != nil {
+		return false
+	}
+	var message streamMessage
+	for {
+		if s.err = s.decoder.Decode(&message); s.err != nil {
+			if s.err == io.EOF {
+				s.err = io.ErrUnexpectedEOF
+			}
+			return false
+		}
+		if message.Method != ProgressMethod || message.Params == nil {
+			break
+		}
+		s.progress = message.Params.Progress
+		message = streamMessage{}
+	}
+	if message.Method == PartialMethod && message.Params != nil {
+		s.partial = message.Params.Result
+		return true
+	}
+	s.partial = nil
+	s.response = &message.clientResponse
+	return false
+}
+
+// Decode unmarshals the current partial result into v.
+func (s *Stream) Decode(v interface{}) error {
+	if s.partial == nil {
+		return ErrNullResult
+	}
+	return json.Unmarshal(*s.partial, v)
+}
+
+// Progress unmarshals the last progress the server reported into v.
+func (s *Stream) Progress(v interface{}) error {
+	if s.progress == nil {
+		return ErrNullResult
+	}
+	return json.Unmarshal(*s.progress, v)
+}
+
+// Err returns the transport or decoding error that stopped the iteration.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Result drains any remaining partial results and unmarshals the final
+// result into reply, returning the JSON-RPC error if the call failed.
+func (s *Stream) Result(reply interface{}) error {
+	for s.Next() {
+	}
+	if s.err != nil {
+		return s.err
+	}
+	if s.response.Error != nil {
+		return s.response.Error
+	}
+	if s.response.Result == nil {
+		return ErrNullResult
+	}
+	return json.Unmarshal(*s.response.Result, reply)
+}
+
+// Close releases the underlying connection.
+func (s *Stream) Close() (err error) {
+	defer checkClose(&err, s.idSession)
+	return s.body.Close()
+}
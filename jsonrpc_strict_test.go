@@ -0,0 +1,64 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// jsonRPCCodec serves JSON under another Content-Type.
+type jsonRPCCodec struct{}
+
+func (jsonRPCCodec) NewRequest(r *http.Request) ServerCodecRequest {
+	return NewCodec().NewRequest(r)
+}
+
+func TestStrictRequests(t *testing.T) {
+	s := &Server{StrictRequests: true}
+	s.RegisterCodec(jsonRPCCodec{}, "application/json-rpc")
+	if err := s.Register("item.get", func(r *http.Request, args *CacheArgs, reply *int) error {
+		*reply = args.N
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantCode    ErrorCode
+	}{
+		{"valid", "application/json", `{"jsonrpc":"2.0","id":1,"method":"item.get","params":{"n":1}}`, 0},
+		{"no version", "application/json", `{"id":1,"method":"item.get","params":{"n":1}}`, E_INVALID_REQ},
+		{"extra member", "application/json", `{"jsonrpc":"2.0","id":1,"method":"item.get","extra":1}`, E_INVALID_REQ},
+		{"scalar params", "application/json", `{"jsonrpc":"2.0","id":1,"method":"item.get","params":1}`, E_INVALID_REQ},
+		{"notification", "application/json", `{"method":"item.get","params":{"n":1}}`, E_INVALID_REQ},
+		{"registered codec", "application/json-rpc", `{"id":1,"method":"item.get","params":{"n":1}}`, E_INVALID_REQ},
+		{"batch", "application/json", `[{"id":1,"method":"item.get","params":{"n":1}}]`, E_INVALID_REQ},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			body := strings.TrimSpace(w.Body.String())
+			if strings.HasPrefix(body, "[") {
+				body = strings.TrimSuffix(strings.TrimPrefix(body, "["), "]")
+			}
+			var resp Response
+			if err := json.Unmarshal([]byte(body), &resp); err != nil {
+				t.Fatalf("status %d: %v: %q", w.Code, err, w.Body)
+			}
+			var code ErrorCode
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			if code != tt.wantCode {
+				t.Errorf("code = %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,524 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventMethod is the method of the notifications delivering events to
+// subscribers.
+const EventMethod = "rpc.event"
+
+// SubscriptionEndedMethod is the notification telling a subscriber that
+// the server ended its subscription.
+const SubscriptionEndedMethod = "rpc.subscriptionEnded"
+
+var ErrNoSession = errors.New("rpc: method requires a connection-oriented transport")
+
+// SubscribeArgs are the params of rpc.subscribe.
+type SubscribeArgs struct {
+	Topic string `json:"topic"`
+
+	// Encoding selects the EventCodec for the event data. Defaults to
+	// "json"; data in any other encoding is sent as a base64 string.
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// SubscriptionArgs are the params of rpc.unsubscribe and the reply of
+// rpc.subscribe.
+type SubscriptionArgs struct {
+	Subscription string `json:"subscription"`
+}
+
+// SubscriptionEnded is the params of a SubscriptionEndedMethod
+// notification.
+type SubscriptionEnded struct {
+	Subscription string `json:"subscription"`
+	Reason       string `json:"reason"`
+}
+
+// Event is the params of an EventMethod notification.
+type Event struct {
+	Subscription string      `json:"subscription"`
+	Topic        string      `json:"topic"`
+	Encoding     string      `json:"encoding,omitempty"`
+	Data         interface{} `json:"data"`
+}
+
+// EventCodec serializes event data for subscribers that asked for its
+// encoding, e.g. msgpack for capable clients.
+type EventCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONEventCodec is the default EventCodec, registered as "json".
+type JSONEventCodec struct{}
+
+func (JSONEventCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONEventCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// EventPayload is a published event. It holds the value handed over by
+// the publisher and serializes it lazily, at most once per encoding, when a
+// subscriber needs it.
+type EventPayload struct {
+	sync.Mutex
+	value   interface{}
+	encoded map[string][]byte
+}
+
+// NewEventPayload wraps a Go value for publishing.
+func NewEventPayload(value interface{}) *EventPayload {
+	return &EventPayload{value: value}
+}
+
+// RawEventPayload wraps data already serialized in encoding, e.g. as
+// received by a Broker from another replica.
+func RawEventPayload(encoding string, data []byte) *EventPayload {
+	return &EventPayload{encoded: map[string][]byte{encoding: data}}
+}
+
+// Encode returns the payload serialized with codec under the given
+// encoding name.
+func (p *EventPayload) Encode(encoding string, codec EventCodec) (data []byte, err error) {
+	p.Lock()
+	defer p.Unlock()
+	if data, ok := p.encoded[encoding]; ok {
+		return data, nil
+	}
+	if p.value == nil {
+		// Only raw data in another encoding: recover the value first.
+		for from, raw := range p.encoded {
+			if from == "json" {
+				err = json.Unmarshal(raw, &p.value)
+			} else {
+				err = errors.New("rpc: cannot convert event data from " + from)
+			}
+			break
+		}
+		if err != nil {
+			return
+		}
+	}
+	if data, err = codec.Marshal(p.value); err != nil {
+		return
+	}
+	if p.encoded == nil {
+		p.encoded = make(map[string][]byte)
+	}
+	p.encoded[encoding] = data
+	return
+}
+
+// Broker routes published events between server replicas, so events reach
+// subscribers regardless of which replica holds their connection.
+//
+// Brokers spanning processes transmit payloads with
+// payload.Encode("json", JSONEventCodec{}) and hand them to local
+// subscribers with RawEventPayload("json", data).
+type Broker interface {
+	// Publish sends an event on topic to every replica that subscribed to
+	// it, including this one.
+	Publish(topic string, payload *EventPayload) error
+
+	// Subscribe delivers the events published on topic by any replica to
+	// deliver until cancel is called. A replica subscribes to a topic only
+	// while it holds subscribers for it.
+	Subscribe(topic string, deliver func(payload *EventPayload)) (cancel func(), err error)
+}
+
+// MemoryBroker is the single-node Broker used by default.
+type MemoryBroker struct {
+	sync.Mutex
+	next     int
+	handlers map[string]map[int]func(*EventPayload)
+}
+
+func (b *MemoryBroker) Publish(topic string, payload *EventPayload) error {
+	b.Lock()
+	handlers := make([]func(*EventPayload), 0, len(b.handlers[topic]))
+	for _, deliver := range b.handlers[topic] {
+		handlers = append(handlers, deliver)
+	}
+	b.Unlock()
+	for _, deliver := range handlers {
+		deliver(payload)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(topic string, deliver func(payload *EventPayload)) (cancel func(), err error) {
+	b.Lock()
+	defer b.Unlock()
+	if b.handlers == nil {
+		b.handlers = make(map[string]map[int]func(*EventPayload))
+	}
+	if b.handlers[topic] == nil {
+		b.handlers[topic] = make(map[int]func(*EventPayload))
+	}
+	b.next++
+	id := b.next
+	b.handlers[topic][id] = deliver
+	cancel = func() {
+		b.Lock()
+		delete(b.handlers[topic], id)
+		if len(b.handlers[topic]) == 0 {
+			delete(b.handlers, topic)
+		}
+		b.Unlock()
+	}
+	return
+}
+
+// SubscriptionHub lets sessions subscribe to topics and delivers events
+// published on them as EventMethod notifications. Enable it with
+// Server.EnableSubscriptions.
+type SubscriptionHub struct {
+	// Counters first, for 64-bit alignment of atomic operations.
+	delivered    uint64
+	dropped      uint64
+	disconnected uint64
+
+	sync.Mutex
+
+	// Broker connects the hubs of several replicas. Defaults to a
+	// MemoryBroker, which only reaches subscribers of this process.
+	Broker Broker
+
+	// Codecs maps the encodings subscribers may ask for to their codecs,
+	// in addition to the built-in "json".
+	Codecs map[string]EventCodec
+
+	// QueueSize is the number of events buffered per subscriber before
+	// Policy applies. Defaults to 64.
+	QueueSize int
+
+	// Policy decides what happens to events for a subscriber whose queue
+	// is full. Defaults to DropNewest.
+	Policy SlowSubscriberPolicy
+
+	// IdleTimeout, if positive, ends subscriptions whose subscriber sent
+	// no message on its session for that long, sending it a
+	// SubscriptionEndedMethod notification with the reason "idle". Events
+	// delivered to it do not count as activity.
+	IdleTimeout time.Duration
+
+	// Clock times IdleTimeout. Defaults to SystemClock; it should tell
+	// the same time as the Clock of the Server.
+	Clock Clock
+
+	topics   map[string]*hubTopic
+	subs     map[string]*subscription
+	sessions map[*Session]map[string]*subscription
+}
+
+type hubTopic struct {
+	cancel func()
+	subs   map[string]*subscription
+}
+
+type subscription struct {
+	id       string
+	topic    string
+	encoding string
+	codec    EventCodec
+	session  *Session
+	queue    chan *EventPayload
+	done     chan struct{}
+}
+
+// SlowSubscriberPolicy decides what happens to events for subscribers that
+// cannot keep up.
+type SlowSubscriberPolicy int
+
+const (
+	// DropNewest discards events that do not fit in the queue. It is the
+	// default.
+	DropNewest SlowSubscriberPolicy = iota
+
+	// DropOldest discards the oldest queued event to make room.
+	DropOldest
+
+	// Disconnect closes the session of a subscriber that fell behind.
+	Disconnect
+
+	// BlockPublisher makes delivery wait for room in the queue, slowing
+	// down the publisher and every other subscriber of the topic.
+	BlockPublisher
+)
+
+// SubscriptionStats are counters of a SubscriptionHub.
+type SubscriptionStats struct {
+	Subscriptions int    `json:"subscriptions"`
+	Delivered     uint64 `json:"delivered"`
+	Dropped       uint64 `json:"dropped"`
+	Disconnected  uint64 `json:"disconnected"`
+}
+
+// Stats returns the current counters of the hub.
+func (h *SubscriptionHub) Stats() SubscriptionStats {
+	h.Lock()
+	subscriptions := len(h.subs)
+	h.Unlock()
+	return SubscriptionStats{
+		Subscriptions: subscriptions,
+		Delivered:     atomic.LoadUint64(&h.delivered),
+		Dropped:       atomic.LoadUint64(&h.dropped),
+		Disconnected:  atomic.LoadUint64(&h.disconnected),
+	}
+}
+
+// EnableSubscriptions registers the rpc.subscribe and rpc.unsubscribe
+// methods backed by h. Subscriptions require a session, i.e. a connection
+// served by ServeConn, and end with it.
+func (s *Server) EnableSubscriptions(h *SubscriptionHub) (err error) {
+	if err = s.Register("rpc.subscribe", h.subscribe); err != nil {
+		return
+	}
+	return s.Register("rpc.unsubscribe", h.unsubscribe)
+}
+
+func (h *SubscriptionHub) broker() Broker {
+	h.Lock()
+	defer h.Unlock()
+	if h.Broker == nil {
+		h.Broker = &MemoryBroker{}
+	}
+	return h.Broker
+}
+
+// Publish publishes data on topic through the broker. data is serialized
+// only when and as often as the subscribers' encodings require.
+func (h *SubscriptionHub) Publish(topic string, data interface{}) error {
+	return h.broker().Publish(topic, NewEventPayload(data))
+}
+
+// deliver queues an event received from the broker for the local
+// subscribers.
+func (h *SubscriptionHub) deliver(topic string, payload *EventPayload) {
+	h.Lock()
+	var subs []*subscription
+	if t := h.topics[topic]; t != nil {
+		for _, sub := range t.subs {
+			subs = append(subs, sub)
+		}
+	}
+	policy := h.Policy
+	h.Unlock()
+	for _, sub := range subs {
+		h.enqueue(sub, payload, policy)
+	}
+}
+
+// enqueue queues payload for sub, applying policy if its queue is full.
+func (h *SubscriptionHub) enqueue(sub *subscription, payload *EventPayload, policy SlowSubscriberPolicy) {
+	if policy == BlockPublisher {
+		select {
+		case sub.queue <- payload:
+		case <-sub.done:
+		}
+		return
+	}
+	for {
+		select {
+		case sub.queue <- payload:
+			return
+		case <-sub.done:
+			return
+		default:
+		}
+		switch policy {
+		case DropNewest:
+			atomic.AddUint64(&h.dropped, 1)
+			return
+		case DropOldest:
+			select {
+			case <-sub.queue:
+				atomic.AddUint64(&h.dropped, 1)
+			default:
+			}
+		case Disconnect:
+			atomic.AddUint64(&h.dropped, 1)
+			atomic.AddUint64(&h.disconnected, 1)
+			sub.session.Close()
+			return
+		}
+	}
+}
+
+// run sends the queued events of sub until it ends.
+func (h *SubscriptionHub) run(sub *subscription, idleTimeout time.Duration) {
+	clock := clockOr(h.Clock)
+	var timer Timer
+	var idle <-chan time.Time
+	if idleTimeout > 0 {
+		timer = clock.NewTimer(idleTimeout)
+		defer timer.Stop()
+		idle = timer.C()
+	}
+	for {
+		select {
+		case <-sub.done:
+			return
+		case <-idle:
+			if rest := idleTimeout - since(clock, sub.session.lastActive()); rest > 0 {
+				timer.Reset(rest)
+				continue
+			}
+			h.reapIdle(sub)
+			return
+		case payload := <-sub.queue:
+			data, err := payload.Encode(sub.encoding, sub.codec)
+			if err != nil {
+				continue
+			}
+			event := &Event{Subscription: sub.id, Topic: sub.topic, Data: data}
+			if sub.encoding == "json" {
+				event.Data = json.RawMessage(data)
+			} else {
+				event.Encoding = sub.encoding
+			}
+			if sub.session.Notify(EventMethod, event) == nil {
+				atomic.AddUint64(&h.delivered, 1)
+			}
+		}
+	}
+}
+
+// reapIdle ends a subscription that exceeded the IdleTimeout.
+func (h *SubscriptionHub) reapIdle(sub *subscription) {
+	h.Lock()
+	_, ok := h.subs[sub.id]
+	if ok {
+		h.remove(sub)
+	}
+	h.Unlock()
+	if ok {
+		sub.session.Notify(SubscriptionEndedMethod, &SubscriptionEnded{Subscription: sub.id, Reason: "idle"})
+	}
+}
+
+// codec returns the codec for encoding.
+func (h *SubscriptionHub) codec(encoding string) (EventCodec, bool) {
+	if codec, ok := h.Codecs[encoding]; ok {
+		return codec, true
+	}
+	if encoding == "json" {
+		return JSONEventCodec{}, true
+	}
+	return nil, false
+}
+
+func (h *SubscriptionHub) subscribe(r *http.Request, args *SubscribeArgs, reply *SubscriptionArgs) (err error) {
+	session, ok := SessionFromContext(r.Context())
+	if !ok {
+		return ErrNoSession
+	}
+	broker := h.broker()
+	encoding := args.Encoding
+	if encoding == "" {
+		encoding = "json"
+	}
+
+	h.Lock()
+	defer h.Unlock()
+	codec, ok := h.codec(encoding)
+	if !ok {
+		return &Error{Code: E_BAD_PARAMS, Message: "rpc: unsupported event encoding " + encoding}
+	}
+	queueSize := h.QueueSize
+	if queueSize <= 0 {
+		queueSize = 64
+	}
+	sub := &subscription{
+		topic:    args.Topic,
+		encoding: encoding,
+		codec:    codec,
+		session:  session,
+		queue:    make(chan *EventPayload, queueSize),
+		done:     make(chan struct{}),
+	}
+	if sub.id, err = newID(); err != nil {
+		return
+	}
+
+	if h.topics == nil {
+		h.topics = make(map[string]*hubTopic)
+		h.subs = make(map[string]*subscription)
+		h.sessions = make(map[*Session]map[string]*subscription)
+	}
+	t := h.topics[args.Topic]
+	if t == nil {
+		t = &hubTopic{subs: make(map[string]*subscription)}
+		topic := args.Topic
+		if t.cancel, err = broker.Subscribe(topic, func(payload *EventPayload) { h.deliver(topic, payload) }); err != nil {
+			return
+		}
+		h.topics[topic] = t
+	}
+	t.subs[sub.id] = sub
+	h.subs[sub.id] = sub
+	if h.sessions[session] == nil {
+		h.sessions[session] = make(map[string]*subscription)
+		go func() {
+			<-session.Done()
+			h.removeSession(session)
+		}()
+	}
+	h.sessions[session][sub.id] = sub
+	go h.run(sub, h.IdleTimeout)
+	reply.Subscription = sub.id
+	return
+}
+
+func (h *SubscriptionHub) unsubscribe(r *http.Request, args *SubscriptionArgs, reply *bool) error {
+	session, ok := SessionFromContext(r.Context())
+	if !ok {
+		return ErrNoSession
+	}
+	h.Lock()
+	defer h.Unlock()
+	sub := h.subs[args.Subscription]
+	if sub == nil || sub.session != session {
+		*reply = false
+		return nil
+	}
+	h.remove(sub)
+	*reply = true
+	return nil
+}
+
+// remove drops a subscription, leaving the topic on the broker once it has
+// no local subscribers. h must be locked.
+func (h *SubscriptionHub) remove(sub *subscription) {
+	close(sub.done)
+	delete(h.subs, sub.id)
+	if subs := h.sessions[sub.session]; subs != nil {
+		delete(subs, sub.id)
+	}
+	if t := h.topics[sub.topic]; t != nil {
+		delete(t.subs, sub.id)
+		if len(t.subs) == 0 {
+			t.cancel()
+			delete(h.topics, sub.topic)
+		}
+	}
+}
+
+func (h *SubscriptionHub) removeSession(session *Session) {
+	h.Lock()
+	defer h.Unlock()
+	for _, sub := range h.sessions[session] {
+		h.remove(sub)
+	}
+	delete(h.sessions, session)
+}
@@ -0,0 +1,149 @@
+package jsonrpc
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// E_QUOTA is the code of the error answering calls beyond the quota of
+// their tenant.
+const E_QUOTA ErrorCode = -32002
+
+// TenantLimit is the quota of one tenant. Zero values are unlimited.
+type TenantLimit struct {
+	// MaxConcurrent caps the calls running at once.
+	MaxConcurrent int
+
+	// Rate caps the calls per second, allowing bursts of up to Burst calls.
+	// Burst defaults to Rate, and at least one.
+	Rate  float64
+	Burst int
+}
+
+// TenantStats summarizes the calls of one tenant.
+type TenantStats struct {
+	Calls    int64 // calls admitted
+	Rejected int64 // calls beyond the quota
+	Failures int64 // admitted calls that failed
+	InFlight int   // calls running
+	Duration time.Duration
+}
+
+// TenantQuotas isolates the tenants sharing a Server, limiting the calls
+// of each and keeping separate statistics. Set it as Server.Tenants.
+type TenantQuotas struct {
+	sync.Mutex
+
+	// Tenant returns the tenant of a call, e.g. from its authenticated
+	// credentials. Calls of the empty tenant are neither limited nor
+	// counted.
+	Tenant func(r *http.Request) string
+
+	// Default is the limit of tenants missing from Limits.
+	Default TenantLimit
+	Limits  map[string]TenantLimit
+
+	tenants map[string]*tenantState
+}
+
+type tenantState struct {
+	stats  TenantStats
+	tokens float64
+	filled time.Time
+}
+
+// state returns the state of tenant. q must be locked.
+func (q *TenantQuotas) state(tenant string) *tenantState {
+	if q.tenants == nil {
+		q.tenants = make(map[string]*tenantState)
+	}
+	state, ok := q.tenants[tenant]
+	if !ok {
+		state = &tenantState{tokens: -1}
+		q.tenants[tenant] = state
+	}
+	return state
+}
+
+func (q *TenantQuotas) limit(tenant string) TenantLimit {
+	if limit, ok := q.Limits[tenant]; ok {
+		return limit
+	}
+	return q.Default
+}
+
+// admit counts a call of tenant, failing if it is beyond its quota.
+func (q *TenantQuotas) admit(tenant string, now time.Time) error {
+	q.Lock()
+	defer q.Unlock()
+	limit, state := q.limit(tenant), q.state(tenant)
+
+	if limit.MaxConcurrent > 0 && state.stats.InFlight >= limit.MaxConcurrent {
+		state.stats.Rejected++
+		return &Error{Code: E_QUOTA, Message: "rpc: too many concurrent calls for tenant"}
+	}
+	if limit.Rate > 0 {
+		burst := float64(limit.Burst)
+		if burst <= 0 {
+			burst = math.Max(1, limit.Rate)
+		}
+		if state.tokens < 0 {
+			state.tokens = burst
+		} else {
+			state.tokens = math.Min(burst, state.tokens+now.Sub(state.filled).Seconds()*limit.Rate)
+		}
+		state.filled = now
+		if state.tokens < 1 {
+			state.stats.Rejected++
+			return &Error{Code: E_QUOTA, Message: "rpc: call rate exceeded for tenant"}
+		}
+		state.tokens--
+	}
+	state.stats.Calls++
+	state.stats.InFlight++
+	return nil
+}
+
+// done records the end of an admitted call of tenant.
+func (q *TenantQuotas) done(tenant string, d time.Duration, err error) {
+	q.Lock()
+	defer q.Unlock()
+	state := q.state(tenant)
+	state.stats.InFlight--
+	state.stats.Duration += d
+	if err != nil {
+		state.stats.Failures++
+	}
+}
+
+// wrap enforces the quotas on the calls of next.
+func (q *TenantQuotas) wrap(clock Clock, next CallHandler) CallHandler {
+	return func(r *http.Request, method string, args, reply interface{}) (err error) {
+		tenant := ""
+		if q.Tenant != nil {
+			tenant = q.Tenant(r)
+		}
+		if tenant == "" {
+			return next(r, method, args, reply)
+		}
+		start := clock.Now()
+		if err = q.admit(tenant, start); err != nil {
+			return
+		}
+		defer func() { q.done(tenant, since(clock, start), err) }()
+		return next(r, method, args, reply)
+	}
+}
+
+// Stats returns the statistics of every tenant that made calls.
+func (q *TenantQuotas) Stats() map[string]TenantStats {
+	q.Lock()
+	defer q.Unlock()
+	stats := make(map[string]TenantStats, len(q.tenants))
+	for tenant, state := range q.tenants {
+		stats[tenant] = state.stats
+	}
+	return stats
+}
@@ -0,0 +1,71 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type tenantKey struct{}
+
+type TenantArgs struct {
+	Tenant string `json:"tenant"`
+}
+
+func TestTenantQuotas(t *testing.T) {
+	quotas := &TenantQuotas{
+		Tenant: func(r *http.Request) string {
+			tenant, _ := r.Context().Value(tenantKey{}).(string)
+			return tenant
+		},
+		Default: TenantLimit{Rate: 1, Burst: 1},
+	}
+	s := &Server{
+		Tenants: quotas,
+		Errors:  new(ErrorRegistry),
+		Clock:   &stoppedClock{now: time.Unix(1000, 0)},
+	}
+	// Authentication middleware telling the tenant from the params.
+	s.Use(func(next CallHandler) CallHandler {
+		return func(r *http.Request, method string, args, reply interface{}) error {
+			ctx := context.WithValue(r.Context(), tenantKey{}, args.(*TenantArgs).Tenant)
+			return next(r.WithContext(ctx), method, args, reply)
+		}
+	})
+	if err := s.Register("item.get", func(r *http.Request, args *TenantArgs, reply *string) error {
+		*reply = args.Tenant
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tenant   string
+		wantCode ErrorCode
+	}{
+		{"a", 0},
+		{"a", E_QUOTA},
+		{"b", 0},
+		{"", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		params, _ := json.Marshal(&TenantArgs{tt.tenant})
+		resp, err := s.Dispatcher().HandleRequest(context.Background(), &Request{Version: Version, ID: json.RawMessage("1"), Method: "item.get", Params: params})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var code ErrorCode
+		if resp.Error != nil {
+			code = resp.Error.Code
+		}
+		if code != tt.wantCode {
+			t.Errorf("tenant %q: code %d, want %d", tt.tenant, code, tt.wantCode)
+		}
+	}
+	if stats := quotas.Stats(); stats["a"].Calls != 1 || stats["a"].Rejected != 1 {
+		t.Errorf("stats of a = %+v, want 1 call and 1 rejected", stats["a"])
+	}
+}
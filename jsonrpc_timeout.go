@@ -0,0 +1,48 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultTimeoutHeader is the header carrying the caller's remaining time
+// budget in milliseconds, unless another name is configured.
+const DefaultTimeoutHeader = "X-Jsonrpc-Timeout"
+
+// setTimeoutHeader advertises the deadline of ctx on req.
+func (client *Client) setTimeoutHeader(ctx context.Context, req *http.Request) {
+	if client.DisableTimeoutHeader {
+		return
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return
+	}
+	name := client.TimeoutHeader
+	if name == "" {
+		name = DefaultTimeoutHeader
+	}
+	ms := (remaining + time.Millisecond - 1) / time.Millisecond
+	req.Header.Set(name, strconv.FormatInt(int64(ms), 10))
+}
+
+// withRequestTimeout bounds the context of r by the timeout the client
+// advertised, so handlers stop working once the caller has given up.
+func (s *Server) withRequestTimeout(r *http.Request) (*http.Request, context.CancelFunc) {
+	name := s.TimeoutHeader
+	if name == "" {
+		name = DefaultTimeoutHeader
+	}
+	ms, err := strconv.ParseInt(r.Header.Get(name), 10, 64)
+	if err != nil || ms <= 0 {
+		return r, func() {}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(ms)*time.Millisecond)
+	return r.WithContext(ctx), cancel
+}
@@ -0,0 +1,107 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// CallTrace is a simplified set of callbacks for the phases of the HTTP
+// requests a call makes, to find which phase of slow calls is at fault.
+// Any callback may be nil. For full detail attach an httptrace.ClientTrace
+// to the call context instead; both can be combined.
+type CallTrace struct {
+	// DNSDone is called when resolving host finished.
+	DNSDone func(host string, d time.Duration, err error)
+
+	// ConnectDone is called when dialing addr finished. It may be called
+	// for several addresses per request.
+	ConnectDone func(addr string, d time.Duration, err error)
+
+	// TLSHandshakeDone is called when the TLS handshake finished.
+	TLSHandshakeDone func(d time.Duration, resumed bool, err error)
+
+	// GotConn is called when a connection was obtained, new or reused.
+	GotConn func(reused bool, d time.Duration)
+
+	// FirstByte is called when the first byte of the response arrived, d
+	// counting from the start of the request.
+	FirstByte func(d time.Duration)
+}
+
+// WithCallTrace returns a copy of ctx under which calls report their
+// phases to trace. CallOptions.Trace sets a trace for all calls of a
+// method.
+func WithCallTrace(ctx context.Context, trace *CallTrace) context.Context {
+	return httptrace.WithClientTrace(ctx, trace.clientTrace())
+}
+
+// clientTrace adapts the callbacks to an httptrace.ClientTrace.
+func (trace *CallTrace) clientTrace() *httptrace.ClientTrace {
+	var (
+		mu       sync.Mutex
+		start    time.Time
+		dnsStart time.Time
+		dnsHost  string
+		tlsStart time.Time
+		dials    = make(map[string]time.Time)
+	)
+	since := func(t *time.Time) time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		return time.Since(*t)
+	}
+	set := func(t *time.Time) {
+		mu.Lock()
+		*t = time.Now()
+		mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		GetConn: func(string) { set(&start) },
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			mu.Lock()
+			dnsStart, dnsHost = time.Now(), info.Host
+			mu.Unlock()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			d, host := time.Since(dnsStart), dnsHost
+			mu.Unlock()
+			if trace.DNSDone != nil {
+				trace.DNSDone(host, d, info.Err)
+			}
+		},
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			dials[addr] = time.Now()
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			d := time.Since(dials[addr])
+			delete(dials, addr)
+			mu.Unlock()
+			if trace.ConnectDone != nil {
+				trace.ConnectDone(addr, d, err)
+			}
+		},
+		TLSHandshakeStart: func() { set(&tlsStart) },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if trace.TLSHandshakeDone != nil {
+				trace.TLSHandshakeDone(since(&tlsStart), state.DidResume, err)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			if trace.GotConn != nil {
+				trace.GotConn(info.Reused, since(&start))
+			}
+		},
+		GotFirstResponseByte: func() {
+			if trace.FirstByte != nil {
+				trace.FirstByte(since(&start))
+			}
+		},
+	}
+}
@@ -0,0 +1,267 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// GenerateTypeScriptClient writes a typed TypeScript client for the
+// methods of doc: a Methods map type describing each method's params and
+// result, a Client with one method per RPC method, batch support through
+// Client.batch, and an RpcError class carrying code and data. It fails
+// when two methods, or a method and a member of Client, map to the same
+// TypeScript name.
+func GenerateTypeScriptClient(w io.Writer, doc *OpenRPCDocument) error {
+	methods := append([]*OpenRPCMethod(nil), doc.Methods...)
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+
+	names := make([]string, len(methods))
+	seen := make(map[string]string, len(methods))
+	for i, m := range methods {
+		names[i] = tsMethodName(m.Name)
+		if tsReserved[names[i]] {
+			return fmt.Errorf("rpc: method %q maps to the reserved client member %s", m.Name, names[i])
+		}
+		if prev, ok := seen[names[i]]; ok {
+			return fmt.Errorf("rpc: methods %q and %q both map to %s", prev, m.Name, names[i])
+		}
+		seen[names[i]] = m.Name
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by jsonrpc.GenerateTypeScriptClient. DO NOT EDIT.\n")
+	if doc.Info.Title != "" {
+		fmt.Fprintf(&buf, "// %s %s\n", doc.Info.Title, doc.Info.Version)
+	}
+	buf.WriteString(tsPrelude)
+
+	fmt.Fprintf(&buf, "\nexport interface Methods {\n")
+	for _, m := range methods {
+		fmt.Fprintf(&buf, "  %s: { params: %s; result: %s };\n", strconv.Quote(m.Name), tsParams(m), tsResult(m))
+	}
+	fmt.Fprintf(&buf, "}\n")
+
+	buf.WriteString(tsClient)
+	for i, m := range methods {
+		fmt.Fprintf(&buf, "\n")
+		switch summary := strings.ReplaceAll(m.Summary, "*/", "*\\/"); {
+		case m.Deprecated && summary != "":
+			fmt.Fprintf(&buf, "  /** %s\n   * @deprecated */\n", summary)
+		case m.Deprecated:
+			fmt.Fprintf(&buf, "  /** @deprecated */\n")
+		case summary != "":
+			fmt.Fprintf(&buf, "  /** %s */\n", summary)
+		}
+		fmt.Fprintf(&buf, "  %s(params: Methods[%s][\"params\"]): Promise<Methods[%s][\"result\"]> {\n",
+			names[i], strconv.Quote(m.Name), strconv.Quote(m.Name))
+		fmt.Fprintf(&buf, "    return this.call(%s, params);\n", strconv.Quote(m.Name))
+		fmt.Fprintf(&buf, "  }\n")
+	}
+	fmt.Fprintf(&buf, "}\n")
+	buf.WriteString(tsBatch)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// tsReserved holds the members of the generated Client that a method
+// name must not shadow.
+var tsReserved = map[string]bool{
+	"constructor": true,
+	"call":        true,
+	"batch":       true,
+	"send":        true,
+	"newId":       true,
+	"nextId":      true,
+	"url":         true,
+	"fetchImpl":   true,
+}
+
+const tsPrelude = `
+export class RpcError extends Error {
+  constructor(public code: number, message: string, public data?: unknown) {
+    super(message);
+    this.name = "RpcError";
+  }
+}
+
+interface RpcResponse {
+  id: number | string | null;
+  result?: unknown;
+  error?: { code: number; message: string; data?: unknown };
+}
+
+export type Fetch = (url: string, init: { method: string; headers: Record<string, string>; body: string }) =>
+  Promise<{ ok: boolean; status: number; json(): Promise<unknown> }>;
+`
+
+const tsClient = `
+export class Client {
+  private nextId = 1;
+
+  constructor(private url: string, private fetchImpl: Fetch = (u, init) => fetch(u, init)) {}
+
+  async call<M extends keyof Methods>(method: M, params: Methods[M]["params"]): Promise<Methods[M]["result"]> {
+    const [response] = await this.send([{ jsonrpc: "2.0", id: this.nextId++, method, params }]);
+    return unwrap(response) as Methods[M]["result"];
+  }
+
+  /** Starts a batch; calls resolve once the batch is sent. */
+  batch(): Batch {
+    return new Batch(this);
+  }
+
+  /** @internal */
+  async send(requests: object[]): Promise<RpcResponse[]> {
+    const body = JSON.stringify(requests.length === 1 ? requests[0] : requests);
+    const res = await this.fetchImpl(this.url, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body,
+    });
+    const payload = (await res.json()) as RpcResponse | RpcResponse[];
+    return Array.isArray(payload) ? payload : [payload];
+  }
+
+  /** @internal */
+  newId(): number {
+    return this.nextId++;
+  }
+`
+
+const tsBatch = `
+function unwrap(response: RpcResponse): unknown {
+  if (response.error) {
+    throw new RpcError(response.error.code, response.error.message, response.error.data);
+  }
+  return response.result;
+}
+
+export class Batch {
+  private calls: { request: object; resolve: (v: unknown) => void; reject: (e: unknown) => void }[] = [];
+
+  constructor(private client: Client) {}
+
+  call<M extends keyof Methods>(method: M, params: Methods[M]["params"]): Promise<Methods[M]["result"]> {
+    return new Promise((resolve, reject) => {
+      const request = { jsonrpc: "2.0", id: this.client.newId(), method, params };
+      this.calls.push({ request, resolve: resolve as (v: unknown) => void, reject });
+    });
+  }
+
+  async send(): Promise<void> {
+    const calls = this.calls;
+    this.calls = [];
+    if (calls.length === 0) {
+      return;
+    }
+    try {
+      const responses = await this.client.send(calls.map((c) => c.request));
+      const byId = new Map(responses.map((r) => [r.id, r]));
+      for (const c of calls) {
+        const response = byId.get((c.request as { id: number }).id);
+        if (!response) {
+          c.reject(new RpcError(-32603, "missing response in batch"));
+          continue;
+        }
+        try {
+          c.resolve(unwrap(response));
+        } catch (e) {
+          c.reject(e);
+        }
+      }
+    } catch (e) {
+      calls.forEach((c) => c.reject(e));
+    }
+  }
+}
+`
+
+// tsParams returns the TypeScript type of a method's params.
+func tsParams(m *OpenRPCMethod) string {
+	if len(m.Params) == 1 && m.Params[0].Name == "params" {
+		return tsType(m.Params[0].Schema)
+	}
+	var fields []string
+	for _, p := range m.Params {
+		optional := "?"
+		if p.Required {
+			optional = ""
+		}
+		fields = append(fields, fmt.Sprintf("%s%s: %s", tsKey(p.Name), optional, tsType(p.Schema)))
+	}
+	if len(fields) == 0 {
+		return "Record<string, never>"
+	}
+	return "{ " + strings.Join(fields, "; ") + " }"
+}
+
+func tsResult(m *OpenRPCMethod) string {
+	if m.Result == nil {
+		return "unknown"
+	}
+	return tsType(m.Result.Schema)
+}
+
+// tsType returns the TypeScript type of values matching schema.
+func tsType(schema *Schema) string {
+	if schema == nil {
+		return "unknown"
+	}
+	switch schema.Type {
+	case "boolean":
+		return "boolean"
+	case "integer", "number":
+		return "number"
+	case "string":
+		return "string"
+	case "array":
+		return "Array<" + tsType(schema.Items) + ">"
+	case "object":
+		if schema.Properties == nil {
+			return "Record<string, " + tsType(schema.AdditionalProperties) + ">"
+		}
+		required := stringSet(schema.Required)
+		names := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fields := make([]string, 0, len(names))
+		for _, name := range names {
+			optional := "?"
+			if required[name] {
+				optional = ""
+			}
+			fields = append(fields, fmt.Sprintf("%s%s: %s", tsKey(name), optional, tsType(schema.Properties[name])))
+		}
+		if len(fields) == 0 {
+			return "Record<string, never>"
+		}
+		return "{ " + strings.Join(fields, "; ") + " }"
+	}
+	return "unknown"
+}
+
+// tsKey quotes property names that are not valid identifiers.
+func tsKey(name string) string {
+	for i, r := range name {
+		if !(r == '_' || r == '$' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9') {
+			return strconv.Quote(name)
+		}
+	}
+	return name
+}
+
+// tsMethodName turns "download.add_uri" into "downloadAddUri".
+func tsMethodName(name string) string {
+	id := identifier(name)
+	r, size := utf8.DecodeRuneInString(id)
+	return string(unicode.ToLower(r)) + id[size:]
+}
@@ -0,0 +1,39 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGenerateTypeScriptClientNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		methods []string
+		want    string
+		wantErr bool
+	}{
+		{"camel case", []string{"download.add_uri"}, "downloadAddUri(", false},
+		{"non-ascii", []string{"Über.status"}, "überStatus(", false},
+		{"reserved call", []string{"call"}, "", true},
+		{"reserved batch", []string{"Batch"}, "", true},
+		{"reserved newId", []string{"new_id"}, "", true},
+		{"same name", []string{"item.get", "item_get"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := new(OpenRPCDocument)
+			for _, name := range tt.methods {
+				doc.Methods = append(doc.Methods, &OpenRPCMethod{Name: name})
+			}
+			var buf bytes.Buffer
+			err := GenerateTypeScriptClient(&buf, doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.want != "" && !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("generated client lacks %q", tt.want)
+			}
+		})
+	}
+}
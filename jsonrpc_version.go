@@ -0,0 +1,80 @@
+package jsonrpc
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VersionHeader carries the highest method version a client understands,
+// for calls naming methods without a version.
+const VersionHeader = "X-Jsonrpc-Method-Version"
+
+// RegisterVersion registers handler as version of method, under the name
+// "method@version", e.g. "status@2". Calls naming a version are served by
+// exactly that version. Calls of the plain method name go, in order of
+// preference, to
+//
+//  1. the highest version not above the one in the VersionHeader,
+//  2. the method registered under the plain name, if any,
+//  3. the lowest registered version,
+//
+// so that clients predating versioning keep the schema they were written
+// against.
+func (s *Server) RegisterVersion(method string, version int, handler interface{}, opts ...MethodOption) error {
+	return s.Register(VersionedMethod(method, version), handler, opts...)
+}
+
+// VersionedMethod returns the name of version of method.
+func VersionedMethod(method string, version int) string {
+	return method + "@" + strconv.Itoa(version)
+}
+
+// splitVersion splits a versioned method name into the method and the
+// version.
+func splitVersion(name string) (method string, version int, ok bool) {
+	i := strings.LastIndexByte(name, '@')
+	if i == -1 {
+		return name, 0, false
+	}
+	version, err := strconv.Atoi(name[i+1:])
+	if err != nil || version < 0 {
+		return name, 0, false
+	}
+	return name[:i], version, true
+}
+
+// versionsOf indexes the sorted versions registered per method.
+func versionsOf(methods map[string]*methodSpec) map[string][]int {
+	versions := make(map[string][]int)
+	for name := range methods {
+		if method, version, ok := splitVersion(name); ok {
+			versions[method] = append(versions[method], version)
+		}
+	}
+	for _, v := range versions {
+		sort.Ints(v)
+	}
+	return versions
+}
+
+// version returns the method a call of method with the given header is
+// served by, following the order documented by RegisterVersion.
+func (reg *registry) version(method string, header http.Header) string {
+	versions := reg.versions[method]
+	if len(versions) == 0 {
+		return method
+	}
+	if max, err := strconv.Atoi(header.Get(VersionHeader)); err == nil {
+		for i := len(versions) - 1; i >= 0; i-- {
+			if versions[i] <= max {
+				return VersionedMethod(method, versions[i])
+			}
+		}
+	}
+	if _, ok := reg.methods[method]; ok {
+		return method
+	}
+	return VersionedMethod(method, versions[0])
+}
@@ -0,0 +1,88 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// WithVisibility makes the method visible only to callers for which
+// visible returns true. Hidden methods behave exactly like unregistered ones
+// and are left out of MethodsFor.
+func WithVisibility(visible func(ctx context.Context) bool) MethodOption {
+	return func(m *methodSpec) { m.visible = visible }
+}
+
+// RequirePrincipal is a visibility function for WithVisibility that shows
+// a method only on sessions which have authenticated, i.e. have a principal.
+func RequirePrincipal(ctx context.Context) bool {
+	session, ok := SessionFromContext(ctx)
+	return ok && session.Principal() != nil
+}
+
+type visibilityRule struct {
+	pattern string
+	visible bool
+}
+
+// Enable makes the methods matching pattern, a path.Match pattern such as
+// "admin.*", visible on this session, overriding WithVisibility.
+func (session *Session) Enable(pattern string) {
+	session.Lock()
+	session.rules = append(session.rules, visibilityRule{pattern, true})
+	session.Unlock()
+}
+
+// Disable hides the methods matching pattern on this session.
+func (session *Session) Disable(pattern string) {
+	session.Lock()
+	session.rules = append(session.rules, visibilityRule{pattern, false})
+	session.Unlock()
+}
+
+// visibility returns the outcome of the last session rule matching method.
+func (session *Session) visibility(method string) (visible, ok bool) {
+	session.Lock()
+	defer session.Unlock()
+	for i := len(session.rules) - 1; i >= 0; i-- {
+		if matchName(session.rules[i].pattern, method, session.server.CaseInsensitiveMethods) {
+			return session.rules[i].visible, true
+		}
+	}
+	return
+}
+
+// isVisible reports whether method is visible to the caller of ctx.
+func (spec *methodSpec) isVisible(ctx context.Context, method string) bool {
+	if session, ok := SessionFromContext(ctx); ok {
+		if visible, ok := session.visibility(method); ok {
+			return visible
+		}
+	}
+	return spec.visible == nil || spec.visible(ctx)
+}
+
+// MethodsFor returns the sorted names of the methods visible to the caller
+// of ctx.
+func (s *Server) MethodsFor(ctx context.Context) []string {
+	s.Lock()
+	specs := make(map[string]*methodSpec, len(s.methods))
+	for name, spec := range s.methods {
+		specs[name] = spec
+	}
+	s.Unlock()
+
+	names := make([]string, 0, len(specs))
+	for name, spec := range specs {
+		if spec.isVisible(ctx, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// errMethodNotFound is the error for unknown and hidden methods alike.
+func errMethodNotFound(method string) error {
+	return &Error{Code: E_NO_METHOD, Message: fmt.Sprintf("rpc: can't find method %q", method)}
+}
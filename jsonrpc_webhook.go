@@ -0,0 +1,334 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrWebhookQueueFull is returned by Notify when deliveries wait for
+	// a worker already.
+	ErrWebhookQueueFull = errors.New("rpc: webhook delivery queue is full")
+
+	// ErrWebhooksClosed is returned by Notify once the manager is closed.
+	ErrWebhooksClosed = errors.New("rpc: webhook manager is closed")
+)
+
+// Webhook is a client's registration to receive notifications by HTTP POST.
+type Webhook struct {
+	ID string `json:"id"`
+
+	// URL receives the notifications.
+	URL string `json:"url"`
+
+	// Events lists the notification methods delivered, as exact names or
+	// path.Match patterns. An empty list receives every notification.
+	Events []string `json:"events,omitempty"`
+
+	// Secret signs the deliveries, see VerifyWebhook. It is only returned
+	// on registration.
+	Secret string `json:"secret,omitempty"`
+}
+
+// WebhookArgs are the params of webhook.register.
+type WebhookArgs struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+}
+
+// WebhookIDArgs are the params of webhook.unregister.
+type WebhookIDArgs struct {
+	ID string `json:"id"`
+}
+
+// WebhookManager delivers server notifications to registered webhooks as
+// signed JSON-RPC notification POSTs in a bounded worker pool, retrying
+// failed deliveries with exponential backoff. Enable it with
+// Server.EnableWebhooks, and stop it with Close.
+type WebhookManager struct {
+	sync.Mutex
+
+	// Client sends the deliveries. Defaults to a client with a 30 second
+	// timeout that, unless AllowURL is set, refuses to connect to the
+	// addresses the default URL check refuses, so that host names resolving
+	// to them are refused too.
+	Client *http.Client
+
+	// AllowURL, if set, checks the URLs webhooks are registered for in
+	// place of the default check, which refuses localhost and loopback,
+	// private, link-local and unspecified addresses, such as the cloud
+	// metadata address 169.254.169.254.
+	AllowURL func(u *url.URL) error
+
+	// Workers is the number of deliveries made concurrently. Defaults to 4.
+	Workers int
+
+	// QueueSize is the number of deliveries that may wait for a worker
+	// before Notify fails with ErrWebhookQueueFull. Defaults to 100.
+	QueueSize int
+
+	// Retry controls redelivery of notifications that failed with a
+	// transport error or a 429 or 5xx status. Defaults to 5 attempts with a
+	// backoff from one second up to a minute.
+	Retry *RetryPolicy
+
+	// Clock times the retries and signatures. Defaults to SystemClock.
+	Clock Clock
+
+	hooks  map[string]*Webhook
+	queue  chan webhookDelivery
+	ctx    context.Context // canceled by Close
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+type webhookDelivery struct {
+	hook Webhook
+	body []byte
+}
+
+var defaultWebhookRetry = &RetryPolicy{MaxAttempts: 5, Backoff: time.Second, MaxBackoff: time.Minute}
+
+// EnableWebhooks registers the webhook.register and webhook.unregister
+// methods backed by m. The random webhook id returned on registration is
+// the only handle to unregister it.
+func (s *Server) EnableWebhooks(m *WebhookManager) (err error) {
+	if err = s.Register("webhook.register", m.register); err != nil {
+		return
+	}
+	return s.Register("webhook.unregister", m.unregister)
+}
+
+// Add registers a webhook, generating its id and secret if unset.
+func (m *WebhookManager) Add(hook Webhook) (added *Webhook, err error) {
+	u, errURL := url.Parse(hook.URL)
+	if errURL != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, &Error{Code: E_BAD_PARAMS, Message: "rpc: webhook url must be an absolute http(s) URL"}
+	}
+	if m.AllowURL != nil {
+		err = m.AllowURL(u)
+	} else if host := strings.ToLower(u.Hostname()); host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		err = errPrivateWebhook
+	} else if ip := net.ParseIP(host); ip != nil && isPrivateIP(ip) {
+		err = errPrivateWebhook
+	}
+	if err != nil {
+		return nil, &Error{Code: E_BAD_PARAMS, Message: err.Error()}
+	}
+	if hook.ID == "" {
+		if hook.ID, err = newID(); err != nil {
+			return
+		}
+	}
+	if hook.Secret == "" {
+		if hook.Secret, err = newID(); err != nil {
+			return
+		}
+	}
+	m.Lock()
+	if m.hooks == nil {
+		m.hooks = make(map[string]*Webhook)
+	}
+	m.hooks[hook.ID] = &hook
+	m.Unlock()
+	added = &hook
+	return
+}
+
+// Remove unregisters the webhook with the given id.
+func (m *WebhookManager) Remove(id string) bool {
+	m.Lock()
+	defer m.Unlock()
+	_, ok := m.hooks[id]
+	delete(m.hooks, id)
+	return ok
+}
+
+// Notify delivers a notification to every webhook subscribed to method.
+// Deliveries happen in the background; those that do not fit in the queue
+// are dropped, and Notify fails with ErrWebhookQueueFull.
+func (m *WebhookManager) Notify(method string, params interface{}) error {
+	m.start()
+	if m.ctx.Err() != nil {
+		return ErrWebhooksClosed
+	}
+	body, err := json.Marshal(&notification{Version: Version, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	m.Lock()
+	var hooks []Webhook
+	for _, hook := range m.hooks {
+		if hook.subscribed(method) {
+			hooks = append(hooks, *hook)
+		}
+	}
+	m.Unlock()
+	for _, hook := range hooks {
+		select {
+		case m.queue <- webhookDelivery{hook, body}:
+		default:
+			err = ErrWebhookQueueFull
+		}
+	}
+	return err
+}
+
+// Close stops the delivery workers, abandoning the deliveries in progress
+// or waiting for a worker.
+func (m *WebhookManager) Close() error {
+	m.start()
+	m.cancel()
+	return nil
+}
+
+func (m *WebhookManager) start() {
+	m.once.Do(func() {
+		workers, queueSize := m.Workers, m.QueueSize
+		if workers <= 0 {
+			workers = 4
+		}
+		if queueSize <= 0 {
+			queueSize = 100
+		}
+		m.ctx, m.cancel = context.WithCancel(context.Background())
+		m.queue = make(chan webhookDelivery, queueSize)
+		for i := 0; i < workers; i++ {
+			go m.work()
+		}
+	})
+}
+
+func (m *WebhookManager) work() {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case d := <-m.queue:
+			m.deliver(m.ctx, d.hook, d.body)
+		}
+	}
+}
+
+func (hook *Webhook) subscribed(method string) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, pattern := range hook.Events {
+		if ok, _ := path.Match(pattern, method); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver posts body to the webhook until it is accepted, the retry
+// policy gives up or ctx is done.
+func (m *WebhookManager) deliver(ctx context.Context, hook Webhook, body []byte) {
+	client, policy := m.Client, m.Retry
+	if client == nil && m.AllowURL != nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	} else if client == nil {
+		client = defaultWebhookClient
+	}
+	if policy == nil {
+		policy = defaultWebhookRetry
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = m.post(ctx, client, hook, body); err == nil {
+			return
+		}
+		var status webhookStatusError
+		if errors.As(err, &status) && status != http.StatusTooManyRequests && status < 500 {
+			break
+		}
+		if attempt >= policy.MaxAttempts {
+			break
+		}
+		if !wait(clockOr(m.Clock), policy.backoff(attempt), ctx.Done()) {
+			return
+		}
+	}
+	log.Printf("rpc: webhook %s delivery to %s failed: %v", hook.ID, hook.URL, err)
+}
+
+type webhookStatusError int
+
+func (status webhookStatusError) Error() string {
+	return "rpc: webhook responded with " + http.StatusText(int(status))
+}
+
+func (m *WebhookManager) post(ctx context.Context, client *http.Client, hook Webhook, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(KeyIDHeader, hook.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook([]byte(hook.Secret), body, clockOr(m.Clock).Now()))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return webhookStatusError(resp.StatusCode)
+	}
+	return nil
+}
+
+var errPrivateWebhook = errors.New("rpc: webhook url must not address a private host")
+
+// defaultWebhookClient refuses to connect to private addresses.
+var defaultWebhookClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 30 * time.Second,
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+					return errPrivateWebhook
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// isPrivateIP reports whether ip is not a public unicast address.
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+func (m *WebhookManager) register(r *http.Request, args *WebhookArgs, reply *Webhook) error {
+	hook, err := m.Add(Webhook{URL: args.URL, Events: args.Events})
+	if err != nil {
+		return err
+	}
+	*reply = *hook
+	return nil
+}
+
+func (m *WebhookManager) unregister(r *http.Request, args *WebhookIDArgs, reply *bool) error {
+	*reply = m.Remove(args.ID)
+	return nil
+}
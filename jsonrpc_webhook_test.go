@@ -0,0 +1,71 @@
+package jsonrpc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestWebhookURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		allow   func(*url.URL) error
+		wantErr bool
+	}{
+		{"https://hooks.example.com/notify", nil, false},
+		{"ftp://hooks.example.com/notify", nil, true},
+		{"/notify", nil, true},
+		{"http://localhost:8080/", nil, true},
+		{"http://api.localhost/", nil, true},
+		{"http://127.0.0.1/", nil, true},
+		{"http://169.254.169.254/latest/meta-data/", nil, true},
+		{"http://10.0.0.1/", nil, true},
+		{"http://[::1]/", nil, true},
+		{"http://0.0.0.0/", nil, true},
+		{"http://127.0.0.1/", func(*url.URL) error { return nil }, false},
+		{"https://hooks.example.com/", func(*url.URL) error { return errors.New("denied") }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			m := &WebhookManager{AllowURL: tt.allow}
+			defer m.Close()
+			_, err := m.Add(Webhook{URL: tt.url})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Add(%q) = %v, want error %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	received := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(KeyIDHeader)
+	}))
+	defer ts.Close()
+
+	m := &WebhookManager{AllowURL: func(*url.URL) error { return nil }}
+	hook, err := m.Add(Webhook{URL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Notify("item.created", map[string]int{"id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case id := <-received:
+		if id != hook.ID {
+			t.Errorf("delivered with key id %q, want %q", id, hook.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification was not delivered")
+	}
+
+	m.Close()
+	if err = m.Notify("item.created", nil); err != ErrWebhooksClosed {
+		t.Errorf("Notify after Close = %v, want ErrWebhooksClosed", err)
+	}
+}
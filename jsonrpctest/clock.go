@@ -0,0 +1,147 @@
+package jsonrpctest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-webdl/jsonrpc"
+)
+
+// FakeClock is a jsonrpc.Clock whose time only moves when advanced, for
+// testing retries, rate limits, cache expiry and idle timeouts instantly
+// and deterministically. Set it as the Clock of a Server, Client,
+// PersistentClient, SubscriptionHub, WebhookManager or Accounting, or pass
+// it to OpenFileCache.
+type FakeClock struct {
+	sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer
+	changed chan struct{} // closed when a timer is added
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) jsonrpc.Timer {
+	t := &fakeTimer{clock: c, ch: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) jsonrpc.Timer {
+	t := &fakeTimer{clock: c, f: f}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the time forward by d, firing the timers that expire on
+// the way in order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Lock()
+	end := c.now.Add(d)
+	for len(c.timers) != 0 && !c.timers[0].when.After(end) {
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.when
+		t.fire()
+	}
+	c.now = end
+	c.Unlock()
+}
+
+// BlockUntil waits until at least n timers are pending, e.g. until the
+// goroutine under test started waiting for the time to be advanced.
+func (c *FakeClock) BlockUntil(n int) {
+	for {
+		c.Lock()
+		if len(c.timers) >= n {
+			c.Unlock()
+			return
+		}
+		if c.changed == nil {
+			c.changed = make(chan struct{})
+		}
+		changed := c.changed
+		c.Unlock()
+		<-changed
+	}
+}
+
+// Pending returns the number of timers that have not fired or been
+// stopped.
+func (c *FakeClock) Pending() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.timers)
+}
+
+// remove unschedules t, reporting whether it was pending. c is locked.
+func (c *FakeClock) remove(t *fakeTimer) bool {
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	ch    chan time.Time
+	f     func()
+}
+
+// fire expires the timer. The clock is locked.
+func (t *fakeTimer) fire() {
+	if t.f != nil {
+		go t.f()
+		return
+	}
+	select {
+	case t.ch <- t.when:
+	default:
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.Lock()
+	defer c.Unlock()
+	return c.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.Lock()
+	defer c.Unlock()
+	active := c.remove(t)
+	t.when = c.now.Add(d)
+	if d <= 0 {
+		t.fire()
+		return active
+	}
+	i := sort.Search(len(c.timers), func(i int) bool { return c.timers[i].when.After(t.when) })
+	c.timers = append(c.timers, nil)
+	copy(c.timers[i+1:], c.timers[i:])
+	c.timers[i] = t
+	if c.changed != nil {
+		close(c.changed)
+		c.changed = nil
+	}
+	return active
+}
@@ -0,0 +1,189 @@
+package jsonrpctest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/go-webdl/jsonrpc"
+)
+
+// Exchange is a call recorded in a Contract.
+type Exchange struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *jsonrpc.Error  `json:"error,omitempty"`
+}
+
+// Contract is a snapshot of the calls a client made and the responses it
+// relied on, replayed against a server by VerifyContract.
+type Contract struct {
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// ReadContract reads a contract snapshot file.
+func ReadContract(path string) (contract *Contract, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return
+	}
+	contract = new(Contract)
+	if err = json.Unmarshal(data, contract); err != nil {
+		contract = nil
+	}
+	return
+}
+
+// WriteFile writes the contract to path.
+func (c *Contract) WriteFile(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// OpenRPC learns an OpenRPC document from the successful exchanges of the
+// contract, for tooling used against endpoints that cannot describe
+// themselves. See jsonrpc.InferOpenRPC.
+func (c *Contract) OpenRPC(info jsonrpc.OpenRPCInfo) *jsonrpc.OpenRPCDocument {
+	var calls []jsonrpc.ObservedCall
+	for _, exchange := range c.Exchanges {
+		if exchange.Error == nil {
+			calls = append(calls, jsonrpc.ObservedCall{Method: exchange.Method, Params: exchange.Params, Result: exchange.Result})
+		}
+	}
+	return jsonrpc.InferOpenRPC(info, calls)
+}
+
+// Recorder records the calls of a jsonrpc.Client into a Contract. Set it
+// as the Client's Base during a test run, then write Contract() to the
+// snapshot file the server's tests verify.
+type Recorder struct {
+	sync.Mutex
+
+	// Base sends the requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+
+	contract Contract
+}
+
+func (rec *Recorder) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	base := rec.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	var body []byte
+	if req.Body != nil {
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if resp, err = base.RoundTrip(req); err != nil {
+		return
+	}
+	var call jsonrpc.Request
+	if json.Unmarshal(body, &call) != nil || call.Method == "" || resp.Header.Get("Content-Encoding") != "" {
+		// Not a single identity-encoded call, e.g. a batch.
+		return
+	}
+
+	var data []byte
+	data, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	var result jsonrpc.Response
+	if json.Unmarshal(data, &result) == nil {
+		rec.Lock()
+		rec.contract.Exchanges = append(rec.contract.Exchanges, Exchange{
+			Method: call.Method,
+			Params: call.Params,
+			Result: result.Result,
+			Error:  result.Error,
+		})
+		rec.Unlock()
+	}
+	return
+}
+
+// Contract returns the calls recorded so far.
+func (rec *Recorder) Contract() *Contract {
+	rec.Lock()
+	defer rec.Unlock()
+	return &Contract{Exchanges: append([]Exchange(nil), rec.contract.Exchanges...)}
+}
+
+// ContractError lists the exchanges a server no longer honors.
+type ContractError struct {
+	Mismatches []ContractMismatch
+}
+
+// ContractMismatch is an exchange answered differently than recorded.
+type ContractMismatch struct {
+	Index    int
+	Exchange Exchange
+	Got      *jsonrpc.Response
+}
+
+func (e *ContractError) Error() string {
+	lines := make([]string, len(e.Mismatches))
+	for i, m := range e.Mismatches {
+		got := string(m.Got.Result)
+		if m.Got.Error != nil {
+			got = fmt.Sprintf("error %d %s", m.Got.Error.Code, m.Got.Error.Message)
+		}
+		lines[i] = fmt.Sprintf("exchange %d (%s): got %s", m.Index, m.Exchange.Method, got)
+	}
+	return "jsonrpctest: contract broken:\n" + strings.Join(lines, "\n")
+}
+
+// VerifyContract replays the exchanges of contract against server and
+// returns a *ContractError if any is answered differently: with another
+// result, compared as JSON values, or with another error code. Error
+// messages may differ.
+func VerifyContract(ctx context.Context, server *jsonrpc.Server, contract *Contract) error {
+	dispatcher := server.Dispatcher()
+	contractErr := &ContractError{}
+	for i, exchange := range contract.Exchanges {
+		resp, err := dispatcher.HandleRequest(ctx, &jsonrpc.Request{
+			Version: jsonrpc.Version,
+			Method:  exchange.Method,
+			Params:  exchange.Params,
+			ID:      json.RawMessage("1"),
+		})
+		if err != nil {
+			return err
+		}
+		if !honors(exchange, resp) {
+			contractErr.Mismatches = append(contractErr.Mismatches, ContractMismatch{i, exchange, resp})
+		}
+	}
+	if len(contractErr.Mismatches) != 0 {
+		return contractErr
+	}
+	return nil
+}
+
+func honors(exchange Exchange, resp *jsonrpc.Response) bool {
+	if exchange.Error != nil || resp.Error != nil {
+		return exchange.Error != nil && resp.Error != nil && exchange.Error.Code == resp.Error.Code
+	}
+	var want, got interface{}
+	if json.Unmarshal(exchange.Result, &want) != nil || json.Unmarshal(resp.Result, &got) != nil {
+		return bytes.Equal(exchange.Result, resp.Result)
+	}
+	return reflect.DeepEqual(want, got)
+}
@@ -0,0 +1,189 @@
+// Package jsonrpctest provides helpers for testing and benchmarking
+// JSON-RPC services built with package jsonrpc.
+package jsonrpctest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-webdl/jsonrpc"
+)
+
+// LoadOptions configures Load.
+type LoadOptions struct {
+	// URL is the endpoint called.
+	URL string
+
+	// Client makes the calls. Defaults to a zero jsonrpc.Client.
+	Client *jsonrpc.Client
+
+	// Method is the method called. Defaults to system.echo.
+	Method string
+
+	// Params, if set, are the params of every call. Otherwise each call
+	// sends a string of PayloadSize bytes.
+	Params interface{}
+
+	// PayloadSize is the size of the generated payload. Defaults to 64.
+	PayloadSize int
+
+	// Concurrency is the number of concurrent callers. Defaults to 1.
+	Concurrency int
+
+	// Duration is how long the load runs. Defaults to 10 seconds.
+	Duration time.Duration
+}
+
+// LoadReport is the outcome of Load.
+type LoadReport struct {
+	Calls    int
+	Errors   int
+	Duration time.Duration
+
+	// Latencies are the latencies of successful calls, sorted ascending.
+	Latencies []time.Duration
+}
+
+// Load calls a method repeatedly from concurrent callers until the
+// duration elapses or ctx is done, and reports the latencies.
+func Load(ctx context.Context, opts LoadOptions) *LoadReport {
+	client := opts.Client
+	if client == nil {
+		client = &jsonrpc.Client{}
+	}
+	method := opts.Method
+	if method == "" {
+		method = "system.echo"
+	}
+	params := opts.Params
+	if params == nil {
+		size := opts.PayloadSize
+		if size <= 0 {
+			size = 64
+		}
+		params = strings.Repeat("x", size)
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	duration := opts.Duration
+	if duration <= 0 {
+		duration = 10 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		report = &LoadReport{}
+		start  = time.Now()
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var latencies []time.Duration
+			var errors int
+			for ctx.Err() == nil {
+				var reply json.RawMessage
+				t := time.Now()
+				err := client.Call(ctx, opts.URL, method, params, &reply)
+				if err != nil {
+					if ctx.Err() != nil {
+						break
+					}
+					errors++
+					continue
+				}
+				latencies = append(latencies, time.Since(t))
+			}
+			mu.Lock()
+			report.Calls += len(latencies) + errors
+			report.Errors += errors
+			report.Latencies = append(report.Latencies, latencies...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	report.Duration = time.Since(start)
+	sort.Slice(report.Latencies, func(i, j int) bool { return report.Latencies[i] < report.Latencies[j] })
+	return report
+}
+
+// Percentile returns the latency below which the fraction p of successful
+// calls fall, with p from 0 to 1.
+func (r *LoadReport) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	i := int(p * float64(len(r.Latencies)-1))
+	return r.Latencies[i]
+}
+
+// Throughput returns the number of calls per second.
+func (r *LoadReport) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Calls) / r.Duration.Seconds()
+}
+
+// histogramBuckets are the upper bounds of the latency histogram.
+var histogramBuckets = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// WriteTo writes a summary and a latency histogram to w.
+func (r *LoadReport) WriteTo(w io.Writer) (n int64, err error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "calls: %d  errors: %d  duration: %s  throughput: %.1f/s\n",
+		r.Calls, r.Errors, r.Duration.Round(time.Millisecond), r.Throughput())
+	fmt.Fprintf(&b, "p50: %s  p90: %s  p99: %s  max: %s\n",
+		r.Percentile(0.5), r.Percentile(0.9), r.Percentile(0.99), r.Percentile(1))
+
+	counts := make([]int, len(histogramBuckets)+1)
+	for _, l := range r.Latencies {
+		i := sort.Search(len(histogramBuckets), func(i int) bool { return l <= histogramBuckets[i] })
+		counts[i]++
+	}
+	max := 0
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+	}
+	for i, c := range counts {
+		if c == 0 {
+			continue
+		}
+		label := "+Inf"
+		if i < len(histogramBuckets) {
+			label = histogramBuckets[i].String()
+		}
+		fmt.Fprintf(&b, "<= %-8s %8d %s\n", label, c, strings.Repeat("#", c*40/max))
+	}
+
+	written, err := io.WriteString(w, b.String())
+	return int64(written), err
+}